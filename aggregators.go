@@ -0,0 +1,62 @@
+/*
+ * Copyright (C) 2015 zulily, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reckon
+
+import "strings"
+
+// NormalizingAggregator wraps `inner`, applying `normalize` to each of the
+// group names it returns.  Groups that differ only in ways removed by
+// `normalize` (e.g. case, when using strings.ToLower) are thereby
+// consolidated.  If normalization causes a key to be assigned to the same
+// group more than once, the duplicates are dropped.
+func NormalizingAggregator(inner Aggregator, normalize func(string) string) Aggregator {
+	return AggregatorFunc(func(key string, valueType ValueType) []string {
+		groups := inner.Groups(key, valueType)
+		normalized := make([]string, 0, len(groups))
+		seen := make(map[string]bool)
+		for _, g := range groups {
+			n := normalize(g)
+			if !seen[n] {
+				seen[n] = true
+				normalized = append(normalized, n)
+			}
+		}
+		return normalized
+	})
+}
+
+// TypeAndNamespaceAggregator returns an Aggregator that groups keys by both
+// their namespace and their ValueType, e.g. to see that the `user` namespace
+// is mostly hashes.  A key's namespace is its name up to the `depth`th
+// occurrence of `sep` (or its whole name, if `sep` occurs fewer times), and
+// its group is named `<namespace><sep><type>`: with a `sep` of ":" and a
+// `depth` of 1, the hash `user:1234` is assigned to group `user:hash`.  Since
+// ValueTypes never contain `sep`, a group name can be split back into its
+// namespace and type at its last occurrence of `sep`.  A `depth` less than
+// 1 is treated as 1.
+func TypeAndNamespaceAggregator(sep string, depth int) Aggregator {
+	if depth < 1 {
+		depth = 1
+	}
+	return AggregatorFunc(func(key string, valueType ValueType) []string {
+		namespace := key
+		if parts := strings.SplitN(key, sep, depth+1); len(parts) > depth {
+			namespace = strings.Join(parts[:depth], sep)
+		}
+		return []string{namespace + sep + string(valueType)}
+	})
+}
@@ -0,0 +1,63 @@
+/*
+ * Copyright (C) 2015 zulily, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reckon
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalizingAggregator(t *testing.T) {
+
+	prefix := AggregatorFunc(func(key string, valueType ValueType) []string {
+		return []string{strings.SplitN(key, ":", 2)[0], " " + strings.SplitN(key, ":", 2)[0]}
+	})
+	agg := NormalizingAggregator(prefix, func(s string) string {
+		return strings.ToLower(strings.TrimSpace(s))
+	})
+
+	for _, key := range []string{"User:1", "user:2", "USER:3"} {
+		groups := agg.Groups(key, TypeString)
+		if !reflect.DeepEqual([]string{"user"}, groups) {
+			t.Errorf("expected: [user], actual: %v", groups)
+		}
+	}
+}
+
+func TestTypeAndNamespaceAggregator(t *testing.T) {
+
+	cases := []struct {
+		depth    int
+		key      string
+		vt       ValueType
+		expected string
+	}{
+		{1, "user:1234", TypeHash, "user:hash"},
+		{1, "user:1234:profile", TypeString, "user:string"},
+		{2, "user:1234:profile", TypeString, "user:1234:string"},
+		{2, "user:1234", TypeSet, "user:1234:set"},
+		{1, "counter", TypeString, "counter:string"},
+		{0, "user:1234", TypeList, "user:list"},
+	}
+	for _, c := range cases {
+		groups := TypeAndNamespaceAggregator(":", c.depth).Groups(c.key, c.vt)
+		if !reflect.DeepEqual([]string{c.expected}, groups) {
+			t.Errorf("depth %d key %q: expected: [%s], actual: %v", c.depth, c.key, c.expected, groups)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+/*
+ * Copyright (C) 2015 zulily, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reckon
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// A clusterShard is a redis cluster master, with the addresses (host:port) of
+// its replicas, as reported by `CLUSTER SLOTS`
+type clusterShard struct {
+	master   string
+	replicas []string
+}
+
+// clusterShards obtains the shards of the redis cluster to which `conn` is
+// connected, in the order in which `CLUSTER SLOTS` first reports them.  A
+// master that serves several slot ranges is reported once.  Nodes reported
+// without a host are assumed to share `seedHost`, that of the node queried.
+func clusterShards(conn redis.Conn, seedHost string) ([]clusterShard, error) {
+	ranges, err := redis.Values(conn.Do("CLUSTER", "SLOTS"))
+	if err != nil {
+		return nil, err
+	}
+
+	var shards []clusterShard
+	seen := make(map[string]bool)
+	for _, r := range ranges {
+		fields, err := redis.Values(r, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) < 3 {
+			return nil, errors.New("unexpected CLUSTER SLOTS reply")
+		}
+		var nodes []string
+		for _, n := range fields[2:] {
+			node, err := redis.Values(n, nil)
+			if err != nil {
+				return nil, err
+			}
+			if len(node) < 2 {
+				return nil, errors.New("unexpected CLUSTER SLOTS reply")
+			}
+			host, err := redis.String(node[0], nil)
+			if err != nil {
+				return nil, err
+			}
+			port, err := redis.Int(node[1], nil)
+			if err != nil {
+				return nil, err
+			}
+			if host == "" {
+				host = seedHost
+			}
+			nodes = append(nodes, net.JoinHostPort(host, strconv.Itoa(port)))
+		}
+		if seen[nodes[0]] {
+			continue
+		}
+		seen[nodes[0]] = true
+		shards = append(shards, clusterShard{master: nodes[0], replicas: nodes[1:]})
+	}
+	return shards, nil
+}
+
+// RunCluster samples every shard of the redis cluster of which the node
+// configured by `fns` (see NewOptions) is a member, concurrently, and merges
+// their results with MergeConcurrent.  The shards are discovered with
+// `CLUSTER SLOTS`.  With PreferReplica, each shard that has a replica is
+// sampled from its first replica, on connections in `READONLY` mode, so that
+// the masters serve no sampling load; the other shards are sampled from
+// their masters, which AllowMaster must permit as usual.  The key count of
+// each shard is always read from its master, since a replica may lag, and
+// the total key count of the cluster is returned.  Shards without keys are
+// not sampled.  As for Sampler.RunMulti,
+// the Aggregator and any callbacks must be safe for concurrent use.
+func RunCluster(aggregator Aggregator, fns ...func(*Options) error) (map[string]*Results, int64, error) {
+	seed, err := NewOptions(fns...)
+	if err != nil {
+		return nil, 0, err
+	}
+	conn, err := dial(seed)
+	if err != nil {
+		return nil, 0, err
+	}
+	shards, err := clusterShards(conn, seed.Host)
+	conn.Close()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var opts []Options
+	for _, shard := range shards {
+		master := seed
+		if err = setAddr(&master, shard.master); err != nil {
+			return nil, 0, err
+		}
+		keys, err := shardKeyCount(master)
+		if err == ErrNoKeys {
+			continue
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+
+		target := master
+		if seed.PreferReplica && len(shard.replicas) > 0 {
+			if err = setAddr(&target, shard.replicas[0]); err != nil {
+				return nil, 0, err
+			}
+			target.ReadOnly = true
+		}
+		target.KeyCount = func(redis.Conn) (int64, error) { return keys, nil }
+		opts = append(opts, target)
+	}
+	if len(opts) == 0 {
+		return nil, 0, ErrNoKeys
+	}
+	return runConcurrently(opts, aggregator)
+}
+
+// setAddr sets the Host and Port of `opts` from the host:port `addr`
+func setAddr(opts *Options, addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if opts.Port, err = strconv.Atoi(port); err != nil {
+		return err
+	}
+	opts.Host = host
+	return nil
+}
+
+// shardKeyCount obtains the key count of the cluster master configured by
+// `opts`, or ErrNoKeys if it has none
+func shardKeyCount(opts Options) (int64, error) {
+	conn, err := dial(opts)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	keys, err := countKeys(opts, conn)
+	if err != nil && err != ErrNoKeys {
+		return 0, fmt.Errorf("Error counting the keys of the cluster master at: %s:%d : %s", opts.Host, opts.Port, err.Error())
+	}
+	return keys, err
+}
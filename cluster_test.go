@@ -0,0 +1,127 @@
+/*
+ * Copyright (C) 2015 zulily, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reckon
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// bulk and array encode RESP bulk strings and arrays of encoded replies
+func bulk(s string) string { return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s) }
+
+func array(replies ...string) string {
+	return fmt.Sprintf("*%d\r\n%s", len(replies), strings.Join(replies, ""))
+}
+
+// fakeCluster serves a two-shard redis cluster: the first shard's master has
+// a lagging replica, and the second shard has no replica.  It records the
+// commands received at each address.
+type fakeCluster struct {
+	sync.Mutex
+	commands map[string][]string
+}
+
+func (c *fakeCluster) Dial(network, addr string) (net.Conn, error) {
+	return respDialer{handler: func(args []string) string {
+		c.Lock()
+		c.commands[addr] = append(c.commands[addr], args[0])
+		c.Unlock()
+		return c.reply(addr, args)
+	}}.Dial(network, addr)
+}
+
+func (c *fakeCluster) reply(addr string, args []string) string {
+	keys := map[string]int{"10.0.0.1:7000": 10, "10.0.0.2:7001": 9, "10.0.0.3:7002": 20}[addr]
+	role := "master"
+	if addr == "10.0.0.2:7001" {
+		role = "slave"
+	}
+	switch {
+	case args[0] == "CLUSTER" && args[1] == "SLOTS":
+		node := func(host string, port int) string { return array(bulk(host), fmt.Sprintf(":%d\r\n", port)) }
+		return array(
+			array(":0\r\n", ":5460\r\n", node("", 7000), node("10.0.0.2", 7001)),
+			array(":5461\r\n", ":16383\r\n", node("10.0.0.3", 7002)),
+			array(":5461\r\n", ":5470\r\n", node("", 7000), node("10.0.0.2", 7001)),
+		)
+	case args[0] == "INFO" && args[1] == "keyspace":
+		return bulk(fmt.Sprintf("# Keyspace\r\ndb0:keys=%d,expires=0,avg_ttl=0\r\n", keys))
+	case args[0] == "INFO" && args[1] == "replication":
+		return bulk("# Replication\r\nrole:" + role + "\r\n")
+	case args[0] == "READONLY":
+		return "+OK\r\n"
+	case args[0] == "RANDOMKEY":
+		return bulk("key")
+	case args[0] == "TYPE":
+		return "+string\r\n"
+	case args[0] == "GET":
+		return bulk("value")
+	}
+	return "-ERR unexpected command " + args[0] + "\r\n"
+}
+
+func (c *fakeCluster) sent(addr, cmd string) bool {
+	c.Lock()
+	defer c.Unlock()
+	for _, sent := range c.commands[addr] {
+		if sent == cmd {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRunCluster(t *testing.T) {
+
+	cluster := &fakeCluster{commands: make(map[string][]string)}
+	results, keys, err := RunCluster(AggregatorFunc(AnyKey),
+		WithHost("10.0.0.1"), WithPort(7000), WithProxy(cluster), WithPreferReplica(),
+		WithAllowMaster(), WithMinSamples(5), WithLogger(&bufLogger{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the key counts of the masters, not the lagging replica, are summed
+	assertInt(t, 30, int(keys))
+	assertInt(t, 10, int(results[DefaultGroup].KeyCount))
+
+	// the first shard is sampled from its replica, and the second, which
+	// has none, from its master
+	if !cluster.sent("10.0.0.2:7001", "READONLY") || !cluster.sent("10.0.0.2:7001", "RANDOMKEY") {
+		t.Error("expected the replica to be sampled in READONLY mode")
+	}
+	if cluster.sent("10.0.0.1:7000", "RANDOMKEY") {
+		t.Error("expected the master of a shard with a replica not to be sampled")
+	}
+	if !cluster.sent("10.0.0.3:7002", "RANDOMKEY") || cluster.sent("10.0.0.3:7002", "READONLY") {
+		t.Error("expected the master of a shard without a replica to be sampled")
+	}
+
+	// without AllowMaster, the master of the shard without a replica is
+	// refused
+	cluster = &fakeCluster{commands: make(map[string][]string)}
+	_, _, err = RunCluster(AggregatorFunc(AnyKey),
+		WithHost("10.0.0.1"), WithPort(7000), WithProxy(cluster), WithPreferReplica(),
+		WithMinSamples(5), WithLogger(&bufLogger{}))
+	if err != ErrMaster {
+		t.Errorf("expected ErrMaster, actual: %v", err)
+	}
+}
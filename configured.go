@@ -0,0 +1,109 @@
+/*
+ * Copyright (C) 2015 zulily, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reckon
+
+import (
+	"net"
+	"strconv"
+	"sync"
+)
+
+// A Sampler runs sampling operations with a common set of default options,
+// e.g. the AuthProvider, Dialer and Logger shared by every redis instance
+// that a service samples, so that they need not be repeated for each call.
+// The package-level Run and Reckon funcs remain for one-off use.
+type Sampler struct {
+	defaults []func(*Options) error
+}
+
+// NewSampler returns a Sampler that applies each of `defaults`, in order,
+// before the funcs supplied to each of its methods
+func NewSampler(defaults ...func(*Options) error) *Sampler {
+	return &Sampler{defaults: defaults}
+}
+
+// Options returns the Options configured by the Sampler's defaults, followed
+// by `fns`, which take precedence (see NewOptions)
+func (s *Sampler) Options(fns ...func(*Options) error) (Options, error) {
+	all := make([]func(*Options) error, 0, len(s.defaults)+len(fns))
+	all = append(append(all, s.defaults...), fns...)
+	return NewOptions(all...)
+}
+
+// Run samples the redis instance configured by the Sampler's defaults and
+// `fns`, as the package-level Run func does
+func (s *Sampler) Run(aggregator Aggregator, fns ...func(*Options) error) (map[string]*Results, int64, error) {
+	opts, err := s.Options(fns...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return Run(opts, aggregator)
+}
+
+// RunMulti samples each of the redis instances at `addrs` (each a host:port
+// address) concurrently, configured by the Sampler's defaults and `fns`, and
+// merges their results with MergeConcurrent.  It returns the total key count
+// of all of the instances.  If sampling any instance fails, the first such
+// error is returned, and the results should be considered invalid.  The
+// Aggregator, and any Logger or other callbacks in the Options, must be safe
+// for concurrent use; a Summary should not be shared between the instances.
+func (s *Sampler) RunMulti(aggregator Aggregator, addrs []string, fns ...func(*Options) error) (map[string]*Results, int64, error) {
+	opts := make([]Options, len(addrs))
+	for i, addr := range addrs {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, 0, err
+		}
+		p, err := strconv.Atoi(port)
+		if err != nil {
+			return nil, 0, err
+		}
+		instance := append(append([]func(*Options) error{}, fns...), WithHost(host), WithPort(p))
+		if opts[i], err = s.Options(instance...); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return runConcurrently(opts, aggregator)
+}
+
+// runConcurrently runs a sampling operation for each of `opts` concurrently,
+// and merges their results with MergeConcurrent, returning the total key
+// count.  If any operation fails, the first such error is returned.
+func runConcurrently(opts []Options, aggregator Aggregator) (map[string]*Results, int64, error) {
+	results := make([]map[string]*Results, len(opts))
+	keys := make([]int64, len(opts))
+	errs := make([]error, len(opts))
+	var wg sync.WaitGroup
+	for i := range opts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], keys[i], errs[i] = Run(opts[i], aggregator)
+		}(i)
+	}
+	wg.Wait()
+
+	var total int64
+	for i, err := range errs {
+		if err != nil {
+			return nil, 0, err
+		}
+		total += keys[i]
+	}
+	return MergeConcurrent(results, len(results)), total, nil
+}
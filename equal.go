@@ -0,0 +1,106 @@
+/*
+ * Copyright (C) 2015 zulily, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reckon
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+)
+
+// Equal reports whether `r` and `other` hold the same statistics, comparing
+// every exported field, and if not, describes the first difference found,
+// e.g. `StringSizes[5]: 2 != 3`.  Fields are compared in declaration order,
+// and map entries in key order.  A missing map entry is equal to an entry
+// holding the zero value, so that e.g. a nil map equals an empty one, and a
+// set (a map[string]bool) equals one that records its non-members as false.
+// NaN floats are equal to each other.
+func (r *Results) Equal(other *Results) (bool, string) {
+	if r == nil || other == nil {
+		if r == other {
+			return true, ""
+		}
+		return false, "only one of the Results is nil"
+	}
+
+	a, b := reflect.ValueOf(r).Elem(), reflect.ValueOf(other).Elem()
+	for i := 0; i < a.NumField(); i++ {
+		field := a.Type().Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		if diff := difference(a.Field(i), b.Field(i)); diff != "" {
+			return false, field.Name + diff
+		}
+	}
+	return true, ""
+}
+
+// difference describes the first difference between `a` and `b`, which are
+// of the same type, or returns an empty string if they are equal
+func difference(a, b reflect.Value) string {
+	switch a.Kind() {
+	case reflect.Map:
+		for _, k := range mapKeys(a, b) {
+			va, vb := a.MapIndex(k), b.MapIndex(k)
+			if !va.IsValid() {
+				va = reflect.Zero(a.Type().Elem())
+			}
+			if !vb.IsValid() {
+				vb = reflect.Zero(b.Type().Elem())
+			}
+			if diff := difference(va, vb); diff != "" {
+				return fmt.Sprintf("[%v]%s", k, diff)
+			}
+		}
+		return ""
+	case reflect.Float32, reflect.Float64:
+		if fa, fb := a.Float(), b.Float(); fa == fb || math.IsNaN(fa) && math.IsNaN(fb) {
+			return ""
+		}
+	default:
+		if reflect.DeepEqual(a.Interface(), b.Interface()) {
+			return ""
+		}
+	}
+	return fmt.Sprintf(": %v != %v", a, b)
+}
+
+// mapKeys returns the union of the keys of the maps `a` and `b`, sorted
+func mapKeys(a, b reflect.Value) []reflect.Value {
+	var keys []reflect.Value
+	seen := make(map[interface{}]bool)
+	for _, m := range []reflect.Value{a, b} {
+		for _, k := range m.MapKeys() {
+			if !seen[k.Interface()] {
+				seen[k.Interface()] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		switch keys[i].Kind() {
+		case reflect.Int, reflect.Int64:
+			return keys[i].Int() < keys[j].Int()
+		case reflect.String:
+			return keys[i].String() < keys[j].String()
+		}
+		return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+	})
+	return keys
+}
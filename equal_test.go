@@ -0,0 +1,65 @@
+/*
+ * Copyright (C) 2015 zulily, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reckon
+
+import (
+	"math"
+	"testing"
+)
+
+// assertResults fails the test if the actual Results differ from those
+// expected, describing the first difference
+func assertResults(t *testing.T, expected, actual *Results) {
+	if ok, diff := expected.Equal(actual); !ok {
+		t.Errorf("unexpected results: %s", diff)
+	}
+}
+
+func TestResultsEqual(t *testing.T) {
+
+	a, b := sampleResults(), sampleResults()
+	assertResults(t, a, b)
+
+	// nil and empty maps, and non-members of sets, are equal
+	b.ListHeadSizes = nil
+	b.StringKeys["absent"] = false
+	a.UniformMaxCV, b.UniformMaxCV = math.NaN(), math.NaN()
+	assertResults(t, a, b)
+
+	for _, c := range []struct {
+		modify func(*Results)
+		diff   string
+	}{
+		{func(r *Results) { r.KeyCount++ }, "KeyCount: 6 != 7"},
+		{func(r *Results) { r.StringSizes[5]++ }, "StringSizes[5]: 2 != 3"},
+		{func(r *Results) { r.StringKeys["str3"] = true }, "StringKeys[str3]: false != true"},
+		{func(r *Results) { r.SetElementClassSizes["numeric"] = map[int]int64{1: 1} }, "SetElementClassSizes[numeric][1]: 0 != 1"},
+		{func(r *Results) { r.BucketKeys[TypeHash] = map[int]map[string]bool{8: {"hash1": true}} }, "BucketKeys[hash][8][hash1]: false != true"},
+	} {
+		b := sampleResults()
+		c.modify(b)
+		ok, diff := sampleResults().Equal(b)
+		if ok || diff != c.diff {
+			t.Errorf("expected difference %q, actual: %q", c.diff, diff)
+		}
+	}
+
+	var none *Results
+	if ok, _ := none.Equal(a); ok {
+		t.Error("expected nil Results not to equal non-nil Results")
+	}
+}
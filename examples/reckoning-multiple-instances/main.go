@@ -23,6 +23,7 @@ import (
 	"log"
 	"net"
 	"os"
+	"runtime"
 	"strconv"
 	"sync"
 
@@ -71,9 +72,10 @@ type reckonResult struct {
 }
 
 type options struct {
-	redises    Addresses
-	minSamples int
-	sampleRate float64
+	redises     Addresses
+	minSamples  int
+	sampleRate  float64
+	allowMaster bool
 }
 
 var (
@@ -84,6 +86,7 @@ func main() {
 
 	flag.Float64Var(&opts.sampleRate, "sample-rate", 0.1, "The percentage of the keyspace to sample on each redis")
 	flag.IntVar(&opts.minSamples, "min-samples", 100, "minimum number of keys to sample on each redis")
+	flag.BoolVar(&opts.allowMaster, "allow-master", false, "allow sampling redis instances whose replication role is master")
 	flag.Var(&opts.redises, "redis", "host:port address of a redis instance to sample (may be specified multiple times)")
 	flag.Parse()
 
@@ -91,7 +94,7 @@ func main() {
 	var reckonOpts []reckon.Options
 
 	for _, redis := range opts.redises {
-		opt := reckon.Options{Host: redis.Host, Port: redis.Port, MinSamples: opts.minSamples, SampleRate: float32(opts.sampleRate)}
+		opt := reckon.Options{Host: redis.Host, Port: redis.Port, MinSamples: opts.minSamples, SampleRate: float32(opts.sampleRate), AllowMaster: opts.allowMaster}
 		reckonOpts = append(reckonOpts, opt)
 	}
 
@@ -111,9 +114,10 @@ func main() {
 		}(instanceOpts)
 	}
 
-	// Collect and merge all the results
-	totals := make(map[string]*reckon.Results)
+	// Collect all the results
+	var all []map[string]*reckon.Results
 	totalKeyCount := int64(0)
+	done := make(chan struct{})
 
 	go func() {
 		for r := range results {
@@ -123,20 +127,17 @@ func main() {
 			log.Println("Got results back from a redis instance!")
 
 			totalKeyCount += r.keyCount
-			for k, v := range r.s {
-				if existing, ok := totals[k]; ok {
-					existing.Merge(v)
-					totals[k] = existing
-				} else {
-					totals[k] = v
-				}
-			}
+			all = append(all, r.s)
 		}
+		close(done)
 	}()
 
-	// render the final results to HTML when everything is complete
 	wg.Wait()
 	close(results)
+	<-done
+
+	// merge the results in parallel, and render the totals to HTML
+	totals := reckon.MergeConcurrent(all, runtime.NumCPU())
 
 	log.Printf("total key count: %d\n", totalKeyCount)
 	for k, v := range totals {
@@ -18,9 +18,7 @@ package main
 
 import (
 	"flag"
-	"fmt"
 	"log"
-	"os"
 	"strings"
 
 	"github.com/zulily/reckon"
@@ -30,6 +28,9 @@ import (
 
 // aggregateByFirst letter aggregates redis stats according the first letter of the redis key
 func aggregateByFirstLetter(key string, valueType reckon.ValueType) []string {
+	if key == "" {
+		return []string{}
+	}
 	return []string{key[:1]}
 }
 
@@ -46,11 +47,14 @@ func setsThatStartWithA(key string, valueType reckon.ValueType) []string {
 func main() {
 
 	var sampleRate float64
+	var outDir string
 	opts := reckon.Options{}
 	flag.StringVar(&opts.Host, "host", "localhost", "the hostname of the redis server")
 	flag.IntVar(&opts.Port, "port", 6379, "the port of the redis server")
 	flag.IntVar(&opts.MinSamples, "min-samples", 50, "number of random samples to take (should be <= the number of keys in the redis instance")
 	flag.Float64Var(&sampleRate, "sample-rate", 0.1, "The percentage of the keyspace to sample on each redis")
+	flag.BoolVar(&opts.AllowMaster, "allow-master", false, "allow sampling a redis instance whose replication role is master")
+	flag.StringVar(&outDir, "out", ".", "the directory to which reports are written")
 	flag.Parse()
 
 	opts.SampleRate = float32(sampleRate)
@@ -60,18 +64,8 @@ func main() {
 	}
 
 	log.Printf("total key count: %d\n", keyCount)
-	for k, v := range stats {
-		log.Printf("stats for: %s\n", k)
-
-		v.Name = k
-		if f, err := os.Create(fmt.Sprintf("output-%s.html", k)); err != nil {
-			panic(err)
-		} else {
-			defer f.Close()
-			log.Printf("Rendering totals for: '%s' to %s:\n", k, f.Name())
-			if err := reckon.RenderHTML(v, f); err != nil {
-				panic(err)
-			}
-		}
+	log.Printf("Rendering HTML and text reports for %d groups to %s\n", len(stats), outDir)
+	if err := reckon.RenderAll(stats, outDir); err != nil {
+		panic(err)
 	}
 }
@@ -0,0 +1,55 @@
+/*
+ * Copyright (C) 2015 zulily, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reckon
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ValidateGlob checks that `glob` is a well-formed redis glob-style pattern,
+// as used by the `KEYS` command: it must be non-empty, every `[` must be
+// closed by a matching `]`, and it must not end with an unescaped `\`.
+func ValidateGlob(glob string) error {
+	if glob == "" {
+		return errors.New("Glob cannot be empty")
+	}
+
+	for i := 0; i < len(glob); i++ {
+		switch glob[i] {
+		case '\\':
+			if i++; i == len(glob) {
+				return fmt.Errorf("invalid glob %q: trailing escape character", glob)
+			}
+		case '[':
+			start := i
+			i++
+			if i < len(glob) && glob[i] == '^' {
+				i++
+			}
+			for ; i < len(glob) && glob[i] != ']'; i++ {
+				if glob[i] == '\\' {
+					i++
+				}
+			}
+			if i >= len(glob) {
+				return fmt.Errorf("invalid glob %q: unclosed '[' at offset %d", glob, start)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+/*
+ * Copyright (C) 2015 zulily, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reckon
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateGlob(t *testing.T) {
+
+	for _, glob := range []string{"*", "user:*", "h?llo", "h[ae]llo", "h[^e]llo", "h[a-b]llo", `h\[llo`, `[\]]`, "[]"} {
+		if err := ValidateGlob(glob); err != nil {
+			t.Errorf("expected %q to be valid, actual: %v", glob, err)
+		}
+	}
+
+	for _, glob := range []string{"", "h[ello", "[^", `h\`, `[\]`, "a[b]c[d"} {
+		if err := ValidateGlob(glob); err == nil {
+			t.Errorf("expected %q to be invalid", glob)
+		}
+	}
+
+	if _, err := NewOptions(WithKeysCommand("user:[0-9")); err == nil || !strings.Contains(err.Error(), "unclosed") {
+		t.Errorf("expected WithKeysCommand to reject an unclosed '[', actual: %v", err)
+	}
+}
+
+func FuzzValidateGlob(f *testing.F) {
+	for _, seed := range []string{"*", "h[ae]llo", "h[ello", `h\`, `[\]]`} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, glob string) {
+		err := ValidateGlob(glob)
+		if err != nil && glob != "" && !strings.ContainsAny(glob, `[\`) {
+			t.Errorf("expected %q, with no brackets or escapes, to be valid: %v", glob, err)
+		}
+
+		// escaping every byte of any glob yields a valid, literal glob
+		var escaped strings.Builder
+		for i := 0; i < len(glob); i++ {
+			escaped.WriteByte('\\')
+			escaped.WriteByte(glob[i])
+		}
+		if glob != "" {
+			if err := ValidateGlob(escaped.String()); err != nil {
+				t.Errorf("expected escaped %q to be valid: %v", glob, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,101 @@
+/*
+ * Copyright (C) 2015 zulily, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reckon
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// A KeySource supplies the keys to be observed, in place of random sampling.
+// See the `KeySource` field of Options.
+type KeySource interface {
+	// Next returns the next key, or false once the source is exhausted
+	Next() (key string, ok bool, err error)
+
+	// Len returns the number of keys the source will supply, or false if that
+	// is not known in advance (e.g. for a live stream of keys).  It is
+	// consulted only for progress reporting.
+	Len() (int64, bool)
+}
+
+// keyList is a KeySource that supplies a fixed list of keys
+type keyList struct {
+	keys []string
+	next int
+}
+
+// NewKeyList returns a KeySource that supplies each of `keys`, in order
+func NewKeyList(keys []string) KeySource {
+	return &keyList{keys: keys}
+}
+
+func (l *keyList) Next() (string, bool, error) {
+	if l.next >= len(l.keys) {
+		return "", false, nil
+	}
+	l.next++
+	return l.keys[l.next-1], true, nil
+}
+
+func (l *keyList) Len() (int64, bool) {
+	return int64(len(l.keys)), true
+}
+
+// ReadKeyList returns a KeySource that supplies the keys read from `r`, one
+// per line.  Blank lines are ignored.  `r` is read in its entirety up front, so
+// that the KeySource knows its Len.
+func ReadKeyList(r io.Reader) (KeySource, error) {
+	var keys []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if key := strings.TrimRight(scanner.Text(), "\r"); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return NewKeyList(keys), nil
+}
+
+// ReadSampleLog returns a KeySource that supplies the keys recorded in a
+// sample log written via the `SampleLog` field of Options, so that the same
+// keys can be observed again.  Keys are supplied in the order in which they
+// were observed, including any repeats.
+func ReadSampleLog(r io.Reader) (KeySource, error) {
+	var keys []string
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.SplitN(scanner.Text(), "\t", 2)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("malformed sample log line %d: %q", line, scanner.Text())
+		}
+		key, err := strconv.Unquote(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("malformed key on sample log line %d: %s", line, err)
+		}
+		keys = append(keys, key)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return NewKeyList(keys), nil
+}
@@ -0,0 +1,184 @@
+/*
+ * Copyright (C) 2015 zulily, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reckon
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// keyStream is a KeySource that cannot report its Len
+type keyStream struct {
+	keys []string
+}
+
+func (s *keyStream) Next() (string, bool, error) {
+	if len(s.keys) == 0 {
+		return "", false, nil
+	}
+	key := s.keys[0]
+	s.keys = s.keys[1:]
+	return key, true, nil
+}
+
+func (s *keyStream) Len() (int64, bool) { return 0, false }
+
+func TestReadKeyList(t *testing.T) {
+
+	src, err := ReadKeyList(strings.NewReader("a\r\n\nb\nc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, ok := src.Len()
+	if !ok {
+		t.Fatal("expected a key list to know its Len")
+	}
+	assertInt(t, 3, int(n))
+
+	var keys []string
+	for {
+		key, ok, err := src.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			break
+		}
+		keys = append(keys, key)
+	}
+	if fmt.Sprint(keys) != "[a b c]" {
+		t.Errorf("expected [a b c], actual: %v", keys)
+	}
+}
+
+func TestObserveSource(t *testing.T) {
+
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		switch cmd {
+		case "TYPE":
+			return "string", nil
+		case "GET":
+			return []byte("value"), nil
+		}
+		return nil, fmt.Errorf("unexpected command: %s", cmd)
+	}}
+
+	for _, tc := range []struct {
+		src     KeySource
+		planned int
+	}{
+		{NewKeyList([]string{"a", "b", "c"}), 3},
+		{&keyStream{keys: []string{"a", "b", "c"}}, 0},
+	} {
+		var reports []Progress
+		s := newSampler(Options{
+			KeySource:        tc.src,
+			Logger:           &bufLogger{},
+			ProgressInterval: 1,
+			Progress:         func(p Progress) { reports = append(reports, p) },
+		}, AggregatorFunc(AnyKey))
+		s.conn = conn
+		if err := s.observeSource(tc.src); err != nil {
+			t.Fatal(err)
+		}
+
+		assertInt(t, 3, int(s.stats[DefaultGroup].KeyCount))
+		assertInt(t, 3, len(reports))
+		last := reports[len(reports)-1]
+		if !last.Done || last.Sampled != 3 || last.Planned != tc.planned {
+			t.Errorf("expected a final report of 3/%d keys, actual: %+v", tc.planned, last)
+		}
+	}
+}
+
+func TestShards(t *testing.T) {
+
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		switch cmd {
+		case "TYPE":
+			return "string", nil
+		case "GET":
+			return []byte("value"), nil
+		}
+		return nil, fmt.Errorf("unexpected command: %s", cmd)
+	}}
+
+	var keys []string
+	for i := 0; i < 30; i++ {
+		keys = append(keys, fmt.Sprintf("key:%d", i))
+	}
+
+	merged := NewResults()
+	for id := 0; id < 3; id++ {
+		s := newSampler(Options{ShardID: id, ShardCount: 3, Logger: &bufLogger{}}, AggregatorFunc(AnyKey))
+		s.conn = conn
+		if err := s.observeSource(NewKeyList(keys)); err != nil {
+			t.Fatal(err)
+		}
+		r := s.stats[DefaultGroup]
+		if r == nil || r.KeyCount == 30 {
+			t.Fatalf("expected shard %d to observe some, but not all, keys", id)
+		}
+		merged.Merge(r)
+	}
+	assertInt(t, 30, int(merged.KeyCount))
+
+	if _, err := NewOptions(WithShard(3, 3)); err == nil {
+		t.Error("expected an error for a ShardID out of range")
+	}
+}
+
+func TestSampleLog(t *testing.T) {
+
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		if cmd == "GET" {
+			return []byte("value"), nil
+		}
+		return nil, fmt.Errorf("unexpected command: %s", cmd)
+	}}
+
+	var log bytes.Buffer
+	s := newSampler(Options{SampleLog: &log}, AggregatorFunc(AnyKey))
+	s.conn = conn
+	for _, key := range []string{"usr:1", "tab\tand\nnewline"} {
+		if err := s.observe(key, TypeString); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.observe("gone", TypeNone); err != nil {
+		t.Fatal(err)
+	}
+	if log.String() != "string\t\"usr:1\"\nstring\t\"tab\\tand\\nnewline\"\n" {
+		t.Errorf("unexpected sample log: %q", log.String())
+	}
+
+	src, err := ReadSampleLog(&log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _, _ := src.Next()
+	second, _, _ := src.Next()
+	if first != "usr:1" || second != "tab\tand\nnewline" {
+		t.Errorf("unexpected keys replayed: %q, %q", first, second)
+	}
+
+	if _, err := ReadSampleLog(strings.NewReader("string usr:1\n")); err == nil {
+		t.Error("expected an error for a malformed line")
+	}
+}
@@ -0,0 +1,77 @@
+/*
+ * Copyright (C) 2015 zulily, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reckon
+
+import "sync"
+
+// mergeGroups merges every group in `b` into `a`.  Groups not already present
+// in `a` are adopted from `b`, rather than copied.
+func mergeGroups(a, b map[string]*Results) {
+	for g, r := range b {
+		if existing, ok := a[g]; ok {
+			existing.Merge(r)
+		} else {
+			a[g] = r
+		}
+	}
+}
+
+// MergeConcurrent merges a slice of per-group results (e.g. as returned by Run
+// for each of several redis instances) into a single map of per-group results.
+// Rather than merging serially, the maps are merged pairwise in a tree, with
+// up to `parallelism` pairs merged concurrently at each level of the tree, so
+// that the merge completes in O(log n) rounds.  Every pair at a given level
+// involves distinct maps, so no Results is ever merged by two goroutines at
+// once.
+//
+// Like Merge, MergeConcurrent works in place: the maps in `results`, and the
+// Results they contain, are merged into one another, and should not be used
+// once MergeConcurrent returns.
+func MergeConcurrent(results []map[string]*Results, parallelism int) map[string]*Results {
+	if len(results) == 0 {
+		return make(map[string]*Results)
+	}
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	maps := make([]map[string]*Results, len(results))
+	copy(maps, results)
+	sem := make(chan struct{}, parallelism)
+
+	for len(maps) > 1 {
+		var wg sync.WaitGroup
+		for i := 0; i+1 < len(maps); i += 2 {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(a, b map[string]*Results) {
+				defer wg.Done()
+				mergeGroups(a, b)
+				<-sem
+			}(maps[i], maps[i+1])
+		}
+		wg.Wait()
+
+		// keep the merged maps (and any odd one out) for the next round
+		next := maps[:0]
+		for i := 0; i < len(maps); i += 2 {
+			next = append(next, maps[i])
+		}
+		maps = next
+	}
+	return maps[0]
+}
@@ -0,0 +1,61 @@
+/*
+ * Copyright (C) 2015 zulily, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reckon
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMergeConcurrent(t *testing.T) {
+
+	var results []map[string]*Results
+	for i := 0; i < 11; i++ {
+		a, b := NewResults(), NewResults()
+		a.observeString(fmt.Sprintf("a%d", i), "value", "value")
+		b.observeSet(fmt.Sprintf("b%d", i), i, []string{"member"})
+		m := map[string]*Results{"a": a, "b": b}
+		if i%2 == 0 {
+			c := NewResults()
+			c.observeList(fmt.Sprintf("c%d", i), 2, []string{"elem"})
+			m["c"] = c
+		}
+		results = append(results, m)
+	}
+
+	// the concurrent merge matches a sequential one
+	expected := make(map[string]*Results)
+	for _, m := range results {
+		for group, r := range m {
+			ensureEntry(expected, group, NewResults).Merge(r)
+		}
+	}
+
+	merged := MergeConcurrent(results, 3)
+	for group, r := range expected {
+		assertResults(t, r, merged[group])
+	}
+
+	assertInt(t, 3, len(merged))
+	assertInt(t, 11, int(merged["a"].KeyCount))
+	assertInt(t, 11, int(merged["a"].StringSizes[5]))
+	assertInt(t, 11, int(merged["b"].KeyCount))
+	assertInt(t, 11, len(merged["b"].SetSizes))
+	assertInt(t, 6, int(merged["c"].KeyCount))
+
+	assertInt(t, 0, len(MergeConcurrent(nil, 4)))
+}
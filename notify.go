@@ -0,0 +1,191 @@
+/*
+ * Copyright (C) 2015 zulily, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reckon
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// keyEventPattern is the channel pattern subscribed to by RunNotifications,
+// matching every keyevent notification for database 0
+const keyEventPattern = "__keyevent@0__:*"
+
+// removalEvents are the keyevent notifications that announce the removal of
+// a key, which therefore cannot be observed
+var removalEvents = map[string]bool{
+	"del":         true,
+	"expired":     true,
+	"evicted":     true,
+	"rename_from": true,
+	"move_from":   true,
+}
+
+// A keyEvent is a keyevent notification: the name of the event, e.g. "hset",
+// and the key that it affected
+type keyEvent struct {
+	event string
+	key   string
+}
+
+// RunNotifications observes the keys that are written to the redis instance
+// configured by `fns` (see NewOptions) for `duration`, or until `ctx` is
+// done, aggregating them with the provided Aggregator.  Rather than sampling
+// a snapshot of the keyspace, it characterizes the flow of writes: each key
+// touched by the keyevent notifications received during the window is
+// observed once, by `TYPE` and the same commands used by Run, and keys that
+// are never written during the window are not seen at all.  TotalKeys in
+// the results of each group is the number of distinct keys of the group
+// that were touched.
+//
+// Keyspace notifications are disabled by default, so `notify-keyspace-events`
+// must be configured on the redis instance to publish keyevent notifications
+// for the types of interest, e.g. `CONFIG SET notify-keyspace-events E$lshz`.
+// If `CONFIG GET` shows that they are not published, an error is returned.
+//
+// If `ctx` is done before `duration` has elapsed, the keys observed so far
+// are returned along with the context's error.
+func RunNotifications(ctx context.Context, aggregator Aggregator, duration time.Duration, fns ...func(*Options) error) (map[string]*Results, error) {
+	opts, err := NewOptions(fns...)
+	if err != nil {
+		return nil, err
+	}
+
+	s := newSampler(opts, aggregator)
+	err = s.runNotifications(ctx, duration)
+	s.estimatePopulations(s.sampled())
+	return s.stats, err
+}
+
+// runNotifications connects to the configured redis instance and observes
+// the keys named by keyevent notifications for `duration`
+func (s *sampler) runNotifications(ctx context.Context, duration time.Duration) (err error) {
+	start := time.Now()
+	defer func() { s.summarize(s.sampled(), time.Since(start)) }()
+
+	conn, err := dial(s.opts)
+	if err != nil {
+		return err
+	}
+	s.counter = &countingConn{Conn: conn}
+	s.conn = s.counter
+	defer s.conn.Close()
+
+	if !s.opts.AllowMaster {
+		role, err := replicationRole(s.conn)
+		if err != nil {
+			return err
+		}
+		if role == "master" {
+			return ErrMaster
+		}
+	}
+	if err = checkKeyEvents(s.conn); err != nil {
+		return err
+	}
+
+	sub, err := dial(s.opts)
+	if err != nil {
+		return err
+	}
+	psc := redis.PubSubConn{Conn: sub}
+	if err = psc.PSubscribe(keyEventPattern); err != nil {
+		sub.Close()
+		return err
+	}
+
+	events := make(chan keyEvent)
+	errs := make(chan error, 1)
+	done := make(chan struct{})
+	go receiveKeyEvents(psc, events, errs, done)
+	defer func() {
+		close(done)
+		// closing the subscription unblocks receiveKeyEvents
+		sub.Close()
+	}()
+
+	window := time.NewTimer(duration)
+	defer window.Stop()
+	s.opts.Logger.Printf("observing keyevent notifications from redis at: %s:%d for %s\n", s.opts.Host, s.opts.Port, duration)
+	return s.observeKeyEvents(ctx, events, errs, window.C)
+}
+
+// checkKeyEvents returns an error if the redis instance is configured not
+// to publish keyevent notifications for any of the sampled types
+func checkKeyEvents(conn redis.Conn) error {
+	reply, err := redis.Strings(conn.Do("CONFIG", "GET", "notify-keyspace-events"))
+	if err != nil || len(reply) < 2 {
+		// CONFIG may be disabled or renamed; assume notifications are enabled
+		return nil
+	}
+	if !strings.Contains(reply[1], "E") || !strings.ContainsAny(reply[1], "A$lshz") {
+		return errors.New("keyevent notifications are not enabled on the redis instance; set notify-keyspace-events, e.g. to E$lshz")
+	}
+	return nil
+}
+
+// receiveKeyEvents sends each keyevent notification received on `psc` to
+// `events`, until `done` is closed.  If receiving fails, e.g. because the
+// subscription was closed, the error is sent to `errs`.
+func receiveKeyEvents(psc redis.PubSubConn, events chan<- keyEvent, errs chan<- error, done <-chan struct{}) {
+	for {
+		switch v := psc.Receive().(type) {
+		case redis.PMessage:
+			event := keyEvent{event: v.Channel[strings.LastIndex(v.Channel, ":")+1:], key: string(v.Data)}
+			select {
+			case events <- event:
+			case <-done:
+				return
+			}
+		case error:
+			errs <- v
+			return
+		}
+	}
+}
+
+// observeKeyEvents observes the key named by each of `events`, once per
+// distinct key, until `window` fires or `ctx` is done
+func (s *sampler) observeKeyEvents(ctx context.Context, events <-chan keyEvent, errs <-chan error, window <-chan time.Time) error {
+	seen := make(map[string]bool)
+	for {
+		select {
+		case <-window:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-errs:
+			return err
+		case e := <-events:
+			if removalEvents[e.event] || seen[e.key] {
+				continue
+			}
+			seen[e.key] = true
+			vt, err := keyType(s.conn, e.key)
+			if err != nil {
+				return err
+			}
+			if err = s.observe(e.key, vt); err != nil {
+				return err
+			}
+		}
+	}
+}
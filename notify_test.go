@@ -0,0 +1,73 @@
+/*
+ * Copyright (C) 2015 zulily, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reckon
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestObserveKeyEvents(t *testing.T) {
+
+	types := map[string]string{"a": "string", "b": "string", "gone": "none"}
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		switch cmd {
+		case "TYPE":
+			return types[args[0].(string)], nil
+		case "GET":
+			return []byte("value"), nil
+		}
+		return nil, fmt.Errorf("unexpected command: %s", cmd)
+	}}
+
+	s := newSampler(Options{}, AggregatorFunc(AnyKey))
+	s.conn = conn
+
+	events := make(chan keyEvent)
+	window := make(chan time.Time)
+	go func() {
+		for _, e := range []keyEvent{{"set", "a"}, {"incr", "b"}, {"del", "x"}, {"append", "a"}, {"set", "gone"}} {
+			events <- e
+		}
+		window <- time.Now()
+	}()
+	if err := s.observeKeyEvents(context.Background(), events, nil, window); err != nil {
+		t.Fatal(err)
+	}
+	assertInt(t, 2, int(s.typeCounts[TypeString]))
+	assertInt(t, 1, int(s.vanished))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := s.observeKeyEvents(ctx, nil, nil, nil); err != context.Canceled {
+		t.Errorf("expected the context's error, actual: %v", err)
+	}
+}
+
+func TestCheckKeyEvents(t *testing.T) {
+
+	for setting, ok := range map[string]bool{"": false, "Kg": false, "Eg": false, "E$lshz": true, "KEA": true} {
+		conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+			return []interface{}{[]byte("notify-keyspace-events"), []byte(setting)}, nil
+		}}
+		if err := checkKeyEvents(conn); (err == nil) != ok {
+			t.Errorf("notify-keyspace-events %q: unexpected error: %v", setting, err)
+		}
+	}
+}
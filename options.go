@@ -0,0 +1,650 @@
+/*
+ * Copyright (C) 2015 zulily, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reckon
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// NewOptions constructs an Options struct by applying each of the supplied
+// funcs, in order, to a default configuration that targets a redis instance
+// listening on localhost:6379.  If any of the funcs returns an error,
+// NewOptions stops and returns that error.
+func NewOptions(fns ...func(*Options) error) (Options, error) {
+	opts := Options{
+		Host: "localhost",
+		Port: 6379,
+	}
+	for _, fn := range fns {
+		if err := fn(&opts); err != nil {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
+// WithHost sets the hostname of the redis instance to sample
+func WithHost(host string) func(*Options) error {
+	return func(opts *Options) error {
+		opts.Host = host
+		return nil
+	}
+}
+
+// WithPort sets the port of the redis instance to sample
+func WithPort(port int) func(*Options) error {
+	return func(opts *Options) error {
+		if port <= 0 || port > 65535 {
+			return errors.New("Port must be between 1 and 65535")
+		}
+		opts.Port = port
+		return nil
+	}
+}
+
+// WithMinSamples sets the minimum number of random keys to sample
+func WithMinSamples(n int) func(*Options) error {
+	return func(opts *Options) error {
+		opts.MinSamples = n
+		return nil
+	}
+}
+
+// WithSampleRate sets the percentage of the keyspace to sample
+func WithSampleRate(rate float32) func(*Options) error {
+	return func(opts *Options) error {
+		if rate < 0.0 || rate > 1.0 {
+			return errors.New("SampleRate must be between 0.0 and 1.0")
+		}
+		opts.SampleRate = rate
+		return nil
+	}
+}
+
+// WithKeysCommand analyzes every key matching `glob`, using a single `KEYS`
+// command, instead of sampling random keys.  `KEYS` blocks redis, so this
+// must only be used with small instances; see the `KeysGlob` field of
+// Options.
+func WithKeysCommand(glob string) func(*Options) error {
+	return func(opts *Options) error {
+		if err := ValidateGlob(glob); err != nil {
+			return err
+		}
+		opts.KeysGlob = glob
+		return nil
+	}
+}
+
+// WithMemoryBudget caps the total bytes of retained examples at `bytes`.  See
+// the `MemoryBudget` field of Options.
+func WithMemoryBudget(bytes int) func(*Options) error {
+	return func(opts *Options) error {
+		if bytes < 1 {
+			return errors.New("MemoryBudget must be at least 1")
+		}
+		opts.MemoryBudget = bytes
+		return nil
+	}
+}
+
+// WithSampleLog writes a line to `w` for each observed key.  See the
+// `SampleLog` field of Options.
+func WithSampleLog(w io.Writer) func(*Options) error {
+	return func(opts *Options) error {
+		if w == nil {
+			return errors.New("SampleLog cannot be nil")
+		}
+		opts.SampleLog = w
+		return nil
+	}
+}
+
+// WithKeySource analyzes every key supplied by `src`, instead of sampling
+// random keys.  See the `KeySource` field of Options.
+func WithKeySource(src KeySource) func(*Options) error {
+	return func(opts *Options) error {
+		if src == nil {
+			return errors.New("KeySource cannot be nil")
+		}
+		opts.KeySource = src
+		return nil
+	}
+}
+
+// WithShard observes only the keys of shard `id` of `total`, so that `total`
+// processes can examine the keys returned by `KEYS` (or a KeySource) in
+// parallel.  See the `ShardID` field of Options.
+func WithShard(id, total int) func(*Options) error {
+	return func(opts *Options) error {
+		if total < 1 {
+			return errors.New("ShardCount must be at least 1")
+		}
+		if id < 0 || id >= total {
+			return errors.New("ShardID must be between 0 and ShardCount-1")
+		}
+		opts.ShardID, opts.ShardCount = id, total
+		return nil
+	}
+}
+
+// WithHashSlot restricts the analysis to the keys in redis cluster hash slot
+// `slot`.  It may be given more than once, to analyze several slots.  See the
+// `HashSlots` field of Options.
+func WithHashSlot(slot int) func(*Options) error {
+	return func(opts *Options) error {
+		if slot < 0 || slot >= HashSlotCount {
+			return fmt.Errorf("HashSlot must be between 0 and %d", HashSlotCount-1)
+		}
+		opts.HashSlots = append(opts.HashSlots, slot)
+		return nil
+	}
+}
+
+// WithScanLimit analyzes a slice of the keyspace of at least `maxKeys` keys,
+// walked with `SCAN` from `cursor` (0 to begin a new scan).  See the
+// `ScanLimit` field of Options.
+func WithScanLimit(maxKeys int, cursor uint64) func(*Options) error {
+	return func(opts *Options) error {
+		if maxKeys < 1 {
+			return errors.New("ScanLimit must be at least 1")
+		}
+		opts.ScanLimit, opts.ScanCursor = maxKeys, cursor
+		return nil
+	}
+}
+
+// WithMaxFetchBytes prevents values longer than `n` bytes from being fetched.
+// See the `MaxFetchBytes` field of Options.
+func WithMaxFetchBytes(n int) func(*Options) error {
+	return func(opts *Options) error {
+		if n < 1 {
+			return errors.New("MaxFetchBytes must be at least 1")
+		}
+		opts.MaxFetchBytes = n
+		return nil
+	}
+}
+
+// WithStringExampleBytes limits captured example string values to their
+// first `n` bytes
+func WithStringExampleBytes(n int) func(*Options) error {
+	return func(opts *Options) error {
+		opts.StringExampleBytes = n
+		return nil
+	}
+}
+
+// WithMergedGroups instructs Reckon to merge the results of every
+// aggregation group into a single report, rather than rendering one report
+// per group
+func WithMergedGroups() func(*Options) error {
+	return func(opts *Options) error {
+		opts.MergeGroups = true
+		return nil
+	}
+}
+
+// WithTargetPrecision samples keys until the mean value size is known to
+// within ±relError (e.g. 0.05) at the given confidence level (e.g. 0.95).
+// See the `TargetRelError` field of Options.
+func WithTargetPrecision(relError, confidence float64) func(*Options) error {
+	return func(opts *Options) error {
+		if relError <= 0.0 {
+			return errors.New("TargetRelError must be greater than 0.0")
+		}
+		if confidence <= 0.0 || confidence >= 1.0 {
+			return errors.New("TargetConfidence must be between 0.0 and 1.0")
+		}
+		opts.TargetRelError = relError
+		opts.TargetConfidence = confidence
+		return nil
+	}
+}
+
+// WithMaxSamples caps the number of keys sampled to a target precision.  See
+// the `MaxSamples` field of Options.
+func WithMaxSamples(n int) func(*Options) error {
+	return func(opts *Options) error {
+		if n < 1 {
+			return errors.New("MaxSamples must be at least 1")
+		}
+		opts.MaxSamples = n
+		return nil
+	}
+}
+
+// WithBurnIn samples and discards `n` random keys before sampling begins.
+// See the `BurnIn` field of Options.
+func WithBurnIn(n int) func(*Options) error {
+	return func(opts *Options) error {
+		if n < 0 {
+			return errors.New("BurnIn cannot be negative")
+		}
+		opts.BurnIn = n
+		return nil
+	}
+}
+
+// WithStratifiedSampling ensures that at least `perType` keys of every
+// ValueType present in the redis instance are observed.  See the
+// `StratifiedPerType` field of Options.
+func WithStratifiedSampling(perType int) func(*Options) error {
+	return func(opts *Options) error {
+		if perType < 0 {
+			return errors.New("perType cannot be negative")
+		}
+		opts.StratifiedPerType = perType
+		return nil
+	}
+}
+
+// WithAllocation divides the keys to be sampled among the ValueTypes
+// according to `allocation`.  See the `Allocation` field of Options.
+func WithAllocation(allocation Allocation) func(*Options) error {
+	return func(opts *Options) error {
+		if allocation < NoAllocation || allocation > EqualAllocation {
+			return fmt.Errorf("unknown Allocation: %d", allocation)
+		}
+		opts.Allocation = allocation
+		return nil
+	}
+}
+
+// WithGrowthProbe measures the rate at which the key count changes over at
+// least `interval`.  See the `GrowthProbe` field of Options.
+func WithGrowthProbe(interval time.Duration) func(*Options) error {
+	return func(opts *Options) error {
+		if interval <= 0 {
+			return errors.New("GrowthProbe interval must be positive")
+		}
+		opts.GrowthProbe = interval
+		return nil
+	}
+}
+
+// WithRunSummary instructs Run to populate `summary` with operational details
+// about the sampling operation
+func WithRunSummary(summary *RunSummary) func(*Options) error {
+	return func(opts *Options) error {
+		opts.Summary = summary
+		return nil
+	}
+}
+
+// WithPerTypeBudget caps the number of keys of each ValueType that are
+// observed.  See the `PerTypeBudget` field of Options.
+func WithPerTypeBudget(budget map[ValueType]int) func(*Options) error {
+	return func(opts *Options) error {
+		for vt, n := range budget {
+			if n < 0 {
+				return fmt.Errorf("budget for type %s cannot be negative", vt)
+			}
+		}
+		opts.PerTypeBudget = budget
+		return nil
+	}
+}
+
+// WithSizeOnly instructs Run to gather size distributions without fetching
+// any values.  See the `SizeOnly` field of Options.
+func WithSizeOnly() func(*Options) error {
+	return func(opts *Options) error {
+		opts.SizeOnly = true
+		return nil
+	}
+}
+
+// WithOtherTypes counts keys of types that reckon does not sample, instead of
+// failing on them.  See the `OtherTypes` field of Options.
+func WithOtherTypes() func(*Options) error {
+	return func(opts *Options) error {
+		opts.OtherTypes = true
+		return nil
+	}
+}
+
+// WithUniformElements declares that the elements of collections are expected
+// to be `size` bytes long, flagging element sizes whose coefficient of
+// variation exceeds `maxCV`.  See the `UniformElementSize` field of Options.
+func WithUniformElements(size int, maxCV float64) func(*Options) error {
+	return func(opts *Options) error {
+		if size < 1 {
+			return errors.New("UniformElementSize must be at least 1")
+		}
+		if maxCV <= 0.0 {
+			return errors.New("UniformMaxCV must be greater than 0.0")
+		}
+		opts.UniformElementSize, opts.UniformMaxCV = size, maxCV
+		return nil
+	}
+}
+
+// WithVerbosity sets the level of detail of the messages logged during
+// sampling.  See Verbosity.
+func WithVerbosity(level Verbosity) func(*Options) error {
+	return func(opts *Options) error {
+		if level < VerbosityProgress || level > VerbosityDebug {
+			return fmt.Errorf("unknown Verbosity: %d", level)
+		}
+		opts.Verbosity = level
+		return nil
+	}
+}
+
+// WithLogger routes the progress messages emitted during sampling to `logger`
+func WithLogger(logger Logger) func(*Options) error {
+	return func(opts *Options) error {
+		opts.Logger = logger
+		return nil
+	}
+}
+
+// WithTracer traces each sampling operation with `tracer`.  See the `Tracer`
+// field of Options.
+func WithTracer(tracer Tracer) func(*Options) error {
+	return func(opts *Options) error {
+		if tracer == nil {
+			return errors.New("Tracer cannot be nil")
+		}
+		opts.Tracer = tracer
+		return nil
+	}
+}
+
+// WithProgress calls `fn` periodically to report the progress of sampling.
+// See the `Progress` field of Options.
+func WithProgress(fn func(Progress)) func(*Options) error {
+	return func(opts *Options) error {
+		opts.Progress = fn
+		return nil
+	}
+}
+
+// WithProgressInterval reports progress every `n` sampled keys.  See the
+// `ProgressInterval` field of Options.
+func WithProgressInterval(n int) func(*Options) error {
+	return func(opts *Options) error {
+		if n < 1 {
+			return errors.New("ProgressInterval must be at least 1")
+		}
+		opts.ProgressInterval = n
+		return nil
+	}
+}
+
+// WithVerbose logs every observed key, its type and its assigned groups to
+// `logger`
+func WithVerbose(logger Logger) func(*Options) error {
+	return func(opts *Options) error {
+		opts.Verbose = logger
+		return nil
+	}
+}
+
+// WithAccessFreq records the distribution of `OBJECT FREQ` access frequencies
+// of the sampled keys.  See the `AccessFreq` field of Options.
+func WithAccessFreq() func(*Options) error {
+	return func(opts *Options) error {
+		opts.AccessFreq = true
+		return nil
+	}
+}
+
+// WithMaxIdleTime skips sampled keys that have been idle for more than
+// `seconds` seconds.  See the `MaxIdleTime` field of Options.
+func WithMaxIdleTime(seconds int) func(*Options) error {
+	return func(opts *Options) error {
+		if seconds < 1 {
+			return errors.New("MaxIdleTime must be at least 1 second")
+		}
+		opts.MaxIdleTime = seconds
+		return nil
+	}
+}
+
+// WithBitmapStats enables or disables recording the distribution of
+// `BITCOUNT` set-bit counts of sampled strings.  See the `BitmapStats` field
+// of Options.
+func WithBitmapStats(enabled bool) func(*Options) error {
+	return func(opts *Options) error {
+		opts.BitmapStats = enabled
+		return nil
+	}
+}
+
+// WithHashFieldAggregator records a separate value size distribution for each
+// class of hash field returned by `fa`.  See the `HashFieldAggregator` field
+// of Options.
+func WithHashFieldAggregator(fa FieldAggregator) func(*Options) error {
+	return func(opts *Options) error {
+		opts.HashFieldAggregator = fa
+		return nil
+	}
+}
+
+// WithApproxHistogram enables or disables recording sizes in bounded,
+// approximate histograms.  See the `ApproxHistogram` field of Options.
+func WithApproxHistogram(enabled bool) func(*Options) error {
+	return func(opts *Options) error {
+		opts.ApproxHistogram = enabled
+		return nil
+	}
+}
+
+// WithByteEstimates records the distribution of the estimated size in bytes
+// of every sampled collection.  See the `ByteEstimates` field of Options.
+func WithByteEstimates() func(*Options) error {
+	return func(opts *Options) error {
+		opts.ByteEstimates = true
+		return nil
+	}
+}
+
+// WithHashByteEstimates records the distribution of the estimated size in
+// bytes of sampled hashes.  See the `HashByteEstimates` field of Options.
+func WithHashByteEstimates() func(*Options) error {
+	return func(opts *Options) error {
+		opts.HashByteEstimates = true
+		return nil
+	}
+}
+
+// WithListEnds records the distributions of the sizes of the head and tail
+// elements of sampled lists.  See the `ListEnds` field of Options.
+func WithListEnds() func(*Options) error {
+	return func(opts *Options) error {
+		opts.ListEnds = true
+		return nil
+	}
+}
+
+// WithElementAggregator records a separate element size distribution for
+// each class of set, sorted set and list element returned by `ea`.  See the
+// `ElementAggregator` field of Options.
+func WithElementAggregator(ea ElementAggregator) func(*Options) error {
+	return func(opts *Options) error {
+		opts.ElementAggregator = ea
+		return nil
+	}
+}
+
+// WithKeyTransform normalizes each sampled key with `transform` before it is
+// aggregated and recorded as an example.  See the `KeyTransform` field of
+// Options.
+func WithKeyTransform(transform func(key string) string) func(*Options) error {
+	return func(opts *Options) error {
+		opts.KeyTransform = transform
+		return nil
+	}
+}
+
+// WithExpectedPatterns audits the sampled keys against the regular
+// expressions `patterns`, reporting the keys that match none of them.  See
+// the `ExpectedPatterns` field of Options.
+func WithExpectedPatterns(patterns ...string) func(*Options) error {
+	return func(opts *Options) error {
+		for _, p := range patterns {
+			re, err := regexp.Compile(p)
+			if err != nil {
+				return fmt.Errorf("invalid expected pattern %q: %s", p, err.Error())
+			}
+			opts.ExpectedPatterns = append(opts.ExpectedPatterns, re)
+		}
+		return nil
+	}
+}
+
+// WithElementsPerKey sets the number of elements sampled from each
+// collection.  See the `ElementsPerKey` field of Options.
+func WithElementsPerKey(n int) func(*Options) error {
+	return func(opts *Options) error {
+		if n < 1 {
+			return errors.New("ElementsPerKey must be at least 1")
+		}
+		opts.ElementsPerKey = n
+		return nil
+	}
+}
+
+// WithKeyMemory records the distribution of the `MEMORY USAGE` of sampled
+// keys.  See the `KeyMemory` field of Options.
+func WithKeyMemory() func(*Options) error {
+	return func(opts *Options) error {
+		opts.KeyMemory = true
+		return nil
+	}
+}
+
+// WithElementSizeFromObject records the element sizes of collections from
+// their `MEMORY USAGE`.  See the `ElementSizeFromObject` field of Options.
+func WithElementSizeFromObject() func(*Options) error {
+	return func(opts *Options) error {
+		opts.ElementSizeFromObject = true
+		return nil
+	}
+}
+
+// WithBucketExamples retains up to `n` example keys of each type for each
+// power-of-two size bucket.  See the `BucketExamples` field of Options.
+func WithBucketExamples(n int) func(*Options) error {
+	return func(opts *Options) error {
+		if n < 1 {
+			return errors.New("BucketExamples must be at least 1")
+		}
+		opts.BucketExamples = n
+		return nil
+	}
+}
+
+// WithIdleTime records the distribution of `OBJECT IDLETIME` idle times of the
+// sampled keys.  See the `IdleTime` field of Options.
+func WithIdleTime() func(*Options) error {
+	return func(opts *Options) error {
+		opts.IdleTime = true
+		return nil
+	}
+}
+
+// WithConnectRetry retries the initial connection to the redis instance up to
+// `attempts` times in total, waiting `backoff` after the first failure and
+// doubling the wait after each subsequent failure.  This smooths over the
+// transient DNS and connection failures that are common immediately after a
+// container starts.
+func WithConnectRetry(attempts int, backoff time.Duration) func(*Options) error {
+	return func(opts *Options) error {
+		if attempts < 1 {
+			return errors.New("attempts must be at least 1")
+		}
+		if backoff < 0 {
+			return errors.New("backoff cannot be negative")
+		}
+		opts.ConnectAttempts = attempts
+		opts.ConnectBackoff = backoff
+		return nil
+	}
+}
+
+// WithProxy establishes connections to redis with `dialer`, e.g. a SOCKS5
+// dialer from the golang.org/x/net/proxy package.  See the `Dialer` field of
+// Options.
+func WithProxy(dialer Dialer) func(*Options) error {
+	return func(opts *Options) error {
+		if dialer == nil {
+			return errors.New("Dialer cannot be nil")
+		}
+		opts.Dialer = dialer
+		return nil
+	}
+}
+
+// WithDialer establishes connections to redis by calling `dial`.  It is
+// equivalent to WithProxy(DialerFunc(dial)).
+func WithDialer(dial func(network, addr string) (net.Conn, error)) func(*Options) error {
+	return func(opts *Options) error {
+		if dial == nil {
+			return errors.New("Dialer cannot be nil")
+		}
+		opts.Dialer = DialerFunc(dial)
+		return nil
+	}
+}
+
+// WithAuthProvider authenticates each connection to redis with credentials
+// obtained from `provider`.  See the `AuthProvider` field of Options.
+func WithAuthProvider(provider func() (user, pass string, err error)) func(*Options) error {
+	return func(opts *Options) error {
+		opts.AuthProvider = provider
+		return nil
+	}
+}
+
+// WithKeyCountFunc counts the keys in the redis instance by calling `fn`,
+// e.g. DBSize.  See the `KeyCount` field of Options.
+func WithKeyCountFunc(fn func(redis.Conn) (int64, error)) func(*Options) error {
+	return func(opts *Options) error {
+		if fn == nil {
+			return errors.New("KeyCount func cannot be nil")
+		}
+		opts.KeyCount = fn
+		return nil
+	}
+}
+
+// WithPreferReplica samples each shard of a redis cluster from a replica,
+// where the shard has one.  See the `PreferReplica` field of Options.
+func WithPreferReplica() func(*Options) error {
+	return func(opts *Options) error {
+		opts.PreferReplica = true
+		return nil
+	}
+}
+
+// WithAllowMaster permits sampling a redis instance whose replication role is
+// master.  See the `AllowMaster` field of Options.
+func WithAllowMaster() func(*Options) error {
+	return func(opts *Options) error {
+		opts.AllowMaster = true
+		return nil
+	}
+}
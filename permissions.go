@@ -0,0 +1,142 @@
+/*
+ * Copyright (C) 2015 zulily, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reckon
+
+import (
+	"strings"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// permissionProbeKey is the key against which CheckPermissions probes the
+// commands that operate on keys.  It need not exist.
+const permissionProbeKey = "reckon:permission-probe"
+
+// A permissionProbe is a harmless invocation of a command used by Run
+type permissionProbe struct {
+	name string
+	args []interface{}
+}
+
+// probe returns a permissionProbe for command `name`, which may include a
+// subcommand, invoked with `args`
+func probe(name string, args ...interface{}) permissionProbe {
+	return permissionProbe{name: name, args: args}
+}
+
+// permissionProbes returns probes of each of the commands that Run issues
+// when configured with `opts`
+func permissionProbes(opts Options) []permissionProbe {
+	k := permissionProbeKey
+	probes := []permissionProbe{probe("TYPE", k)}
+	if opts.KeyCount == nil {
+		// a custom KeyCount may issue any command
+		probes = append(probes, probe("INFO", "keyspace"))
+	}
+	if !opts.AllowMaster {
+		probes = append(probes, probe("INFO", "replication"))
+	}
+
+	switch {
+	case opts.KeysGlob != "":
+		probes = append(probes, probe("KEYS", k))
+	case opts.KeySource != nil:
+		// the keys are supplied by the caller
+	case len(opts.HashSlots) > 0:
+		probes = append(probes, probe("CLUSTER", "COUNTKEYSINSLOT", 0), probe("CLUSTER", "GETKEYSINSLOT", 0, 1))
+	case opts.ScanLimit > 0:
+		probes = append(probes, probe("SCAN", 0, "COUNT", 1))
+	default:
+		probes = append(probes, probe("RANDOMKEY"))
+	}
+	if opts.KeysGlob == "" && opts.KeySource == nil && len(opts.HashSlots) == 0 && opts.ScanLimit <= 0 && (opts.StratifiedPerType > 0 || opts.Allocation != NoAllocation) {
+		probes = append(probes, probe("SCAN", 0, "COUNT", 1, "TYPE", string(TypeString)))
+	}
+
+	if opts.SizeOnly {
+		for _, vt := range sampledTypes {
+			probes = append(probes, probe(sizeCommands[vt], k))
+		}
+	} else {
+		probes = append(probes,
+			probe("GET", k),
+			probe("LLEN", k), probe("LRANGE", k, 0, 0),
+			probe("SCARD", k), probe("SRANDMEMBER", k, 1),
+			probe("ZCARD", k), probe("ZRANGE", k, 0, 0),
+			probe("HLEN", k), probe("HKEYS", k), probe("HMGET", k, "field"))
+	}
+
+	if opts.MaxFetchBytes > 0 && !opts.SizeOnly {
+		probes = append(probes, probe("STRLEN", k), probe("HSTRLEN", k, "field"))
+	}
+	if opts.ListEnds && !opts.SizeOnly {
+		probes = append(probes, probe("LINDEX", k, 0))
+	}
+	if opts.KeyMemory || opts.ElementSizeFromObject && !opts.SizeOnly {
+		probes = append(probes, probe("MEMORY USAGE", k))
+	}
+	if opts.BitmapStats {
+		probes = append(probes, probe("BITCOUNT", k))
+	}
+	if opts.AccessFreq {
+		probes = append(probes, probe("OBJECT FREQ", k))
+	}
+	if opts.IdleTime || opts.MaxIdleTime > 0 {
+		probes = append(probes, probe("CONFIG GET", "maxmemory-policy"), probe("OBJECT IDLETIME", k))
+	}
+	return probes
+}
+
+// CheckPermissions connects to the redis instance configured by `fns` (see
+// NewOptions) and issues a harmless probe of each command that Run would
+// issue, returning the names of those that are denied by the instance's
+// ACLs.  Key commands are probed against a key that need not exist, and are
+// permitted by ACLs that restrict sampling to particular key patterns only if
+// `reckon:permission-probe` matches those patterns.
+func CheckPermissions(fns ...func(*Options) error) ([]string, error) {
+	opts, err := NewOptions(fns...)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := dial(opts)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return deniedCommands(conn, permissionProbes(opts))
+}
+
+// deniedCommands issues each of `probes`, returning the names of those that
+// fail with a `NOPERM` error.  Other redis errors (e.g. a probed key having
+// the wrong type) imply that the command is permitted, and are ignored.
+func deniedCommands(conn redis.Conn, probes []permissionProbe) ([]string, error) {
+	var denied []string
+	for _, p := range probes {
+		args := append(redis.Args{}.AddFlat(strings.Fields(p.name)[1:]), p.args...)
+		_, err := conn.Do(strings.Fields(p.name)[0], args...)
+		if rerr, ok := err.(redis.Error); ok {
+			if strings.HasPrefix(string(rerr), "NOPERM") {
+				denied = append(denied, p.name)
+			}
+		} else if err != nil {
+			return denied, err
+		}
+	}
+	return denied, nil
+}
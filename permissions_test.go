@@ -0,0 +1,93 @@
+/*
+ * Copyright (C) 2015 zulily, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reckon
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestPermissionProbes(t *testing.T) {
+
+	probed := func(opts Options) map[string]bool {
+		names := map[string]bool{}
+		for _, p := range permissionProbes(opts) {
+			names[p.name] = true
+		}
+		return names
+	}
+
+	all := probed(Options{AccessFreq: true, IdleTime: true, BitmapStats: true, StratifiedPerType: 1})
+	for _, cmd := range []string{"RANDOMKEY", "SCAN", "INFO", "GET", "HMGET", "BITCOUNT", "OBJECT FREQ", "OBJECT IDLETIME", "CONFIG GET"} {
+		if !all[cmd] {
+			t.Errorf("expected %s to be probed", cmd)
+		}
+	}
+	if all["KEYS"] || all["STRLEN"] {
+		t.Errorf("unexpected probes: %v", all)
+	}
+
+	keys := probed(Options{KeysGlob: "*", SizeOnly: true, StratifiedPerType: 1})
+	if !keys["KEYS"] || !keys["STRLEN"] {
+		t.Errorf("expected KEYS and STRLEN to be probed: %v", keys)
+	}
+	if keys["RANDOMKEY"] || keys["SCAN"] || keys["GET"] {
+		t.Errorf("unexpected probes: %v", keys)
+	}
+
+	if counted := probed(Options{KeyCount: DBSize, AllowMaster: true}); counted["INFO"] {
+		t.Errorf("expected INFO not to be probed with a KeyCount func: %v", counted)
+	}
+}
+
+func TestDeniedCommands(t *testing.T) {
+
+	var issued []string
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		issued = append(issued, strings.TrimSpace(fmt.Sprint(cmd, " ", args)))
+		switch cmd {
+		case "SCAN":
+			return nil, redis.Error("NOPERM this user has no permissions to run the 'scan' command")
+		case "OBJECT":
+			return nil, redis.Error("ERR no such key")
+		}
+		return nil, nil
+	}}
+
+	probes := []permissionProbe{probe("RANDOMKEY"), probe("SCAN", 0), probe("OBJECT FREQ", "k")}
+	denied, err := deniedCommands(conn, probes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(denied) != "[SCAN]" {
+		t.Errorf("expected only SCAN to be denied, actual: %v", denied)
+	}
+	if fmt.Sprint(issued) != "[RANDOMKEY [] SCAN [0] OBJECT [FREQ k]]" {
+		t.Errorf("unexpected commands issued: %v", issued)
+	}
+
+	conn.handler = func(cmd string, args ...interface{}) (interface{}, error) {
+		return nil, errors.New("connection reset")
+	}
+	if _, err := deniedCommands(conn, probes); err == nil {
+		t.Error("expected a connection error to be returned")
+	}
+}
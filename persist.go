@@ -0,0 +1,210 @@
+/*
+ * Copyright (C) 2015 zulily, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reckon
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"io"
+	"reflect"
+	"sort"
+)
+
+// ResultsVersion is the version of the serialized form of Results written by
+// Save and GobEncode, recorded in the Version field.  It is incremented
+// whenever fields are added to Results, so that LoadResults can recognize
+// results written by a different version of reckon.
+//
+// Version 2 added SetByteSizes, SortedSetByteSizes and ListByteSizes,
+// version 3 added BucketKeys, and version 4 added KeyMemorySizes.
+const ResultsVersion = 4
+
+// Save serializes the method receiver as JSON to the supplied io.Writer.  The
+// serialized Results can later be reloaded with LoadResults, e.g. to be merged
+// with results sampled elsewhere, or rendered on another host.
+func (r *Results) Save(w io.Writer) error {
+	return json.NewEncoder(w).Encode(r)
+}
+
+// LoadResults deserializes a Results instance previously written by Save.
+// The number of elements from which each example set was drawn is not
+// serialized, so further observations added to the loaded Results are
+// weighted as though each example set had been drawn only from the examples
+// it retains.  Results written by other versions of reckon are loaded as
+// described by LoadResultsWithLogger, with any warnings discarded.
+func LoadResults(rd io.Reader) (*Results, error) {
+	return LoadResultsWithLogger(rd, discardLogger{})
+}
+
+// LoadResultsWithLogger is like LoadResults, but reports to `logger` when
+// the Results were written by a different version of reckon (see
+// ResultsVersion), and names any fields that this version does not know,
+// which are ignored.  Fields absent from the serialized Results, e.g. because
+// they were written by an older version, are left empty, so the loaded
+// Results can be merged with any others.
+func LoadResultsWithLogger(rd io.Reader, logger Logger) (*Results, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(rd).Decode(&raw); err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+
+	// Results written before the Version field was added have no version
+	r := NewResults()
+	r.Version = 0
+	if err := json.Unmarshal(raw, r); err != nil {
+		return nil, err
+	}
+	if r.Version != ResultsVersion {
+		logger.Printf("WARNING: loading results written with version %d of the serialized form, rather than version %d\n", r.Version, ResultsVersion)
+	}
+	if unknown := unknownFields(fields); len(unknown) > 0 {
+		logger.Printf("WARNING: ignoring unknown fields of the serialized results: %v\n", unknown)
+	}
+
+	r.Version = ResultsVersion
+	r.initMaps()
+	r.seen = countExamples(r)
+	return r, nil
+}
+
+// unknownFields returns the names, in sorted order, of those of `fields` that
+// are not exported fields of Results
+func unknownFields(fields map[string]json.RawMessage) []string {
+	t := reflect.TypeOf(Results{})
+	var unknown []string
+	for name := range fields {
+		if f, ok := t.FieldByName(name); !ok || f.PkgPath != "" {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// initMaps replaces any nil maps among the fields of the method receiver with
+// empty ones, e.g. those decoded as null, or absent from Results written by an
+// older version, so that the receiver can be observed into or merged into.
+func (r *Results) initMaps() {
+	v := reflect.ValueOf(r).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if f := v.Field(i); f.Kind() == reflect.Map && f.IsNil() && f.CanSet() {
+			f.Set(reflect.MakeMap(f.Type()))
+		}
+	}
+}
+
+// gobResults has the fields of Results, but not its GobEncoder and GobDecoder
+// methods, so that it can be encoded with gob's default encoding
+type gobResults Results
+
+// exampleSets returns pointers to the example sets of the method receiver, in
+// a fixed order
+func (r *Results) exampleSets() []*map[string]bool {
+	return []*map[string]bool{
+		&r.StringKeys, &r.StringValues,
+		&r.SetKeys, &r.SetElements,
+		&r.SortedSetKeys, &r.SortedSetElements,
+		&r.HashKeys, &r.HashElements, &r.HashValues,
+		&r.ListKeys, &r.ListElements,
+		&r.UnmatchedKeys, &r.BinaryKeys, &r.NonUniformKeys,
+	}
+}
+
+// GobEncode implements gob.GobEncoder.  Example sets, including those of
+// BucketKeys, are encoded as lists of their members, rather than as maps with
+// bool values, avoiding the encoding of a redundant bool per member.  The
+// bucket examples follow the other example sets, so that Results encoded
+// before BucketKeys was added can still be decoded.  Like Save, the number of elements from which each
+// example set was drawn is not encoded.
+func (r *Results) GobEncode() ([]byte, error) {
+	c := gobResults(*r)
+	sets := (*Results)(&c).exampleSets()
+	examples := make([][]string, len(sets))
+	for i, set := range sets {
+		for elem := range *set {
+			examples[i] = append(examples[i], elem)
+		}
+		*set = nil
+	}
+	buckets := make(map[ValueType]map[int][]string)
+	for vt, sizes := range r.BucketKeys {
+		buckets[vt] = make(map[int][]string)
+		for size, keys := range sizes {
+			for key := range keys {
+				buckets[vt][size] = append(buckets[vt][size], key)
+			}
+		}
+	}
+	c.BucketKeys = nil
+
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	if err := enc.Encode(&c); err != nil {
+		return nil, err
+	}
+	if err := enc.Encode(examples); err != nil {
+		return nil, err
+	}
+	if err := enc.Encode(buckets); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GobDecode implements gob.GobDecoder, decoding Results encoded by GobEncode.
+// As with LoadResults, fields unknown to this version are ignored, and any
+// absent fields are left empty.
+func (r *Results) GobDecode(data []byte) error {
+	*r = *NewResults()
+	var examples [][]string
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode((*gobResults)(r)); err != nil {
+		return err
+	}
+	if err := dec.Decode(&examples); err != nil {
+		return err
+	}
+	var buckets map[ValueType]map[int][]string
+	if err := dec.Decode(&buckets); err != nil && err != io.EOF {
+		return err
+	}
+	r.Version = ResultsVersion
+	r.initMaps()
+
+	for i, set := range r.exampleSets() {
+		*set = make(map[string]bool)
+		if i < len(examples) {
+			for _, elem := range examples[i] {
+				(*set)[elem] = true
+			}
+		}
+	}
+	for vt, sizes := range buckets {
+		for size, keys := range sizes {
+			for _, key := range keys {
+				r.bucketKeys(vt, size)[key] = true
+			}
+		}
+	}
+	r.seen = countExamples(r)
+	return nil
+}
@@ -0,0 +1,165 @@
+/*
+ * Copyright (C) 2015 zulily, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reckon
+
+import (
+	"bytes"
+	"encoding/gob"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSaveLoadResults(t *testing.T) {
+
+	r := sampleResults()
+
+	var buf bytes.Buffer
+	if err := r.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadResults(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertResults(t, r, loaded)
+	if !reflect.DeepEqual(r.seen, loaded.seen) {
+		t.Errorf("expected example counts: %+v, actual: %+v", r.seen, loaded.seen)
+	}
+}
+
+func TestGobResults(t *testing.T) {
+
+	r := sampleResults()
+	r.SetElementClassSizes["numeric"] = map[int]int64{3: 2}
+	r.BucketKeys[TypeString] = map[int]map[string]bool{8: {"str1": true, "str2": true}}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(map[string]*Results{"sample": r}); err != nil {
+		t.Fatal(err)
+	}
+
+	var loaded map[string]*Results
+	if err := gob.NewDecoder(&buf).Decode(&loaded); err != nil {
+		t.Fatal(err)
+	}
+
+	assertResults(t, r, loaded["sample"])
+	if !reflect.DeepEqual(r.seen, loaded["sample"].seen) {
+		t.Errorf("expected example counts: %+v, actual: %+v", r.seen, loaded["sample"].seen)
+	}
+
+	if len(r.StringKeys) == 0 {
+		t.Error("expected GobEncode not to modify the encoded Results")
+	}
+}
+
+func TestLoadOtherVersions(t *testing.T) {
+
+	// an older form, without a Version or several maps, and with a field
+	// unknown to this version
+	old := `{"Name": "old", "KeyCount": 2, "StringSizes": {"5": 2}, "StringKeys": {"a": true, "b": true}, "ListSizes": null, "FutureSizes": {"1": 1}}`
+	logger := &bufLogger{}
+	r, err := LoadResultsWithLogger(strings.NewReader(old), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, strings.Join(logger.messages, ""), "version 0 of the serialized form")
+	assertContains(t, strings.Join(logger.messages, ""), "unknown fields of the serialized results: [FutureSizes]")
+	assertInt(t, ResultsVersion, r.Version)
+	if r.ListSizes == nil {
+		t.Error("expected a null map to be initialized")
+	}
+
+	merged := sampleResults()
+	merged.Merge(r)
+	assertInt(t, 4, int(merged.StringSizes[5]))
+
+	// Merge initializes the maps of a receiver that was not created by
+	// NewResults
+	zero := &Results{}
+	zero.Merge(r)
+	assertInt(t, 2, int(zero.KeyCount))
+}
+
+func TestLoadVersion1(t *testing.T) {
+
+	// version 1 preceded the byte size distributions of collections
+	v1 := `{"Version": 1, "Name": "v1", "KeyCount": 1, "SetSizes": {"3": 1}, "HashByteSizes": {"12": 1}}`
+	logger := &bufLogger{}
+	r, err := LoadResultsWithLogger(strings.NewReader(v1), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, strings.Join(logger.messages, ""), "version 1 of the serialized form")
+	for name, m := range map[string]map[int]int64{"SetByteSizes": r.SetByteSizes, "SortedSetByteSizes": r.SortedSetByteSizes, "ListByteSizes": r.ListByteSizes} {
+		if m == nil {
+			t.Errorf("expected %s to be initialized", name)
+		}
+	}
+	current := NewResults()
+	current.SetByteSizes[2] = 1
+	r.Merge(current)
+	assertInt(t, 1, int(r.SetByteSizes[2]))
+}
+
+func TestGobDecodeWithoutBucketKeys(t *testing.T) {
+
+	// Results encoded before BucketKeys were added lack the bucket examples
+	r := sampleResults()
+	c := gobResults(*r)
+	var examples [][]string
+	for _, set := range (*Results)(&c).exampleSets() {
+		var members []string
+		for elem := range *set {
+			members = append(members, elem)
+		}
+		examples = append(examples, members)
+		*set = nil
+	}
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	if err := enc.Encode(&c); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Encode(examples); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded := &Results{}
+	if err := decoded.GobDecode(buf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	assertResults(t, r, decoded)
+}
+
+func TestLoadVersion3(t *testing.T) {
+
+	// version 3 preceded KeyMemorySizes
+	v3 := `{"Version": 3, "Name": "v3", "KeyCount": 1, "StringSizes": {"5": 1}}`
+	logger := &bufLogger{}
+	r, err := LoadResultsWithLogger(strings.NewReader(v3), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, strings.Join(logger.messages, ""), "version 3 of the serialized form")
+	if r.KeyMemorySizes == nil {
+		t.Error("expected KeyMemorySizes to be initialized")
+	}
+}
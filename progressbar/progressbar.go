@@ -0,0 +1,89 @@
+/*
+ * Copyright (C) 2015 zulily, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package progressbar renders the progress of a reckon sampling operation as
+// a progress bar on an interactive terminal.
+package progressbar
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/zulily/reckon"
+)
+
+// width is the number of characters spanned by the bar itself
+const width = 40
+
+// WithProgressBar renders a progress bar for the sampling operation to `w`,
+// in place of the progress messages otherwise printed to stdout.  If `w` is
+// not a terminal, WithProgressBar has no effect, so that redirected output is
+// not littered with control characters.
+func WithProgressBar(w io.Writer) func(*reckon.Options) error {
+	return func(opts *reckon.Options) error {
+		if !isTerminal(w) {
+			return nil
+		}
+		opts.Progress = bar(w)
+		if opts.Logger == nil {
+			opts.Logger = discard{}
+		}
+		return nil
+	}
+}
+
+// isTerminal reports whether `w` is a character device, such as a terminal
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// bar returns a Progress func that redraws a progress bar on the current line
+// of `w` whenever progress is reported.  If the number of keys to be sampled
+// is not known, e.g. for a KeySource without a Len, only the number sampled so
+// far is shown until sampling is done.  A Len may be an estimate, so the bar
+// is capped at 100% if more keys are sampled than were planned.
+func bar(w io.Writer) func(reckon.Progress) {
+	return func(p reckon.Progress) {
+		if p.Planned <= 0 && !p.Done {
+			fmt.Fprintf(w, "\r%d keys sampled (of %d)", p.Sampled, p.TotalKeys)
+			return
+		}
+		fraction := 1.0
+		if !p.Done {
+			fraction = math.Min(math.Max(float64(p.Sampled)/float64(p.Planned), 0), 1)
+		}
+		filled := int(fraction * width)
+		fmt.Fprintf(w, "\r[%s%s] %3.0f%% %d/%d keys sampled (of %d)",
+			strings.Repeat("=", filled), strings.Repeat(" ", width-filled),
+			100*fraction, p.Sampled, p.Planned, p.TotalKeys)
+		if p.Done {
+			fmt.Fprintln(w)
+		}
+	}
+}
+
+// discard is a reckon.Logger that drops every message
+type discard struct{}
+
+func (discard) Printf(format string, v ...interface{}) {}
@@ -0,0 +1,74 @@
+/*
+ * Copyright (C) 2015 zulily, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package progressbar
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/zulily/reckon"
+)
+
+func TestBar(t *testing.T) {
+
+	var buf bytes.Buffer
+	render := bar(&buf)
+
+	render(reckon.Progress{Sampled: 50, Planned: 100, TotalKeys: 1000})
+	expected := "\r[" + strings.Repeat("=", 20) + strings.Repeat(" ", 20) + "]  50% 50/100 keys sampled (of 1000)"
+	if buf.String() != expected {
+		t.Errorf("expected: %q, actual: %q", expected, buf.String())
+	}
+
+	buf.Reset()
+	render(reckon.Progress{Sampled: 80, Planned: 100, TotalKeys: 1000, Done: true})
+	if !strings.Contains(buf.String(), "] 100% 80/100") || !strings.HasSuffix(buf.String(), "\n") {
+		t.Errorf("expected a completed bar, actual: %q", buf.String())
+	}
+}
+
+func TestBarUnplanned(t *testing.T) {
+
+	var buf bytes.Buffer
+	render := bar(&buf)
+
+	// a KeySource whose Len underestimated the number of keys
+	render(reckon.Progress{Sampled: 150, Planned: 100, TotalKeys: 1000})
+	expected := "\r[" + strings.Repeat("=", 40) + "] 100% 150/100 keys sampled (of 1000)"
+	if buf.String() != expected {
+		t.Errorf("expected: %q, actual: %q", expected, buf.String())
+	}
+
+	// a KeySource of unknown Len
+	buf.Reset()
+	render(reckon.Progress{Sampled: 150, TotalKeys: 1000})
+	if expected := "\r150 keys sampled (of 1000)"; buf.String() != expected {
+		t.Errorf("expected: %q, actual: %q", expected, buf.String())
+	}
+}
+
+func TestWithProgressBarNotTerminal(t *testing.T) {
+
+	opts, err := reckon.NewOptions(WithProgressBar(&bytes.Buffer{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Progress != nil || opts.Logger != nil {
+		t.Error("expected no progress bar when not writing to a terminal")
+	}
+}
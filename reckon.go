@@ -19,16 +19,39 @@
 package reckon
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
 	"net"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/garyburd/redigo/redis"
 )
 
+// An Allocation determines how the keys to be sampled are divided among the
+// ValueTypes
+type Allocation int
+
+const (
+	// NoAllocation samples keys at random, regardless of their type
+	NoAllocation Allocation = iota
+
+	// ProportionalAllocation divides the keys to be sampled among the
+	// ValueTypes in proportion to their frequency in the keyspace
+	ProportionalAllocation
+
+	// EqualAllocation divides the keys to be sampled equally among the
+	// ValueTypes
+	EqualAllocation
+)
+
 // Options is a configuration struct that instructs the reckon pkg to sample
 // the redis instance listening on a particular host/port with a specified
 // number/percentage of random keys.
@@ -48,8 +71,419 @@ type Options struct {
 	// sampled will be the greater of the two values, once the key count has been
 	// calculated using the `SampleRate`.
 	SampleRate float32
+
+	// TargetRelError, if greater than zero, samples keys until the mean size
+	// of the sampled values (string lengths, or numbers of members) is known
+	// to within ±TargetRelError of its value (e.g. 0.05 for ±5%), at the
+	// TargetConfidence level (e.g. 0.95), rather than sampling a fixed number
+	// of keys.  At least MinSamples keys, and no fewer than 30, are sampled,
+	// and SampleRate is ignored.
+	TargetRelError   float64
+	TargetConfidence float64
+
+	// MaxSamples caps the number of keys sampled when TargetRelError is set.
+	// If zero, no more keys are sampled than the key count of the instance.
+	MaxSamples int
+
+	// BurnIn is the number of random keys to sample and discard before
+	// observation begins, to avoid any bias in the first keys returned by
+	// `RANDOMKEY`.  Each discarded key costs a `RANDOMKEY` and a `TYPE`
+	// round trip.  BurnIn is ignored if KeysGlob or KeySource is set.
+	BurnIn int
+
+	// KeysGlob, if non-empty, replaces random sampling with an exact analysis
+	// of every key matching the glob-style pattern, as returned by a single
+	// `KEYS` command.  MinSamples, SampleRate, StratifiedPerType and
+	// Allocation are ignored.  If no keys match, a warning is logged and
+	// recorded in the RunSummary.  WARNING: `KEYS` blocks the redis instance
+	// while it iterates over the entire keyspace, and must never be used
+	// against a production-sized instance.  It is intended only for small
+	// development and test instances, with at most a few thousand keys.
+	KeysGlob string
+
+	// KeySource, if non-nil, replaces random sampling with an analysis of
+	// every key it supplies, e.g. a list of keys read from a file (see
+	// ReadKeyList).  Like KeysGlob, MinSamples, SampleRate, StratifiedPerType
+	// and Allocation are ignored.  KeysGlob takes precedence if both are set.
+	KeySource KeySource
+
+	// SampleLog, if non-nil, receives a line for each observed key, as it is
+	// observed: its ValueType, a tab, and its name, double-quoted and escaped
+	// as a Go string literal.  This records exactly which keys a run touched,
+	// e.g. for auditing, or to observe the same keys again with
+	// ReadSampleLog.
+	SampleLog io.Writer
+
+	// ShardID and ShardCount, if ShardCount is greater than one, partition
+	// the keys examined via KeysGlob or KeySource between ShardCount
+	// cooperating processes: only keys whose hash modulo ShardCount is
+	// ShardID are observed (see keyShard), so the Results of processes with
+	// each ShardID from 0 to ShardCount-1 merge to cover every key.  Each
+	// process still pays the full cost of `KEYS`, or of reading the
+	// KeySource; only the per-key commands are divided between them.
+	ShardID    int
+	ShardCount int
+
+	// HashSlots, if non-empty, restricts the analysis to keys in the given
+	// redis cluster hash slots (see HashSlot), e.g. to investigate a hot slot.
+	// Unless KeysGlob or KeySource is set, every key in the slots is obtained
+	// with `CLUSTER GETKEYSINSLOT`, which must be issued to the cluster node
+	// that owns the slots; MinSamples, SampleRate, StratifiedPerType and
+	// Allocation are then ignored.  Otherwise, keys in other slots are skipped.
+	HashSlots []int
+
+	// ScanLimit, if positive, replaces random sampling with an analysis of
+	// a slice of the keyspace: keys are walked with `SCAN`, from ScanCursor,
+	// until at least ScanLimit keys have been examined (the final `SCAN`
+	// batch is always examined in full).  The cursor from which the next
+	// slice begins is reported in the `NextCursor` field of the RunSummary,
+	// so that scheduled runs can cover a large keyspace in bounded chunks,
+	// merging each run's Results into those saved by the previous one (see
+	// LoadResults and Merge).  `SCAN` guarantees only that keys present for
+	// the whole walk are returned, so keys added, deleted or changed between
+	// runs make the accumulated Results less accurate.  MinSamples,
+	// SampleRate, StratifiedPerType and Allocation are ignored.  KeysGlob,
+	// KeySource and HashSlots take precedence if set.
+	ScanLimit  int
+	ScanCursor uint64
+
+	// ConnectAttempts is the number of times Run attempts to establish the
+	// initial connection to the redis instance before giving up.  Values less
+	// than 1 are treated as 1.  Between attempts, Run waits ConnectBackoff,
+	// doubling the wait after each failed attempt.
+	ConnectAttempts int
+	ConnectBackoff  time.Duration
+
+	// Dialer, if non-nil, establishes the network connections to the redis
+	// instance, e.g. through a SOCKS5 proxy.  See WithProxy.
+	Dialer Dialer
+
+	// AuthProvider, if non-nil, is called each time a connection to the redis
+	// instance is established, to obtain the credentials with which the
+	// connection is authenticated using `AUTH`.  This accommodates passwords
+	// that are rotated while sampling is in progress.  If `user` is empty,
+	// only the password is sent, as required by redis versions before 6.0.
+	AuthProvider func() (user, pass string, err error)
+
+	// KeyCount, if non-nil, replaces the default means of counting the keys
+	// in the redis instance, which parses the output of `INFO keyspace`, e.g.
+	// with DBSize for redis-compatible servers whose `INFO` output does not
+	// report a key count.  It should return ErrNoKeys if there are no keys.
+	KeyCount func(redis.Conn) (int64, error)
+
+	// Tracer, if non-nil, is used to trace each Run with a "reckon.run" span,
+	// tagged with the host, port, sampling mode and number of keys sampled,
+	// and child spans for the "reckon.connect", "reckon.keyCount" and
+	// "reckon.sample" phases.  Without a Tracer, no spans are created.
+	Tracer Tracer
+
+	// PreferReplica directs RunCluster to sample each shard of a redis
+	// cluster from one of its replicas, rather than its master, wherever the
+	// shard has a replica.  See RunCluster.
+	PreferReplica bool
+
+	// ReadOnly sends `READONLY` on each connection to the redis instance, as
+	// required to read keys from a redis cluster replica.  RunCluster sets it
+	// for the replicas that it samples.
+	ReadOnly bool
+
+	// AllowMaster permits sampling a redis instance whose replication role is
+	// master.  By default, Run checks the role reported by `INFO replication`
+	// and refuses to sample a master, to avoid accidentally loading a
+	// production primary; sampling should ordinarily be directed at replicas.
+	AllowMaster bool
+
+	// StringExampleBytes, if greater than zero, limits each example string
+	// value captured during sampling to its first StringExampleBytes bytes.
+	// This is useful for recognizing the format of large, structured values
+	// (serialized JSON, protobuf, etc.) without storing them in full.  Value
+	// size statistics always reflect the full length of the value.
+	StringExampleBytes int
+
+	// MaxFetchBytes, if greater than zero, prevents string and hash values
+	// longer than MaxFetchBytes bytes from being fetched.  The length of each
+	// string or hash value is first obtained with `STRLEN` or `HSTRLEN`
+	// (redis 3.2 or later), and larger values are recorded by size only,
+	// without contributing examples.  This bounds the bandwidth used by
+	// sampling, at the cost of an extra round trip per string or hash.
+	MaxFetchBytes int
+
+	// MemoryBudget, if greater than zero, caps the total length in bytes of
+	// the example keys, values and elements retained across every
+	// aggregation group.  Once the budget is reached, further examples are
+	// discarded, while sizes continue to be counted.  Unlike the per-set
+	// limits (e.g. MaxExampleKeys), this bounds memory use however many groups
+	// the Aggregator produces.  The RunSummary reports whether the budget was
+	// reached.
+	MemoryBudget int
+
+	// MergeGroups instructs Reckon to merge the results of all aggregation
+	// groups into a single report.  It has no effect on Run.
+	MergeGroups bool
+
+	// StratifiedPerType, if greater than zero, tops up the random sample so
+	// that at least StratifiedPerType keys of every ValueType present in the
+	// redis instance are observed, regardless of how rare that type is in the
+	// keyspace.  The extra keys are located using `SCAN ... TYPE`, which
+	// requires redis 6.0 or later, and may need to iterate over the entire
+	// keyspace to find keys of a very rare type.
+	StratifiedPerType int
+
+	// Allocation, if not NoAllocation, divides the keys to be sampled among
+	// the ValueTypes.  A pilot tenth of the keys are sampled at random to
+	// discover the mix of types in the keyspace, and the remainder are
+	// located using `SCAN ... TYPE` (see StratifiedPerType), so that every
+	// type present in the redis instance is observed at least once.
+	Allocation Allocation
+
+	// PerTypeBudget, if non-nil, caps the number of keys of each ValueType
+	// that are observed.  Once a type's budget is reached, sampled keys of that
+	// type are skipped while sampling of other types continues, and sampling
+	// ends early once every type in PerTypeBudget has reached its budget.
+	// Types absent from PerTypeBudget are not capped.  Either way, no more
+	// than the configured number of samples are drawn.  When KeysGlob is set,
+	// every matching key is still examined, but keys of a type whose budget
+	// has been reached are not observed.  The number of keys of each type
+	// skipped in this way is reported in the RunSummary.
+	PerTypeBudget map[ValueType]int
+
+	// SizeOnly instructs Run to gather only the size distribution of each
+	// type, using `STRLEN`, `LLEN`, `SCARD`, `ZCARD` and `HLEN`, without
+	// fetching any values or members.  Example keys are still captured, but
+	// example values/elements and element size distributions are not.  This
+	// dramatically reduces the bandwidth used when sampling large values.
+	SizeOnly bool
+
+	// OtherTypes instructs Run to count keys of types that it does not sample
+	// (e.g. `stream`, or a module type such as `ReJSON-RL`), rather than
+	// failing when one is sampled.  Such a key is passed to the Aggregator with
+	// the exact type name returned by `TYPE` as its ValueType, and counted in
+	// the OtherTypes of each of its groups, but its value is not examined.
+	OtherTypes bool
+
+	// UniformElementSize, if greater than zero, declares that every element
+	// of a set, sorted set or list, and every field of a hash, is expected to
+	// be UniformElementSize bytes long (e.g. 16 for binary UUIDs).  Keys with
+	// a sampled element of any other size are counted, with examples, and
+	// each type whose element sizes have a coefficient of variation above
+	// UniformMaxCV is flagged in reports.  See Results.NonUniformTypes.
+	UniformElementSize int
+	UniformMaxCV       float64
+
+	// ElementsPerKey is the number of elements (members, or fields and their
+	// values) sampled from each list, set, sorted set and hash, in order to
+	// compute element size distributions.  Values less than 1 are treated as
+	// 1.  Set members are sampled at random and are always distinct; for the
+	// other types, the first ElementsPerKey elements are sampled.
+	ElementsPerKey int
+
+	// AccessFreq instructs Run to record the distribution of access
+	// frequencies of the sampled keys, as reported by `OBJECT FREQ`.  This
+	// requires the redis instance to be configured with an LFU
+	// maxmemory-policy (e.g. allkeys-lfu); if it is not, Run returns an error.
+	AccessFreq bool
+
+	// IdleTime instructs Run to record the distribution of idle times (the
+	// number of seconds since each sampled key was last accessed), as reported
+	// by `OBJECT IDLETIME`.  Idle times are not tracked by redis instances
+	// configured with an LFU maxmemory-policy; Run checks the policy using
+	// `CONFIG GET` and returns an error if it is LFU.  If `CONFIG` is
+	// unavailable (e.g. it has been renamed), the check is skipped.
+	IdleTime bool
+
+	// MaxIdleTime, if greater than zero, restricts sampling to recently-used
+	// keys: keys whose `OBJECT IDLETIME` exceeds MaxIdleTime seconds are
+	// skipped.  This characterizes the working set, rather than the whole
+	// keyspace.  The same maxmemory-policy restrictions as IdleTime apply.
+	MaxIdleTime int
+
+	// BitmapStats instructs Run to record the distribution of the number of
+	// set bits in sampled string values, as reported by `BITCOUNT`.  This is
+	// useful when strings are used as bitmaps; since bitmaps cannot be
+	// distinguished from other strings, `BITCOUNT` is issued for every sampled
+	// string.
+	BitmapStats bool
+
+	// HashFieldAggregator, if non-nil, assigns the fields sampled from each
+	// hash to field classes (e.g. by a pattern on the field name), so that a
+	// separate value size distribution is recorded for each class, in
+	// addition to the aggregate distribution of all hash values.  Only the
+	// fields sampled from each hash are classified, so ElementsPerKey should
+	// usually be raised when using a HashFieldAggregator.
+	HashFieldAggregator FieldAggregator
+
+	// HashByteEstimates instructs Run to record the distribution of the
+	// estimated size in bytes of each sampled hash: the total length of the
+	// sampled fields and values, scaled up by the number of fields in the
+	// hash.  This approximates the memory cost of hashes without `MEMORY
+	// USAGE`; the estimate improves as ElementsPerKey is raised.
+	HashByteEstimates bool
+
+	// ByteEstimates extends HashByteEstimates to sets, sorted sets and lists:
+	// the estimated size in bytes of each sampled collection is the mean
+	// length of its sampled elements, scaled up by its cardinality.  It also
+	// implies HashByteEstimates.  Sorted set scores are not included.
+	ByteEstimates bool
+
+	// KeyMemory instructs Run to record the distribution of the memory, in
+	// bytes, used by each sampled key and its value, as reported by `MEMORY
+	// USAGE` (which requires redis 4.0 or later).  See the `KeyMemorySizes`
+	// field of Results, and MemoryConcentration.
+	KeyMemory bool
+
+	// ElementSizeFromObject instructs Run to record, as the size of each
+	// sampled member of a set, sorted set or list, the memory used by the
+	// collection (as reported by `MEMORY USAGE`, which requires redis 4.0 or
+	// later) divided by its cardinality, rather than the length of the member.
+	// The element size distributions then account for encoding overhead,
+	// and for members that were not sampled.  Hashes are unaffected.
+	ElementSizeFromObject bool
+
+	// BucketExamples, if positive, instructs Run to retain up to this many
+	// example keys of each type for each power-of-two size bucket (see
+	// ComputePowerOfTwoFreq), e.g. to find keys of about 1MB.  Sizes are as
+	// recorded in the size distribution of each type.  See the `BucketKeys`
+	// field of Results.
+	BucketExamples int
+
+	// ListEnds instructs Run to record the distributions of the sizes of the
+	// head and tail elements of each sampled list, as returned by `LINDEX`.
+	// This reveals lists, such as queues, whose ends hold elements of
+	// different kinds.
+	ListEnds bool
+
+	// KeyTransform, if non-nil, normalizes each sampled key (e.g. replacing
+	// embedded IDs with `*`) before it is passed to the Aggregator and
+	// recorded as an example.  Redis commands are always issued with the raw
+	// key.  ExpectedPatterns are matched against the raw key.
+	KeyTransform func(key string) string
+
+	// ExpectedPatterns, if non-empty, audits the sampled keys against the
+	// namespaces they are expected to belong to: the Results for each group
+	// separately count, and capture examples of, the sampled keys matching
+	// none of the patterns.  Such keys may be leftovers, or mis-written.
+	ExpectedPatterns []*regexp.Regexp
+
+	// ElementAggregator, if non-nil, assigns the elements sampled from each
+	// set, sorted set and list to element classes (e.g. by a pattern on the
+	// element), so that a separate element size distribution is recorded for
+	// each class, independently of the groups the key is assigned to.  Only
+	// the elements sampled from each key are classified, so ElementsPerKey
+	// should usually be raised when using an ElementAggregator.
+	ElementAggregator ElementAggregator
+
+	// ApproxHistogram instructs Run to record sizes, bit counts and idle times
+	// in approximate, log-linear histograms, in which each bucket spans about
+	// 3% of its value, rather than recording every distinct value exactly.
+	// This bounds the memory used by the frequency maps of Results when
+	// sampling huge keyspaces with highly varied sizes, at the cost of exact
+	// statistics.  The Approximate field of the resulting Results is set.
+	ApproxHistogram bool
+
+	// Logger receives the progress messages emitted during sampling.  If nil,
+	// progress messages are printed to stdout.
+	Logger Logger
+
+	// Progress, if non-nil, is called periodically during sampling (see
+	// ProgressInterval), and once more when sampling completes.  See the progressbar package for a
+	// ready-made Progress func for interactive terminals.
+	Progress func(Progress)
+
+	// ProgressInterval is the number of keys sampled between progress
+	// messages and calls to Progress.  If zero, progress is reported about
+	// once per 1% of the keys to be sampled.
+	ProgressInterval int
+
+	// Verbose, if non-nil, receives a message for every observed key, naming
+	// the key, its type, and the groups the Aggregator assigned it to.  This is
+	// useful for debugging an Aggregator that isn't bucketing keys as expected.
+	Verbose Logger
+
+	// Verbosity controls which messages are logged during sampling.  See
+	// Verbosity.
+	Verbosity Verbosity
+
+	// Summary, if non-nil, is populated by Run with operational details about
+	// the sampling operation, such as the number of keys observed per type.
+	Summary *RunSummary
+
+	// GrowthProbe, if greater than zero, instructs Run to take a second
+	// reading of the key count once sampling completes, at least GrowthProbe
+	// after the first, waiting for the remainder of GrowthProbe if sampling
+	// completes sooner.  The rate at which the keyspace grew (or shrank)
+	// between the readings is reported in the RunSummary.
+	GrowthProbe time.Duration
+}
+
+// Progress describes how far a sampling operation has progressed
+type Progress struct {
+	// Sampled is the number of random keys sampled so far
+	Sampled int
+
+	// Planned is the number of random keys to be sampled.  Sampling may end
+	// early, e.g. once every PerTypeBudget has been exhausted.  Planned is
+	// zero if a KeySource that cannot report its Len is being observed.
+	Planned int
+
+	// TotalKeys is the number of keys in the redis instance
+	TotalKeys int64
+
+	// Done is set for the final report, once random sampling has completed
+	Done bool
+}
+
+// A Logger receives messages about the progress of a sampling operation.
+// *log.Logger satisfies this interface.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// A Dialer establishes network connections.  It is satisfied by *net.Dialer,
+// and by the proxy.Dialer of the golang.org/x/net/proxy package.
+type Dialer interface {
+	Dial(network, addr string) (net.Conn, error)
 }
 
+// The DialerFunc type is an adapter to allow the use of ordinary functions as
+// Dialers.
+type DialerFunc func(network, addr string) (net.Conn, error)
+
+// Dial establishes a connection to `addr` on the named `network`
+func (f DialerFunc) Dial(network, addr string) (net.Conn, error) {
+	return f(network, addr)
+}
+
+// stdoutLogger is the Logger used when none is configured
+type stdoutLogger struct{}
+
+func (stdoutLogger) Printf(format string, v ...interface{}) {
+	fmt.Printf(format, v...)
+}
+
+// discardLogger is a Logger that drops every message
+type discardLogger struct{}
+
+func (discardLogger) Printf(format string, v ...interface{}) {}
+
+// A Verbosity is a level of detail of the messages logged during sampling
+type Verbosity int
+
+const (
+	// VerbosityProgress logs status, progress and warning messages to the
+	// Logger, and messages for every observed key to Verbose, if set.  It is
+	// the default.
+	VerbosityProgress Verbosity = iota
+
+	// VerbositySilent logs nothing at all, to either Logger or Verbose.  This
+	// is useful when embedding reckon in programs whose output must not be
+	// corrupted by stray messages.
+	VerbositySilent
+
+	// VerbosityDebug additionally logs a message for every observed key to
+	// the Logger, if Verbose is not set.
+	VerbosityDebug
+)
+
 // A ValueType represents the various data types that redis can store. The
 // string representation of a ValueType matches what is returned from redis'
 // `TYPE` command.
@@ -74,19 +508,31 @@ var (
 	// TypeUnknown means that the redis value type is undefined, and indicates an error
 	TypeUnknown ValueType = "unknown"
 
+	// TypeNone is returned by redis' `TYPE` command for a key that does not
+	// exist, e.g. because it expired or was deleted after it was sampled
+	TypeNone ValueType = "none"
+
 	// ErrNoKeys is the error returned when a specified redis instance contains
 	// no keys, or the key count could not be determined
 	ErrNoKeys = errors.New("No keys are present in the configured redis instance")
 
+	// ErrMaster is the error returned when the configured redis instance is a
+	// master, and sampling masters has not been allowed via AllowMaster
+	ErrMaster = errors.New("The configured redis instance is a master; set AllowMaster to sample it anyway")
+
 	// keysExpr captures the key count from the matching line of output from
-	// redis' "INFO" command
+	// redis' "INFO keyspace" command
 	keysExpr = regexp.MustCompile("^db\\d+:keys=(\\d+),")
 )
 
+// DefaultGroup is the name of the aggregation group to which AnyKey assigns
+// every sampled key.  It can be used to index the results returned by Run.
+const DefaultGroup = "any-key"
+
 // AnyKey is an AggregatorFunc that puts any sampled key (regardless of key
-// name or redis data type) into a generic "any-key" bucket.
+// name or redis data type) into a generic DefaultGroup bucket.
 func AnyKey(key string, valueType ValueType) []string {
-	return []string{"any-key"}
+	return []string{DefaultGroup}
 }
 
 // An Aggregator returns 0 or more arbitrary strings, to be used during a
@@ -108,12 +554,65 @@ func (f AggregatorFunc) Groups(key string, valueType ValueType) []string {
 	return f(key, valueType)
 }
 
+// A FieldAggregator returns 0 or more field classes for a field sampled from
+// a redis hash.  Whereas an Aggregator groups keys, a FieldAggregator groups
+// the fields within a hash, e.g. to distinguish `meta:*` fields from `blob:*`
+// fields.
+type FieldAggregator interface {
+	Classes(field string) []string
+}
+
+// The FieldAggregatorFunc type is an adapter to allow the use of ordinary
+// functions as FieldAggregators.
+type FieldAggregatorFunc func(field string) []string
+
+// Classes provides 0 or more classes to aggregate the hash field `field` to
+func (f FieldAggregatorFunc) Classes(field string) []string {
+	return f(field)
+}
+
+// An ElementAggregator returns 0 or more element classes for an element
+// sampled from a redis set, sorted set or list, e.g. to distinguish
+// `tag:color:*` members from `tag:size:*` members.
+type ElementAggregator interface {
+	Classes(element string) []string
+}
+
+// The ElementAggregatorFunc type is an adapter to allow the use of ordinary
+// functions as ElementAggregators.
+type ElementAggregatorFunc func(element string) []string
+
+// Classes provides 0 or more classes to aggregate `element` to
+func (f ElementAggregatorFunc) Classes(element string) []string {
+	return f(element)
+}
+
 // flush is a convenience func for flushing a redis pipeline, receiving the
 // replies, and returning them, along with any error
 func flush(conn redis.Conn) ([]interface{}, error) {
 	return redis.Values(conn.Do(""))
 }
 
+// countingConn is a redis.Conn that counts the commands issued on it, whether
+// individually or in pipelines
+type countingConn struct {
+	redis.Conn
+	commands int64
+}
+
+func (c *countingConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	if cmd != "" {
+		// an empty command only flushes the pipeline
+		c.commands++
+	}
+	return c.Conn.Do(cmd, args...)
+}
+
+func (c *countingConn) Send(cmd string, args ...interface{}) error {
+	c.commands++
+	return c.Conn.Send(cmd, args...)
+}
+
 // ensureEntry is a convenience func for obtaining the Stats instance for the
 // specified `group`, creating a new one if no such entry already exists
 func ensureEntry(m map[string]*Results, group string, init func() *Results) *Results {
@@ -133,17 +632,77 @@ func randomKey(conn redis.Conn) (key string, vt ValueType, err error) {
 		return key, TypeUnknown, err
 	}
 
+	vt, err = keyType(conn, key)
+	return key, vt, err
+}
+
+// keyType obtains the ValueType of `key` from the supplied redis connection
+func keyType(conn redis.Conn, key string) (ValueType, error) {
 	typeStr, err := redis.String(conn.Do("TYPE", key))
 	if err != nil {
-		return key, TypeUnknown, err
+		return TypeUnknown, err
+	}
+	return parseValueType(typeStr), nil
+}
+
+// parseValueType converts a reply from redis' `TYPE` command to a ValueType.
+// The reply is trimmed and, if it names a type that reckon knows, lowercased,
+// since some proxies return status replies with surrounding whitespace or in a
+// different case.  Other type names, e.g. of module types, are case-sensitive
+// and are retained exactly.
+func parseValueType(reply string) ValueType {
+	reply = strings.TrimSpace(reply)
+	if vt := ValueType(strings.ToLower(reply)); vt == TypeNone || isSampledType(vt) {
+		return vt
+	}
+	return ValueType(reply)
+}
+
+// isSampledType reports whether `vt` is one of the sampledTypes
+func isSampledType(vt ValueType) bool {
+	for _, t := range sampledTypes {
+		if vt == t {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIdleTimePolicy returns an error if the redis instance is configured
+// with an LFU maxmemory-policy, under which `OBJECT IDLETIME` is unavailable
+func checkIdleTimePolicy(conn redis.Conn) error {
+	reply, err := redis.Strings(conn.Do("CONFIG", "GET", "maxmemory-policy"))
+	if err != nil || len(reply) < 2 {
+		// CONFIG may be disabled or renamed; OBJECT IDLETIME will report
+		// any problem itself
+		return nil
+	}
+	if strings.Contains(reply[1], "lfu") {
+		return fmt.Errorf("IdleTime is unavailable with the %s maxmemory-policy; use AccessFreq instead", reply[1])
+	}
+	return nil
+}
+
+// replicationRole obtains the replication role (e.g. "master" or "slave") of
+// the redis instance
+func replicationRole(conn redis.Conn) (string, error) {
+	resp, err := redis.String(conn.Do("INFO", "replication"))
+	if err != nil {
+		return "", err
 	}
 
-	return key, ValueType(typeStr), nil
+	for _, str := range strings.Split(resp, "\n") {
+		if strings.HasPrefix(str, "role:") {
+			return strings.TrimSpace(strings.TrimPrefix(str, "role:")), nil
+		}
+	}
+	return "", errors.New("could not determine the replication role of the redis instance")
 }
 
-// keyCount obtains a the number of keys in the redis instance.
+// keyCount obtains a the number of keys in the redis instance.  Only the
+// keyspace section of `INFO` is requested, since the full output can be large.
 func keyCount(conn redis.Conn) (count int64, err error) {
-	resp, err := redis.String(conn.Do("INFO"))
+	resp, err := redis.String(conn.Do("INFO", "keyspace"))
 	if err != nil {
 		return count, err
 	}
@@ -160,202 +719,1443 @@ func keyCount(conn redis.Conn) (count int64, err error) {
 	return 0, ErrNoKeys
 }
 
-func sampleString(key string, conn redis.Conn, aggregator Aggregator, stats map[string]*Results) error {
-	val, err := redis.String(conn.Do("GET", key))
-	if err != nil {
-		return err
+// DBSize obtains the number of keys in the currently-selected database of the
+// redis instance using `DBSIZE`.  It is an alternative to the default key
+// count (see the `KeyCount` field of Options) for servers whose `INFO` output
+// omits the keyspace section.
+func DBSize(conn redis.Conn) (int64, error) {
+	count, err := redis.Int64(conn.Do("DBSIZE"))
+	if err == nil && count == 0 {
+		err = ErrNoKeys
 	}
+	return count, err
+}
 
-	for _, agg := range aggregator.Groups(key, TypeString) {
-		s := ensureEntry(stats, agg, NewResults)
-		s.observeString(key, val)
+// countKeys obtains the number of keys in the redis instance using the
+// KeyCount configured in `opts`, if any, or keyCount otherwise
+func countKeys(opts Options, conn redis.Conn) (int64, error) {
+	if opts.KeyCount != nil {
+		return opts.KeyCount(conn)
 	}
-	return nil
+	return keyCount(conn)
 }
 
-func sampleList(key string, conn redis.Conn, aggregator Aggregator, stats map[string]*Results) error {
-	// TODO: Let's not always get the first element, like the orig. reckon
-	conn.Send("LLEN", key)
-	conn.Send("LRANGE", key, 0, 0)
-	replies, err := flush(conn)
-	if err != nil {
-		return err
+// prefix returns at most the first `n` bytes of `s`.  If `n` is not positive,
+// `s` is returned unmodified
+func prefix(s string, n int) string {
+	if n > 0 && len(s) > n {
+		return s[:n]
 	}
+	return s
+}
 
-	if len(replies) >= 2 {
-		l, err := redis.Int(replies[0], nil)
-		ms, err := redis.Strings(replies[1], err)
-		if err != nil {
+// sampler holds the state of a single sampling operation against a redis
+// instance
+type sampler struct {
+	conn       redis.Conn
+	opts       Options
+	aggregator Aggregator
+	stats      map[string]*Results
+
+	// stream, if non-nil, receives each observation as it is made, instead of
+	// the observation being aggregated into stats
+	stream *json.Encoder
+
+	// typeCounts tracks the number of keys observed for each ValueType
+	typeCounts map[ValueType]int64
+
+	// skipped is the number of sampled keys that were not observed
+	skipped int64
+
+	// binaryKeys is the number of observed keys with binary names
+	binaryKeys int64
+
+	// filtered is the number of keys that were not examined because they
+	// belong to another shard (see ShardCount) or hash slot (see HashSlots)
+	filtered int64
+
+	// ungrouped is the number of observed keys for which the Aggregator
+	// returned no groups
+	ungrouped int64
+
+	// nextCursor is the cursor from which a ScanLimit scan can be resumed,
+	// and scanComplete is set once such a scan has covered the keyspace
+	nextCursor   uint64
+	scanComplete bool
+
+	// assignments is the number of group assignments made by the Aggregator
+	// to observed keys
+	assignments int64
+
+	// budgetSkips tracks the number of keys of each ValueType that were
+	// skipped because the PerTypeBudget for the type had been reached
+	budgetSkips map[ValueType]int64
+
+	// vanished is the number of sampled keys that no longer existed by the
+	// time they were to be observed
+	vanished int64
+
+	// growth is the rate, in keys per second, at which the key count changed
+	// over growthWindow, as measured by probeGrowth
+	growth       float64
+	growthWindow time.Duration
+
+	// sizes accumulates the sizes of the observed values, for sampling to a
+	// TargetRelError
+	sizes runningStats
+
+	// pairs retains (cardinality, estimated total bytes) pairs for each
+	// collection type, from which RunSummary.SizeCorrelation is computed
+	pairs map[ValueType]*sizePairs
+
+	// budget, if non-nil, is the MemoryBudget shared by every group's Results
+	budget *memoryBudget
+
+	// counter counts the commands issued on conn, once it is established
+	counter *countingConn
+
+	// warnings describes conditions that may affect the validity of the
+	// results, without preventing sampling
+	warnings []string
+}
+
+func newSampler(opts Options, aggregator Aggregator) *sampler {
+	if opts.Logger == nil {
+		opts.Logger = stdoutLogger{}
+	}
+	switch opts.Verbosity {
+	case VerbositySilent:
+		opts.Logger, opts.Verbose = discardLogger{}, nil
+	case VerbosityDebug:
+		if opts.Verbose == nil {
+			opts.Verbose = opts.Logger
+		}
+	}
+	s := &sampler{
+		opts:        opts,
+		aggregator:  aggregator,
+		stats:       make(map[string]*Results),
+		typeCounts:  make(map[ValueType]int64),
+		budgetSkips: make(map[ValueType]int64),
+		pairs:       make(map[ValueType]*sizePairs),
+	}
+	if opts.MemoryBudget > 0 {
+		s.budget = &memoryBudget{limit: int64(opts.MemoryBudget)}
+	}
+	return s
+}
+
+// newResults constructs the Results for a newly-encountered group
+func (s *sampler) newResults() *Results {
+	r := NewResults()
+	r.Approximate = s.opts.ApproxHistogram
+	r.budget = s.budget
+	r.UniformElementSize, r.UniformMaxCV = s.opts.UniformElementSize, s.opts.UniformMaxCV
+	return r
+}
+
+// An observation is a func that aggregates a single observation of a key
+// into the Results for one of the key's groups.  The `example` is the name
+// of the key, after any KeyTransform, to record in the example sets.
+type observation func(r *Results, example string)
+
+// errVanished is returned by the sample funcs when the key being sampled was
+// found to have been deleted after its type was determined
+var errVanished = errors.New("the key vanished while it was being sampled")
+
+// observe samples the value stored at `key`, aggregating the observation into
+// the sampler's stats
+func (s *sampler) observe(key string, vt ValueType) error {
+	var err error
+	var freq int
+
+	if vt == TypeNone {
+		// the key expired or was deleted after it was sampled
+		return s.vanish(key)
+	}
+
+	// OBJECT FREQ must precede any command that accesses the key, since
+	// accessing the key would bump its access frequency
+	if s.opts.AccessFreq {
+		if freq, err = redis.Int(s.conn.Do("OBJECT", "FREQ", key)); err != nil {
+			if strings.Contains(err.Error(), "LFU") {
+				return fmt.Errorf("AccessFreq requires an LFU maxmemory-policy on the redis instance: %s", err.Error())
+			}
 			return err
 		}
+	}
 
-		for _, g := range aggregator.Groups(key, TypeList) {
-			s := ensureEntry(stats, g, NewResults)
-			s.observeList(key, l, ms[0])
+	var idle int
+	if s.opts.IdleTime || s.opts.MaxIdleTime > 0 {
+		if idle, err = redis.Int(s.conn.Do("OBJECT", "IDLETIME", key)); err != nil {
+			return err
+		}
+		if s.opts.MaxIdleTime > 0 && idle > s.opts.MaxIdleTime {
+			s.skipped++
+			return nil
 		}
 	}
-	return nil
-}
 
-func sampleSet(key string, conn redis.Conn, aggregator Aggregator, stats map[string]*Results) error {
-	conn.Send("SCARD", key)
-	conn.Send("SRANDMEMBER", key)
-	replies, err := flush(conn)
+	if s.opts.OtherTypes && !isSampledType(vt) {
+		if err = s.record(key, vt, 0, func(r *Results, example string) { r.observeOtherType(vt) }); err != nil {
+			return err
+		}
+		s.typeCounts[vt]++
+		return nil
+	}
+
+	var size int
+	var fn observation
+	switch {
+	case s.opts.SizeOnly:
+		size, fn, err = s.sampleSize(key, vt)
+	case vt == TypeString:
+		size, fn, err = s.sampleString(key)
+	case vt == TypeList:
+		size, fn, err = s.sampleList(key)
+	case vt == TypeSet:
+		size, fn, err = s.sampleSet(key)
+	case vt == TypeSortedSet:
+		size, fn, err = s.sampleSortedSet(key)
+	case vt == TypeHash:
+		size, fn, err = s.sampleHash(key)
+	default:
+		return fmt.Errorf("unknown type for redis key: %s", key)
+	}
+	if err == errVanished {
+		return s.vanish(key)
+	}
 	if err != nil {
 		return err
 	}
+	if fn == nil {
+		s.typeCounts[vt]++
+		return nil
+	}
 
-	if len(replies) >= 2 {
-		l, err := redis.Int(replies[0], nil)
-		m, err := redis.String(replies[1], err)
+	if s.opts.AccessFreq {
+		observeValue := fn
+		fn = func(r *Results, example string) {
+			observeValue(r, example)
+			r.AccessFrequencies[freq]++
+		}
+	}
+
+	if s.opts.BucketExamples > 0 {
+		observeValue := fn
+		fn = func(r *Results, example string) {
+			observeValue(r, example)
+			r.observeBucketExample(vt, size, example, s.opts.BucketExamples)
+		}
+	}
+
+	if s.opts.IdleTime {
+		observeValue := fn
+		fn = func(r *Results, example string) {
+			observeValue(r, example)
+			r.IdleTimes[r.bucket(idle)]++
+		}
+	}
+
+	if s.opts.BitmapStats && vt == TypeString {
+		bits, err := redis.Int(s.conn.Do("BITCOUNT", key))
 		if err != nil {
 			return err
 		}
+		observeValue := fn
+		fn = func(r *Results, example string) {
+			observeValue(r, example)
+			r.StringBitCounts[r.bucket(bits)]++
+		}
+	}
+
+	if s.opts.KeyMemory {
+		usage, err := redis.Int(s.conn.Do("MEMORY", "USAGE", key))
+		if err != nil && err != redis.ErrNil {
+			return err
+		}
+		if err == nil {
+			observeValue := fn
+			fn = func(r *Results, example string) {
+				observeValue(r, example)
+				r.KeyMemorySizes[r.bucket(usage)]++
+			}
+		}
+	}
 
-		for _, g := range aggregator.Groups(key, TypeSet) {
-			s := ensureEntry(stats, g, NewResults)
-			s.observeSet(key, l, m)
+	if len(s.opts.ExpectedPatterns) > 0 && !s.expected(key) {
+		observeValue := fn
+		fn = func(r *Results, example string) {
+			observeValue(r, example)
+			r.observeUnmatched(example)
 		}
 	}
+
+	if err = s.record(key, vt, size, fn); err != nil {
+		return err
+	}
+	s.typeCounts[vt]++
+	s.sizes.add(float64(size))
 	return nil
 }
 
-func sampleSortedSet(key string, conn redis.Conn, aggregator Aggregator, stats map[string]*Results) error {
-	conn.Send("ZCARD", key)
-	// TODO: Let's not always get the first element, like the orig. sampler
-	conn.Send("ZRANGE", key, 0, 0)
-	replies, err := flush(conn)
+// groups obtains the groups for `key` from the sampler's Aggregator,
+// vanish counts `key` as having vanished before it could be observed.  The
+// key is charged to the VanishedKeys of each group to which the Aggregator
+// assigns it as a key of TypeNone.
+func (s *sampler) vanish(key string) error {
+	s.vanished++
+	if s.stream != nil {
+		return nil
+	}
+	name := key
+	if s.opts.KeyTransform != nil {
+		name = s.opts.KeyTransform(key)
+	}
+	groups, err := s.groups(name, TypeNone)
 	if err != nil {
 		return err
 	}
+	for _, g := range groups {
+		ensureEntry(s.stats, g, s.newResults).VanishedKeys++
+	}
+	return nil
+}
 
-	if len(replies) >= 2 {
-		l, err := redis.Int(replies[0], nil)
-		ms, err := redis.Strings(replies[1], err)
-		if err != nil {
-			return err
+// converting a panic in the Aggregator into an error that identifies the key
+func (s *sampler) groups(key string, vt ValueType) (groups []string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("aggregator panicked on key %q of type %s: %v", key, vt, r)
 		}
+	}()
+	return s.aggregator.Groups(key, vt), nil
+}
 
-		for _, g := range aggregator.Groups(key, TypeSortedSet) {
-			s := ensureEntry(stats, g, NewResults)
-			s.observeSortedSet(key, l, ms[0])
+// expected reports whether `key` matches any of the ExpectedPatterns
+func (s *sampler) expected(key string) bool {
+	for _, p := range s.opts.ExpectedPatterns {
+		if p.MatchString(key) {
+			return true
 		}
 	}
-	return nil
+	return false
 }
 
-func sampleHash(key string, conn redis.Conn, aggregator Aggregator, stats map[string]*Results) error {
-	conn.Send("HLEN", key)
-	conn.Send("HKEYS", key)
-	replies, err := flush(conn)
+// record assigns an observation of `key` to each of the groups returned by
+// the sampler's Aggregator.  Unless the sampler is streaming observations,
+// `fn` is invoked with the Results for each group.  The `size` is the
+// length of a string value, or the number of members of any other type.  If
+// a KeyTransform is configured, the transformed key is aggregated instead.
+func (s *sampler) record(key string, vt ValueType, size int, fn observation) error {
+	if s.opts.SampleLog != nil {
+		if _, err := fmt.Fprintf(s.opts.SampleLog, "%s\t%q\n", vt, key); err != nil {
+			return err
+		}
+	}
+
+	name := key
+	if s.opts.KeyTransform != nil {
+		name = s.opts.KeyTransform(key)
+	}
+
+	groups, err := s.groups(name, vt)
 	if err != nil {
 		return err
 	}
+	if len(groups) == 0 {
+		s.ungrouped++
+	}
+	s.assignments += int64(len(groups))
+	if s.opts.Verbose != nil {
+		s.opts.Verbose.Printf("observed key: %q type: %s groups: %q\n", key, vt, groups)
+	}
 
-	if len(replies) >= 2 {
-		for _, g := range aggregator.Groups(key, TypeHash) {
+	example := name
+	if binaryKey(name) {
+		example = EscapeKey(name)
+		s.binaryKeys++
+		observeValue := fn
+		fn = func(r *Results, example string) {
+			observeValue(r, example)
+			r.observeBinaryKey(example)
+		}
+	}
 
-			// TODO: Let's not always get the first hash field, like the orig. sampler
-			l, err := redis.Int(replies[0], nil)
-			fields, err := redis.Strings(replies[1], err)
-			if err != nil {
-				return err
-			}
-			val, err := redis.String(conn.Do("HGET", key, fields[0]))
-			if err != nil {
+	for _, g := range groups {
+		if s.stream != nil {
+			o := Observation{Group: g, Key: example, Type: vt, Size: size, Time: time.Now()}
+			if err := s.stream.Encode(o); err != nil {
 				return err
 			}
-			s := ensureEntry(stats, g, NewResults)
-			s.observeHash(key, l, fields[0], val)
+			continue
 		}
+		fn(ensureEntry(s.stats, g, s.newResults), example)
 	}
 	return nil
 }
 
-func max(a, b int) int {
-	if a > b {
-		return a
-	}
-	return b
+// sizeCommands maps each ValueType to the redis command that returns the size
+// of a value of that type, without transferring the value itself
+var sizeCommands = map[ValueType]string{
+	TypeString:    "STRLEN",
+	TypeList:      "LLEN",
+	TypeSet:       "SCARD",
+	TypeSortedSet: "ZCARD",
+	TypeHash:      "HLEN",
 }
 
-// Run performs the configured sampling operation against the redis instance,
-// returning aggregated statistics using the provided Aggregator, as well as
-// the actual key count for the redis instance.  If any errors occur, the
-// sampling is short-circuited, and the error is returned.  In such a case, the
-// results should be considered invalid.
-func Run(opts Options, aggregator Aggregator) (map[string]*Results, int64, error) {
-
-	stats := make(map[string]*Results)
-	var err error
-	var keys int64
+// sampleSize records only the size of the value stored at `key`
+func (s *sampler) sampleSize(key string, vt ValueType) (int, observation, error) {
+	cmd, ok := sizeCommands[vt]
+	if !ok {
+		return 0, nil, fmt.Errorf("unknown type for redis key: %s", key)
+	}
 
-	if opts.SampleRate < 0.0 || opts.SampleRate > 1.0 {
-		return stats, keys, errors.New("SampleRate must be between 0.0 and 1.0")
+	size, err := redis.Int(s.conn.Do(cmd, key))
+	if err != nil {
+		return 0, nil, err
 	}
 
-	if opts.MinSamples <= 0 && opts.SampleRate == 0.0 {
-		return stats, keys, errors.New("MinSamples cannot be 0")
+	return size, func(r *Results, example string) {
+		r.observeSize(example, vt, size)
+	}, nil
+}
+
+func (s *sampler) sampleString(key string) (int, observation, error) {
+	if s.opts.MaxFetchBytes > 0 {
+		size, err := redis.Int(s.conn.Do("STRLEN", key))
+		if err != nil {
+			return 0, nil, err
+		}
+		if size > s.opts.MaxFetchBytes {
+			return size, func(r *Results, example string) {
+				r.observeSize(example, TypeString, size)
+			}, nil
+		}
 	}
 
-	conn, err := redis.Dial("tcp", net.JoinHostPort(opts.Host, strconv.Itoa(opts.Port)))
+	val, err := redis.String(s.conn.Do("GET", key))
 	if err != nil {
-		return stats, keys, fmt.Errorf("Error connecting to the redis instance at: %s:%d : %s", opts.Host, opts.Port, err.Error())
+		return 0, nil, err
 	}
 
-	numSamples := opts.MinSamples
+	return len(val), func(r *Results, example string) {
+		r.observeString(example, val, prefix(val, s.opts.StringExampleBytes))
+	}, nil
+}
 
-	if keys, err = keyCount(conn); err != nil {
-		return stats, keys, err
-	}
+// elementsPerKey returns the number of elements to sample from each
+// collection, which is always at least 1
+func (s *sampler) elementsPerKey() int {
+	return max(s.opts.ElementsPerKey, 1)
+}
 
-	fmt.Printf("redis at %s:%d has %d keys\n", opts.Host, opts.Port, keys)
-	if opts.SampleRate > 0.0 {
-		v := int(float32(keys) * opts.SampleRate)
-		numSamples = max(max(v, numSamples), 1)
+func (s *sampler) sampleList(key string) (int, observation, error) {
+	// TODO: Let's not always get the first elements, like the orig. reckon
+	s.conn.Send("LLEN", key)
+	s.conn.Send("LRANGE", key, 0, s.elementsPerKey()-1)
+	if s.opts.ListEnds {
+		s.conn.Send("LINDEX", key, 0)
+		s.conn.Send("LINDEX", key, -1)
 	}
-
-	interval := numSamples / 100
-	if interval == 0 {
-		interval = 1
+	replies, err := flush(s.conn)
+	if err != nil {
+		return 0, nil, err
 	}
-	lastInterval := 0
 
-	for i := 0; i < numSamples; i++ {
-		key, vt, err := randomKey(conn)
-		if err != nil {
-			return stats, keys, err
-		}
+	if len(replies) >= 2 {
+		l, err := redis.Int(replies[0], nil)
+		ms, err := redis.Strings(replies[1], err)
+		if err != nil {
+			return 0, nil, err
+		}
 
-		if i/interval != lastInterval {
-			fmt.Printf("sampled %d keys from redis at: %s:%d...\n", i, opts.Host, opts.Port)
-			lastInterval = i / interval
+		var ends []string
+		if s.opts.ListEnds && len(replies) >= 4 {
+			if ends, err = redis.Strings(replies[2:4], nil); err != nil {
+				return 0, nil, err
+			}
 		}
 
-		switch ValueType(vt) {
-		case TypeString:
-			if err = sampleString(key, conn, aggregator, stats); err != nil {
-				return stats, keys, err
+		sizes, err := s.elementSizes(key, l, ms)
+		if err != nil {
+			return 0, nil, err
+		}
+		classes := s.elementClasses(ms)
+		estimate := s.estimateBytes(l, ms)
+		s.pair(TypeList, l, elementBytes(l, ms))
+		return l, s.checkUniform(ms, func(r *Results, example string) {
+			r.observeListSizes(example, l, ms, sizes)
+			observeClasses(r.ListElementClassSizes, classes, ms, r.bucket)
+			if estimate >= 0 {
+				r.ListByteSizes[r.bucket(estimate)]++
 			}
-		case TypeList:
-			if err = sampleList(key, conn, aggregator, stats); err != nil {
-				return stats, keys, err
+			if len(ends) == 2 {
+				r.ListHeadSizes[r.bucket(len(ends[0]))]++
+				r.ListTailSizes[r.bucket(len(ends[1]))]++
 			}
-		case TypeSet:
-			if err = sampleSet(key, conn, aggregator, stats); err != nil {
-				return stats, keys, err
+		}), nil
+	}
+	return 0, nil, nil
+}
+
+// sampleSet samples up to ElementsPerKey members of the set stored at `key`.
+// SRANDMEMBER is given a positive count, so the members returned are
+// distinct, and fewer members than requested are returned when the set is
+// smaller than the count.  Each member is therefore observed exactly once,
+// which keeps the element size distribution unbiased.
+func (s *sampler) sampleSet(key string) (int, observation, error) {
+	s.conn.Send("SCARD", key)
+	s.conn.Send("SRANDMEMBER", key, s.elementsPerKey())
+	replies, err := flush(s.conn)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if len(replies) >= 2 {
+		l, err := redis.Int(replies[0], nil)
+		ms, err := redis.Strings(replies[1], err)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		sizes, err := s.elementSizes(key, l, ms)
+		if err != nil {
+			return 0, nil, err
+		}
+		classes := s.elementClasses(ms)
+		estimate := s.estimateBytes(l, ms)
+		s.pair(TypeSet, l, elementBytes(l, ms))
+		return l, s.checkUniform(ms, func(r *Results, example string) {
+			r.observeSetSizes(example, l, ms, sizes)
+			observeClasses(r.SetElementClassSizes, classes, ms, r.bucket)
+			if estimate >= 0 {
+				r.SetByteSizes[r.bucket(estimate)]++
 			}
-		case TypeSortedSet:
-			if err = sampleSortedSet(key, conn, aggregator, stats); err != nil {
-				return stats, keys, err
+		}), nil
+	}
+	return 0, nil, nil
+}
+
+func (s *sampler) sampleSortedSet(key string) (int, observation, error) {
+	s.conn.Send("ZCARD", key)
+	// TODO: Let's not always get the first elements, like the orig. sampler
+	s.conn.Send("ZRANGE", key, 0, s.elementsPerKey()-1)
+	replies, err := flush(s.conn)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if len(replies) >= 2 {
+		l, err := redis.Int(replies[0], nil)
+		ms, err := redis.Strings(replies[1], err)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		sizes, err := s.elementSizes(key, l, ms)
+		if err != nil {
+			return 0, nil, err
+		}
+		classes := s.elementClasses(ms)
+		estimate := s.estimateBytes(l, ms)
+		s.pair(TypeSortedSet, l, elementBytes(l, ms))
+		return l, s.checkUniform(ms, func(r *Results, example string) {
+			r.observeSortedSetSizes(example, l, ms, sizes)
+			observeClasses(r.SortedSetElementClassSizes, classes, ms, r.bucket)
+			if estimate >= 0 {
+				r.SortedSetByteSizes[r.bucket(estimate)]++
+			}
+		}), nil
+	}
+	return 0, nil, nil
+}
+
+func (s *sampler) sampleHash(key string) (int, observation, error) {
+	s.conn.Send("HLEN", key)
+	s.conn.Send("HKEYS", key)
+	replies, err := flush(s.conn)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if len(replies) >= 2 {
+		// TODO: Let's not always get the first hash fields, like the orig. sampler
+		l, err := redis.Int(replies[0], nil)
+		fields, err := redis.Strings(replies[1], err)
+		if err != nil {
+			return 0, nil, err
+		}
+		if len(fields) == 0 {
+			// redis removes empty hashes, so the hash was deleted after
+			// its type was determined
+			return 0, nil, errVanished
+		}
+		if len(fields) > s.elementsPerKey() {
+			fields = fields[:s.elementsPerKey()]
+		}
+
+		vals, sizes, err := s.hashValues(key, fields)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		var classes [][]string
+		if s.opts.HashFieldAggregator != nil {
+			classes = classify(s.opts.HashFieldAggregator.Classes, fields)
+		}
+
+		estimate := -1
+		if s.opts.HashByteEstimates || s.opts.ByteEstimates {
+			estimate = estimateHashBytes(l, fields, sizes)
+		}
+		if len(fields) > 0 {
+			s.pair(TypeHash, l, estimateHashBytes(l, fields, sizes))
+		}
+
+		return l, s.checkUniform(fields, func(r *Results, example string) {
+			r.observeHashSizes(example, l, fields, vals, sizes)
+			observeClassSizes(r.HashFieldValueSizes, classes, sizes, r.bucket)
+			if estimate >= 0 {
+				r.HashByteSizes[r.bucket(estimate)]++
 			}
-		case TypeHash:
-			if err = sampleHash(key, conn, aggregator, stats); err != nil {
-				return stats, keys, err
+		}), nil
+	}
+	return 0, nil, nil
+}
+
+// checkUniform wraps `fn` to also record the key as non-uniform if any of its
+// sampled `elements` is not UniformElementSize bytes long
+func (s *sampler) checkUniform(elements []string, fn observation) observation {
+	if s.opts.UniformElementSize <= 0 {
+		return fn
+	}
+	for _, e := range elements {
+		if len(e) != s.opts.UniformElementSize {
+			return func(r *Results, example string) {
+				fn(r, example)
+				r.observeNonUniform(example)
 			}
-		default:
-			return stats, keys, fmt.Errorf("unknown type for redis key: %s", key)
 		}
 	}
-	return stats, keys, nil
+	return fn
+}
+
+// classify returns the classes of each of `elements`
+func classify(classes func(string) []string, elements []string) [][]string {
+	cs := make([][]string, len(elements))
+	for i, e := range elements {
+		cs[i] = classes(e)
+	}
+	return cs
+}
+
+// elementClasses returns the classes assigned to each of `members` by the
+// ElementAggregator, if any
+func (s *sampler) elementClasses(members []string) [][]string {
+	if s.opts.ElementAggregator == nil {
+		return nil
+	}
+	return classify(s.opts.ElementAggregator.Classes, members)
+}
+
+// hashValues fetches the values of `fields` of the hash at `key`, along with
+// their sizes.  If MaxFetchBytes is set, the sizes are obtained first with
+// `HSTRLEN`, and values larger than MaxFetchBytes are not fetched, but left
+// empty.
+func (s *sampler) hashValues(key string, fields []string) ([]string, []int, error) {
+	if s.opts.MaxFetchBytes <= 0 {
+		vals, err := redis.Strings(s.conn.Do("HMGET", redis.Args{}.Add(key).AddFlat(fields)...))
+		return vals, lengths(vals), err
+	}
+
+	for _, f := range fields {
+		s.conn.Send("HSTRLEN", key, f)
+	}
+	replies, err := flush(s.conn)
+	if err != nil {
+		return nil, nil, err
+	}
+	sizes, err := redis.Ints(replies, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	vals := make([]string, len(fields))
+	var fetch []string
+	var indexes []int
+	for i, f := range fields {
+		if i < len(sizes) && sizes[i] <= s.opts.MaxFetchBytes {
+			fetch = append(fetch, f)
+			indexes = append(indexes, i)
+		}
+	}
+	if len(fetch) > 0 {
+		fetched, err := redis.Strings(s.conn.Do("HMGET", redis.Args{}.Add(key).AddFlat(fetch)...))
+		if err != nil {
+			return nil, nil, err
+		}
+		for j, v := range fetched {
+			vals[indexes[j]] = v
+		}
+	}
+	return vals, sizes, nil
+}
+
+// estimateHashBytes estimates the total length of the fields and values of a
+// hash with `length` fields, from a sample of its fields and the sizes of
+// their values
+func estimateHashBytes(length int, fields []string, sizes []int) int {
+	if len(fields) == 0 {
+		return 0
+	}
+	var total int
+	for i, f := range fields {
+		total += len(f)
+		if i < len(sizes) {
+			total += sizes[i]
+		}
+	}
+	return int(float64(total) * float64(length) / float64(len(fields)))
+}
+
+// elementSizes returns the size to record for each of `members`, sampled from
+// the collection of `length` elements at `key`: its length, or with
+// ElementSizeFromObject, the `MEMORY USAGE` of the collection divided by
+// `length`.  If the key has vanished, the lengths are returned.
+func (s *sampler) elementSizes(key string, length int, members []string) ([]int, error) {
+	sizes := lengths(members)
+	if !s.opts.ElementSizeFromObject || length == 0 {
+		return sizes, nil
+	}
+	usage, err := redis.Int(s.conn.Do("MEMORY", "USAGE", key))
+	if err == redis.ErrNil {
+		return sizes, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range sizes {
+		sizes[i] = usage / length
+	}
+	return sizes, nil
+}
+
+// estimateBytes estimates the total length of the elements of a collection of
+// `length` elements from a sample of them, `elements`, or returns -1 if no
+// estimate is wanted
+func (s *sampler) estimateBytes(length int, elements []string) int {
+	if !s.opts.ByteEstimates {
+		return -1
+	}
+	return elementBytes(length, elements)
+}
+
+// elementBytes estimates the total length of the elements of a collection of
+// `length` elements from a sample of them, `elements`, or returns -1 if the
+// sample is empty
+func elementBytes(length int, elements []string) int {
+	if len(elements) == 0 {
+		return -1
+	}
+	var total int
+	for _, e := range elements {
+		total += len(e)
+	}
+	return int(float64(total) * float64(length) / float64(len(elements)))
+}
+
+// pair retains the `cardinality` and estimated total `bytes` of a collection
+// of type `vt`, unless no estimate could be made
+func (s *sampler) pair(vt ValueType, cardinality, bytes int) {
+	if bytes < 0 {
+		return
+	}
+	p, ok := s.pairs[vt]
+	if !ok {
+		p = &sizePairs{}
+		s.pairs[vt] = p
+	}
+	p.add(cardinality, bytes)
+}
+
+// stratify tops up the sample so that at least `perType` keys of every
+// ValueType present in the redis instance have been observed.  Keys of each
+// under-represented type are located with `SCAN ... TYPE`, which requires
+// redis 6.0 or later.
+func (s *sampler) stratify(perType int) error {
+	for _, vt := range sampledTypes {
+		if err := s.topUp(vt, perType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sampledTypes are the ValueTypes that reckon observes
+var sampledTypes = []ValueType{TypeString, TypeList, TypeSet, TypeSortedSet, TypeHash}
+
+// topUp observes keys of type `vt`, located with `SCAN ... TYPE`, until at
+// least `n` keys of that type have been observed, or no more can be found
+func (s *sampler) topUp(vt ValueType, n int) error {
+	cursor := 0
+	for s.typeCounts[vt] < int64(n) {
+		replies, err := redis.Values(s.conn.Do("SCAN", cursor, "COUNT", 1000, "TYPE", string(vt)))
+		if err != nil {
+			return err
+		}
+		var keys []string
+		if _, err := redis.Scan(replies, &cursor, &keys); err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if s.typeCounts[vt] >= int64(n) {
+				break
+			}
+			if err := s.observe(key, vt); err != nil {
+				return err
+			}
+		}
+		if cursor == 0 {
+			break
+		}
+	}
+	return nil
+}
+
+// pilotCount returns the number of keys to sample at random, to discover the
+// mix of types in the keyspace, before allocating `numSamples` among them
+func pilotCount(numSamples int) int {
+	return max(numSamples/10, 1)
+}
+
+// allocate divides `numSamples` among the ValueTypes according to
+// `allocation`, based on the mix of types observed so far, and tops up the
+// sample so that each type receives its share.  Every type receives at least
+// one key, if any exist, even if none were seen by the pilot sample.
+func (s *sampler) allocate(allocation Allocation, numSamples int) error {
+	var observed int64
+	for _, vt := range sampledTypes {
+		observed += s.typeCounts[vt]
+	}
+
+	for _, vt := range sampledTypes {
+		share := numSamples / len(sampledTypes)
+		if allocation == ProportionalAllocation && observed > 0 {
+			share = int(float64(numSamples) * float64(s.typeCounts[vt]) / float64(observed))
+		}
+		if err := s.topUp(vt, max(share, 1)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// observeAll observes every key matching the glob-style pattern `glob`, as
+// returned by a single `KEYS` command
+func (s *sampler) observeAll(glob string) error {
+	keys, err := redis.Strings(s.conn.Do("KEYS", glob))
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		warning := fmt.Sprintf("no keys match the glob %q; it may be mis-specified", glob)
+		s.opts.Logger.Printf("WARNING: %s\n", warning)
+		s.warnings = append(s.warnings, warning)
+	}
+	return s.observeSource(NewKeyList(keys))
+}
+
+// unknownLenInterval is the number of keys between progress reports when
+// observing a KeySource that cannot report its Len
+const unknownLenInterval = 1000
+
+// observeSource observes every key supplied by `src`
+func (s *sampler) observeSource(src KeySource) error {
+	var progress Progress
+	interval := unknownLenInterval
+	if n, ok := src.Len(); ok {
+		progress.Planned, progress.TotalKeys = int(n), n
+		interval = progressInterval(s.opts, int(n))
+	} else if s.opts.ProgressInterval > 0 {
+		interval = s.opts.ProgressInterval
+	}
+
+	for i := 0; ; i++ {
+		key, ok, err := src.Next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		if i > 0 && i%interval == 0 {
+			s.reportProgress(progress, i)
+		}
+		progress.Sampled = i + 1
+
+		if s.opts.ShardCount > 1 && keyShard(key, s.opts.ShardCount) != s.opts.ShardID || !s.inHashSlots(key) {
+			s.filtered++
+			continue
+		}
+
+		vt, err := keyType(s.conn, key)
+		if err != nil {
+			return err
+		}
+
+		if s.overBudget(vt) {
+			s.skipBudgeted(vt)
+			continue
+		}
+
+		if err = s.observe(key, vt); err != nil {
+			return err
+		}
+	}
+
+	if s.opts.Progress != nil {
+		progress.Done = true
+		s.opts.Progress(progress)
+	}
+	return nil
+}
+
+// keyShard returns the shard, from 0 to `shards`-1, to which `key` belongs,
+// using its 32-bit FNV-1a hash
+func keyShard(key string, shards int) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(shards))
+}
+
+// maxConsecutiveVanished is the number of consecutive random keys that may
+// have vanished before sampling concludes that the instance is effectively
+// empty.  Lazily-expired keys are still returned by `RANDOMKEY`, but have the
+// type `none`.
+const maxConsecutiveVanished = 100
+
+// randomLiveKey obtains a random key that still exists, and its ValueType.
+// Keys that vanished before their type could be determined are counted and
+// replaced, and ErrNoKeys is returned if maxConsecutiveVanished keys in a row
+// have vanished.
+func (s *sampler) randomLiveKey() (string, ValueType, error) {
+	for i := 0; i < maxConsecutiveVanished; i++ {
+		key, vt, err := randomKey(s.conn)
+		if err != nil || vt != TypeNone {
+			return key, vt, err
+		}
+		if err = s.vanish(key); err != nil {
+			return key, vt, err
+		}
+	}
+	return "", TypeNone, ErrNoKeys
+}
+
+// burnIn samples and discards `n` random keys
+func (s *sampler) burnIn(n int) error {
+	for i := 0; i < n; i++ {
+		if _, _, err := randomKey(s.conn); err != nil {
+			return err
+		}
+	}
+	if n > 0 {
+		s.opts.Logger.Printf("discarded %d burn-in keys from redis at: %s:%d\n", n, s.opts.Host, s.opts.Port)
+	}
+	return nil
+}
+
+// progressInterval returns the number of keys between progress reports when
+// sampling `n` keys
+func progressInterval(opts Options, n int) int {
+	if opts.ProgressInterval > 0 {
+		return opts.ProgressInterval
+	}
+	if n/100 == 0 {
+		return 1
+	}
+	return n / 100
+}
+
+// reportProgress logs that `sampled` keys have been sampled, and passes
+// `progress` to the Progress callback, if any
+func (s *sampler) reportProgress(progress Progress, sampled int) {
+	s.opts.Logger.Printf("sampled %d keys from redis at: %s:%d...\n", sampled, s.opts.Host, s.opts.Port)
+	if s.opts.Progress != nil {
+		s.opts.Progress(progress)
+	}
+}
+
+// minPrecisionSamples is the fewest keys sampled to a TargetRelError, below
+// which the normal approximation of the confidence interval is unreliable
+const minPrecisionSamples = 30
+
+// precise reports whether, having sampled `n` keys, the TargetRelError (if
+// any) has been reached
+func (s *sampler) precise(n int) bool {
+	if s.opts.TargetRelError <= 0.0 || n < max(s.opts.MinSamples, minPrecisionSamples) {
+		return false
+	}
+	ci := s.sizes.meanCI(zScore(s.opts.TargetConfidence))
+	return ci <= s.opts.TargetRelError*s.sizes.mean
+}
+
+// overBudget reports whether the configured budget for ValueType `vt` has
+// been exhausted
+func (s *sampler) overBudget(vt ValueType) bool {
+	budget, ok := s.opts.PerTypeBudget[vt]
+	return ok && s.typeCounts[vt] >= int64(budget)
+}
+
+// skipBudgeted records that a key of type `vt` was skipped because its
+// budget had been reached
+func (s *sampler) skipBudgeted(vt ValueType) {
+	s.skipped++
+	s.budgetSkips[vt]++
+}
+
+// budgetsMet reports whether every configured per-type budget has been
+// exhausted
+func (s *sampler) budgetsMet() bool {
+	if len(s.opts.PerTypeBudget) == 0 {
+		return false
+	}
+	for vt := range s.opts.PerTypeBudget {
+		if !s.overBudget(vt) {
+			return false
+		}
+	}
+	return true
+}
+
+// observed returns the number of keys observed so far
+func (s *sampler) observed() int64 {
+	var observed int64
+	for _, n := range s.typeCounts {
+		observed += n
+	}
+	return observed
+}
+
+// sampled returns the number of keys sampled so far, including those that
+// were skipped or had vanished
+func (s *sampler) sampled() int64 {
+	return s.observed() + s.skipped + s.vanished
+}
+
+// summarize populates the RunSummary supplied via Options, if any
+func (s *sampler) summarize(keys int64, elapsed time.Duration) {
+	if s.opts.Summary == nil {
+		return
+	}
+
+	observed := s.observed()
+	s.opts.Summary.Address = net.JoinHostPort(s.opts.Host, strconv.Itoa(s.opts.Port))
+	s.opts.Summary.TotalKeys = keys
+	s.opts.Summary.Sampled = s.sampled()
+	s.opts.Summary.TypeCounts = s.typeCounts
+	s.opts.Summary.Skipped = s.skipped
+	s.opts.Summary.OverBudget = s.budgetSkips
+	s.opts.Summary.Vanished = s.vanished
+	s.opts.Summary.Funnel = Funnel{
+		Examined:  s.opts.Summary.Sampled + s.filtered,
+		Filtered:  s.filtered,
+		Vanished:  s.vanished,
+		Skipped:   s.skipped,
+		Ungrouped: s.ungrouped,
+		Observed:  observed - s.ungrouped,
+	}
+	s.opts.Summary.SizeCorrelation = make(map[ValueType]float64)
+	for vt, p := range s.pairs {
+		if c := p.correlation(); !math.IsNaN(c) {
+			s.opts.Summary.SizeCorrelation[vt] = c
+		}
+	}
+	s.opts.Summary.Duration = elapsed
+	s.opts.Summary.Warnings = s.warnings
+	if s.binaryKeys > 0 {
+		s.opts.Summary.Warnings = append(s.opts.Summary.Warnings, fmt.Sprintf("%d observed keys have binary (non-UTF-8 or non-printable) names", s.binaryKeys))
+	}
+	if s.budget != nil && s.budget.exhausted {
+		s.opts.Summary.MemoryBudgetReached = true
+		s.opts.Summary.Warnings = append(s.opts.Summary.Warnings, fmt.Sprintf("the MemoryBudget of %d bytes was reached; later examples were discarded", s.budget.limit))
+	}
+	s.opts.Summary.GroupAssignments = s.assignments
+	if observed > 0 {
+		s.opts.Summary.GroupsPerKey = float64(s.assignments) / float64(observed)
+	}
+	if s.counter != nil {
+		s.opts.Summary.Commands = s.counter.commands
+		if observed > 0 {
+			s.opts.Summary.CommandsPerKey = float64(s.counter.commands) / float64(observed)
+		}
+	}
+	if s.opts.ScanLimit > 0 {
+		s.opts.Summary.NextCursor, s.opts.Summary.ScanComplete = s.nextCursor, s.scanComplete
+	}
+	s.opts.Summary.KeyGrowthRate = s.growth
+	s.opts.Summary.GrowthWindow = s.growthWindow
+}
+
+// probeGrowth takes a second reading of the key count, at least GrowthProbe
+// after the first reading of `keys` keys at `start`, and records the rate at
+// which the key count changed in between.  If the second reading fails, a
+// warning is recorded instead.
+func (s *sampler) probeGrowth(keys int64, start time.Time) {
+	time.Sleep(s.opts.GrowthProbe - time.Since(start))
+
+	end, err := countKeys(s.opts, s.conn)
+	window := time.Since(start)
+	if err != nil && err != ErrNoKeys {
+		s.warnings = append(s.warnings, fmt.Sprintf("could not measure key count growth: %s", err.Error()))
+		return
+	}
+	s.growth = float64(end-keys) / window.Seconds()
+	s.growthWindow = window
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// validateSampling returns an error if `opts` does not describe a valid
+// number of keys to sample
+func validateSampling(opts Options) error {
+	if opts.SampleRate < 0.0 || opts.SampleRate > 1.0 {
+		return errors.New("SampleRate must be between 0.0 and 1.0")
+	}
+
+	if opts.TargetRelError > 0.0 && (opts.TargetConfidence <= 0.0 || opts.TargetConfidence >= 1.0) {
+		return errors.New("TargetConfidence must be between 0.0 and 1.0")
+	}
+
+	if opts.MinSamples <= 0 && opts.SampleRate == 0.0 && opts.TargetRelError <= 0.0 && opts.KeysGlob == "" && opts.KeySource == nil && len(opts.HashSlots) == 0 && opts.ScanLimit <= 0 {
+		return errors.New("MinSamples cannot be 0")
+	}
+	return nil
+}
+
+// sampleCount returns the number of keys to sample from a redis instance
+// containing `keys` keys: the greater of MinSamples and the number of keys
+// implied by SampleRate.  When sampling to a TargetRelError, it is the most
+// keys that may be sampled.
+func sampleCount(opts Options, keys int64) int {
+	numSamples := opts.MinSamples
+	if opts.TargetRelError > 0.0 {
+		if opts.MaxSamples > 0 {
+			return max(opts.MaxSamples, numSamples)
+		}
+		return max(int(keys), numSamples)
+	}
+	if opts.SampleRate > 0.0 {
+		v := int(float32(keys) * opts.SampleRate)
+		numSamples = max(max(v, numSamples), 1)
+	}
+	return numSamples
+}
+
+// authenticate authenticates `conn` with the credentials obtained from
+// `provider`, if non-nil
+func authenticate(conn redis.Conn, provider func() (user, pass string, err error)) error {
+	if provider == nil {
+		return nil
+	}
+	user, pass, err := provider()
+	if err != nil {
+		return fmt.Errorf("Error obtaining redis credentials: %s", err.Error())
+	}
+
+	if user == "" {
+		_, err = conn.Do("AUTH", pass)
+	} else {
+		_, err = conn.Do("AUTH", user, pass)
+	}
+	return err
+}
+
+// dial connects to the redis instance configured by `opts`, retrying up to
+// ConnectAttempts times in total if the connection cannot be established
+func dial(opts Options) (redis.Conn, error) {
+	addr := net.JoinHostPort(opts.Host, strconv.Itoa(opts.Port))
+	backoff := opts.ConnectBackoff
+
+	var dialOpts []redis.DialOption
+	if opts.Dialer != nil {
+		dialOpts = append(dialOpts, redis.DialNetDial(opts.Dialer.Dial))
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		var conn redis.Conn
+		if conn, err = redis.Dial("tcp", addr, dialOpts...); err == nil {
+			if err = authenticate(conn, opts.AuthProvider); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			if opts.ReadOnly {
+				if _, err = conn.Do("READONLY"); err != nil {
+					conn.Close()
+					return nil, err
+				}
+			}
+			return conn, nil
+		}
+		if attempt >= opts.ConnectAttempts {
+			break
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+	return nil, fmt.Errorf("Error connecting to the redis instance at: %s:%d : %s", opts.Host, opts.Port, err.Error())
+}
+
+// run connects to the configured redis instance and performs the sampling
+// operation, returning the key count for the redis instance
+func (s *sampler) run() (keys int64, err error) {
+
+	opts := s.opts
+	start := time.Now()
+
+	span := s.startSpan("reckon.run")
+	span.SetAttribute("host", opts.Host)
+	span.SetAttribute("port", opts.Port)
+	span.SetAttribute("mode", samplingMode(opts))
+	defer func() {
+		span.SetAttribute("sampled", s.sampled())
+		span.End(err)
+	}()
+	defer func() { s.summarize(keys, time.Since(start)) }()
+
+	if err = validateSampling(opts); err != nil {
+		return keys, err
+	}
+
+	connect := s.startSpan("reckon.connect")
+	conn, err := dial(opts)
+	connect.End(err)
+	if err != nil {
+		return keys, err
+	}
+	s.counter = &countingConn{Conn: conn}
+	s.conn = s.counter
+	defer s.conn.Close()
+
+	if !opts.AllowMaster {
+		role, err := replicationRole(s.conn)
+		if err != nil {
+			return keys, err
+		}
+		if role == "master" {
+			return keys, ErrMaster
+		}
+	}
+
+	if opts.IdleTime || opts.MaxIdleTime > 0 {
+		if err = checkIdleTimePolicy(s.conn); err != nil {
+			return keys, err
+		}
+	}
+
+	count := s.startSpan("reckon.keyCount")
+	keys, err = countKeys(opts, s.conn)
+	count.End(err)
+	if err != nil {
+		return keys, err
+	}
+	if opts.GrowthProbe > 0 {
+		// there is no use waiting for a second reading if sampling failed
+		countStart := time.Now()
+		defer func() {
+			if err == nil {
+				s.probeGrowth(keys, countStart)
+			}
+		}()
+	}
+
+	sample := s.startSpan("reckon.sample")
+	defer func() { sample.End(err) }()
+
+	opts.Logger.Printf("redis at %s:%d has %d keys\n", opts.Host, opts.Port, keys)
+	if opts.KeysGlob != "" {
+		return keys, s.observeAll(opts.KeysGlob)
+	}
+	if opts.KeySource != nil {
+		return keys, s.observeSource(opts.KeySource)
+	}
+	if len(opts.HashSlots) > 0 {
+		return keys, s.observeSlots(opts.HashSlots)
+	}
+	if opts.ScanLimit > 0 {
+		return keys, s.observeScan()
+	}
+
+	numSamples := sampleCount(opts, keys)
+	if int64(numSamples) > keys {
+		s.warnings = append(s.warnings, fmt.Sprintf("%d samples exceeds the key count of %d; keys will be sampled more than once", numSamples, keys))
+	}
+
+	if err = s.burnIn(opts.BurnIn); err != nil {
+		return keys, err
+	}
+
+	numRandom := numSamples
+	if opts.Allocation != NoAllocation {
+		numRandom = pilotCount(numSamples)
+	}
+
+	interval := progressInterval(opts, numRandom)
+	lastInterval := 0
+	progress := Progress{Planned: numRandom, TotalKeys: keys}
+
+	for i := 0; i < numRandom && !s.budgetsMet() && !s.precise(i); i++ {
+		key, vt, err := s.randomLiveKey()
+		if err != nil {
+			return keys, err
+		}
+		progress.Sampled = i + 1
+
+		if i/interval != lastInterval {
+			s.reportProgress(progress, i)
+			lastInterval = i / interval
+		}
+
+		if s.overBudget(vt) {
+			s.skipBudgeted(vt)
+			continue
+		}
+
+		if err = s.observe(key, vt); err != nil {
+			return keys, err
+		}
+	}
+
+	if opts.Progress != nil {
+		progress.Done = true
+		opts.Progress(progress)
+	}
+
+	if opts.Allocation != NoAllocation {
+		if err = s.allocate(opts.Allocation, numSamples); err != nil {
+			return keys, err
+		}
+	}
+
+	if opts.StratifiedPerType > 0 {
+		if err = s.stratify(opts.StratifiedPerType); err != nil {
+			return keys, err
+		}
+	}
+	return keys, nil
+}
+
+// estimatePopulations sets the TotalKeys of each group to an estimate of the
+// number of the `keys` keys in the redis instance that belong to the group:
+// its share of the keys sampled, scaled to the keyspace
+func (s *sampler) estimatePopulations(keys int64) {
+	sampled := s.sampled()
+	for _, r := range s.stats {
+		if sampled == 0 {
+			r.TotalKeys = 0
+			continue
+		}
+		r.TotalKeys = int64(math.Round(float64(keys) * float64(r.KeyCount+r.VanishedKeys) / float64(sampled)))
+	}
+}
+
+// Run performs the configured sampling operation against the redis instance,
+// returning aggregated statistics using the provided Aggregator, as well as
+// the actual key count for the redis instance.  If any errors occur, the
+// sampling is short-circuited, and the error is returned.  In such a case, the
+// results should be considered invalid.
+func Run(opts Options, aggregator Aggregator) (map[string]*Results, int64, error) {
+	s := newSampler(opts, aggregator)
+	keys, err := s.run()
+	s.estimatePopulations(keys)
+	return s.stats, keys, err
+}
+
+// PlanSamples connects to the redis instance configured by `fns` (see
+// NewOptions) and returns the number of keys that Run would sample, along
+// with the key count for the redis instance, without sampling any keys.
+// Note that if PerTypeBudget is configured, sampling may end before
+// numSamples keys have been sampled.
+func PlanSamples(fns ...func(*Options) error) (numSamples int, totalKeys int64, err error) {
+	opts, err := NewOptions(fns...)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if err = validateSampling(opts); err != nil {
+		return 0, 0, err
+	}
+
+	conn, err := dial(opts)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer conn.Close()
+
+	if totalKeys, err = countKeys(opts, conn); err != nil {
+		return 0, totalKeys, err
+	}
+	return sampleCount(opts, totalKeys), totalKeys, nil
+}
+
+// Reckon samples the redis instance configured by `fns` (see NewOptions),
+// aggregating keys with the provided Aggregator, and renders the results to
+// `w`.  The `format` may be one of "html", "text" or "json".  One report is
+// rendered per aggregation group, ordered by group name, unless the
+// WithMergedGroups option was given, in which case all groups are merged into
+// a single report.  Note that keys assigned to multiple groups are counted
+// once per group in a merged report.
+func Reckon(format string, w io.Writer, aggregator Aggregator, fns ...func(*Options) error) error {
+
+	var render func(*Results, io.Writer, ...func(*RenderOptions) error) error
+	switch format {
+	case "html":
+		render = RenderHTML
+	case "text":
+		render = RenderText
+	case "json":
+		render = RenderJSON
+	default:
+		return fmt.Errorf("unknown report format: %s", format)
+	}
+
+	opts, err := NewOptions(fns...)
+	if err != nil {
+		return err
+	}
+
+	stats, _, err := Run(opts, aggregator)
+	if err != nil {
+		return err
+	}
+
+	groups := make([]string, 0, len(stats))
+	for g := range stats {
+		groups = append(groups, g)
+	}
+	sort.Strings(groups)
+
+	if opts.MergeGroups {
+		merged := NewResults()
+		merged.Name = "all"
+		for _, g := range groups {
+			merged.Merge(stats[g])
+		}
+		return render(merged, w)
+	}
+
+	for _, g := range groups {
+		stats[g].Name = g
+		if err := render(stats[g], w); err != nil {
+			return err
+		}
+	}
+	return nil
 }
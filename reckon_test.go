@@ -0,0 +1,1959 @@
+/*
+ * Copyright (C) 2015 zulily, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reckon
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// fakeConn is a redis.Conn that answers commands using a handler func rather
+// than a live redis instance
+type fakeConn struct {
+	handler func(cmd string, args ...interface{}) (interface{}, error)
+	pending [][]interface{}
+}
+
+func (c *fakeConn) Close() error { return nil }
+func (c *fakeConn) Err() error   { return nil }
+func (c *fakeConn) Flush() error { return nil }
+
+func (c *fakeConn) Send(cmd string, args ...interface{}) error {
+	c.pending = append(c.pending, append([]interface{}{cmd}, args...))
+	return nil
+}
+
+func (c *fakeConn) Receive() (interface{}, error) {
+	if len(c.pending) == 0 {
+		return nil, fmt.Errorf("no pending commands")
+	}
+	p := c.pending[0]
+	c.pending = c.pending[1:]
+	return c.handler(p[0].(string), p[1:]...)
+}
+
+func (c *fakeConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	if cmd == "" {
+		replies := make([]interface{}, 0, len(c.pending))
+		for len(c.pending) > 0 {
+			r, err := c.Receive()
+			if err != nil {
+				return nil, err
+			}
+			replies = append(replies, r)
+		}
+		return replies, nil
+	}
+	return c.handler(cmd, args...)
+}
+
+func TestRandomKeyNormalizesType(t *testing.T) {
+
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		switch cmd {
+		case "RANDOMKEY":
+			return []byte("foo"), nil
+		case "TYPE":
+			return " STRING\r\n", nil
+		}
+		return nil, fmt.Errorf("unexpected command: %s", cmd)
+	}}
+
+	key, vt, err := randomKey(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != "foo" {
+		t.Errorf("expected: foo, actual: %s", key)
+	}
+	if vt != TypeString {
+		t.Errorf("expected: %s, actual: %q", TypeString, vt)
+	}
+}
+
+func TestSizeOnly(t *testing.T) {
+
+	var cmds []string
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		cmds = append(cmds, cmd)
+		switch cmd {
+		case "STRLEN":
+			return int64(1048576), nil
+		case "HLEN":
+			return int64(12), nil
+		}
+		return nil, fmt.Errorf("unexpected command: %s", cmd)
+	}}
+
+	s := newSampler(Options{SizeOnly: true}, AggregatorFunc(AnyKey))
+	s.conn = conn
+	if err := s.observe("bigstring", TypeString); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.observe("somehash", TypeHash); err != nil {
+		t.Fatal(err)
+	}
+
+	r := s.stats[DefaultGroup]
+	assertInt(t, 2, int(r.KeyCount))
+	assertInt(t, 1, int(r.StringSizes[1048576]))
+	assertInt(t, 1, int(r.HashSizes[12]))
+	assertInt(t, 0, len(r.StringValues))
+	assertInt(t, 1, len(r.HashKeys))
+	assertInt(t, 2, len(cmds))
+}
+
+// bufLogger is a Logger that accumulates messages in memory
+type bufLogger struct {
+	messages []string
+}
+
+func (l *bufLogger) Printf(format string, v ...interface{}) {
+	l.messages = append(l.messages, fmt.Sprintf(format, v...))
+}
+
+func TestVerbose(t *testing.T) {
+
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		return []byte("value"), nil
+	}}
+
+	logger := &bufLogger{}
+	s := newSampler(Options{Verbose: logger}, AggregatorFunc(AnyKey))
+	s.conn = conn
+	if err := s.observe("foo", TypeString); err != nil {
+		t.Fatal(err)
+	}
+
+	assertInt(t, 1, len(logger.messages))
+	expected := "observed key: \"foo\" type: string groups: [\"any-key\"]\n"
+	if logger.messages[0] != expected {
+		t.Errorf("expected: %q, actual: %q", expected, logger.messages[0])
+	}
+}
+
+func TestAccessFreq(t *testing.T) {
+
+	lfu := true
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		switch cmd {
+		case "OBJECT":
+			if !lfu {
+				return nil, redis.Error("ERR An LFU maxmemory policy is not selected, access frequency not tracked.")
+			}
+			return int64(5), nil
+		case "GET":
+			return []byte("value"), nil
+		}
+		return nil, fmt.Errorf("unexpected command: %s", cmd)
+	}}
+
+	s := newSampler(Options{AccessFreq: true}, AggregatorFunc(AnyKey))
+	s.conn = conn
+	if err := s.observe("foo", TypeString); err != nil {
+		t.Fatal(err)
+	}
+	assertInt(t, 1, int(s.stats[DefaultGroup].AccessFrequencies[5]))
+
+	lfu = false
+	err := s.observe("foo", TypeString)
+	if err == nil || !strings.Contains(err.Error(), "LFU maxmemory-policy") {
+		t.Errorf("expected an LFU policy error, actual: %v", err)
+	}
+}
+
+func TestBitmapStats(t *testing.T) {
+
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		switch cmd {
+		case "GET":
+			return []byte("\xff\x01"), nil
+		case "BITCOUNT":
+			return int64(9), nil
+		case "HLEN":
+			return int64(0), nil
+		case "HKEYS":
+			return []interface{}{}, nil
+		case "HMGET":
+			return []interface{}{}, nil
+		}
+		return nil, fmt.Errorf("unexpected command: %s", cmd)
+	}}
+
+	s := newSampler(Options{BitmapStats: true}, AggregatorFunc(AnyKey))
+	s.conn = conn
+	if err := s.observe("flags", TypeString); err != nil {
+		t.Fatal(err)
+	}
+	// BITCOUNT is only issued for strings
+	if err := s.observe("myhash", TypeHash); err != nil {
+		t.Fatal(err)
+	}
+
+	r := s.stats[DefaultGroup]
+	assertInt(t, 1, len(r.StringBitCounts))
+	assertInt(t, 1, int(r.StringBitCounts[9]))
+}
+
+func TestHashFieldAggregator(t *testing.T) {
+
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		switch cmd {
+		case "HLEN":
+			return int64(3), nil
+		case "HKEYS":
+			return []interface{}{[]byte("meta:a"), []byte("blob:b"), []byte("meta:c")}, nil
+		case "HMGET":
+			return []interface{}{[]byte("x"), []byte("0123456789"), []byte("yy")}, nil
+		}
+		return nil, fmt.Errorf("unexpected command: %s", cmd)
+	}}
+
+	classify := FieldAggregatorFunc(func(field string) []string {
+		return []string{strings.SplitN(field, ":", 2)[0]}
+	})
+	s := newSampler(Options{ElementsPerKey: 3, HashFieldAggregator: classify}, AggregatorFunc(AnyKey))
+	s.conn = conn
+	if err := s.observe("myhash", TypeHash); err != nil {
+		t.Fatal(err)
+	}
+
+	r := s.stats[DefaultGroup]
+	assertInt(t, 3, len(r.HashValueSizes))
+	assertInt(t, 2, len(r.HashFieldValueSizes))
+	assertInt(t, 1, int(r.HashFieldValueSizes["meta"][1]))
+	assertInt(t, 1, int(r.HashFieldValueSizes["meta"][2]))
+	assertInt(t, 1, int(r.HashFieldValueSizes["blob"][10]))
+
+	merged := r.Clone()
+	merged.Merge(r)
+	assertInt(t, 2, int(merged.HashFieldValueSizes["blob"][10]))
+	assertInt(t, 1, int(r.HashFieldValueSizes["blob"][10]))
+}
+
+func TestObserveAll(t *testing.T) {
+
+	types := map[string]string{"a": "string", "b": "string", "gone": "none"}
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		switch cmd {
+		case "KEYS":
+			if glob := args[0].(string); glob != "*" {
+				return nil, fmt.Errorf("unexpected glob: %s", glob)
+			}
+			return []interface{}{[]byte("a"), []byte("b"), []byte("gone")}, nil
+		case "TYPE":
+			return types[args[0].(string)], nil
+		case "GET":
+			return []byte("value"), nil
+		}
+		return nil, fmt.Errorf("unexpected command: %s", cmd)
+	}}
+
+	s := newSampler(Options{KeysGlob: "*", Logger: &bufLogger{}}, AggregatorFunc(AnyKey))
+	s.conn = conn
+	if err := s.observeAll("*"); err != nil {
+		t.Fatal(err)
+	}
+	assertInt(t, 2, int(s.stats[DefaultGroup].KeyCount))
+	assertInt(t, 2, int(s.typeCounts[TypeString]))
+	assertInt(t, 1, int(s.vanished))
+
+	// a budget caps the observed strings, while every key is still examined
+	s = newSampler(Options{KeysGlob: "*", Logger: &bufLogger{}, PerTypeBudget: map[ValueType]int{TypeString: 1}}, AggregatorFunc(AnyKey))
+	s.conn = conn
+	if err := s.observeAll("*"); err != nil {
+		t.Fatal(err)
+	}
+	assertInt(t, 1, int(s.typeCounts[TypeString]))
+	assertInt(t, 1, int(s.budgetSkips[TypeString]))
+	assertInt(t, 1, int(s.skipped))
+	assertInt(t, 1, int(s.vanished))
+}
+
+func TestPerTypeBudget(t *testing.T) {
+
+	info := "# Keyspace\r\ndb0:keys=100,expires=0,avg_ttl=0\r\n"
+	keys := []string{"s:1", "s:2", "s:3", "s:4", "h:1", "s:5", "h:2"}
+	var randoms int
+	dialer := respDialer{handler: func(args []string) string {
+		switch args[0] {
+		case "INFO":
+			return fmt.Sprintf("$%d\r\n%s\r\n", len(info), info)
+		case "RANDOMKEY":
+			key := keys[randoms%len(keys)]
+			randoms++
+			return fmt.Sprintf("$%d\r\n%s\r\n", len(key), key)
+		case "TYPE":
+			if strings.HasPrefix(args[1], "h:") {
+				return "+hash\r\n"
+			}
+			return "+string\r\n"
+		case "STRLEN", "HLEN":
+			return ":1\r\n"
+		}
+		return "-ERR unexpected command " + args[0] + "\r\n"
+	}}
+
+	run := func(budget map[ValueType]int) (*Results, RunSummary) {
+		opts, err := NewOptions(WithProxy(dialer), WithAllowMaster(), WithMinSamples(50),
+			WithSizeOnly(), WithPerTypeBudget(budget), WithLogger(&bufLogger{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var summary RunSummary
+		opts.Summary = &summary
+		randoms = 0
+		results, _, err := Run(opts, AggregatorFunc(AnyKey))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return results[DefaultGroup], summary
+	}
+
+	// sampling continues past the exhausted string budget while the hash
+	// budget is short, then stops as soon as both are met
+	r, summary := run(map[ValueType]int{TypeString: 2, TypeHash: 1})
+	assertInt(t, 5, randoms)
+	assertInt(t, 3, int(r.KeyCount))
+	assertInt(t, 2, int(summary.Skipped))
+	assertInt(t, 2, int(summary.OverBudget[TypeString]))
+	assertInt(t, 0, int(summary.OverBudget[TypeHash]))
+
+	// types without a budget are not capped, and a budget that is never met
+	// does not end sampling early
+	unbudgeted, _ := run(nil)
+	sampled := randoms
+	r, summary = run(map[ValueType]int{TypeHash: 100})
+	assertInt(t, sampled, randoms)
+	assertInt(t, int(unbudgeted.KeyCount), int(r.KeyCount))
+	assertInt(t, 0, int(summary.Skipped))
+
+	s := newSampler(Options{PerTypeBudget: map[ValueType]int{TypeString: 1, TypeHash: 1}}, AggregatorFunc(AnyKey))
+	s.typeCounts[TypeString] = 1
+	if !s.overBudget(TypeString) || s.overBudget(TypeHash) || s.overBudget(TypeList) {
+		t.Error("expected only the string budget to be exhausted")
+	}
+	if s.budgetsMet() {
+		t.Error("expected budgets to be unmet while the hash budget is short")
+	}
+	s.typeCounts[TypeHash] = 1
+	if !s.budgetsMet() {
+		t.Error("expected budgets to be met")
+	}
+	if newSampler(Options{}, AggregatorFunc(AnyKey)).budgetsMet() {
+		t.Error("expected no budgets never to be met")
+	}
+}
+
+func TestProgressInterval(t *testing.T) {
+
+	assertInt(t, 1, progressInterval(Options{}, 50))
+	assertInt(t, 25, progressInterval(Options{}, 2500))
+	assertInt(t, 10, progressInterval(Options{ProgressInterval: 10}, 2500))
+
+	if _, err := NewOptions(WithProgressInterval(0)); err == nil {
+		t.Error("expected an error for a ProgressInterval of 0")
+	}
+
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		switch cmd {
+		case "KEYS":
+			return []interface{}{[]byte("a"), []byte("b"), []byte("c"), []byte("d"), []byte("e")}, nil
+		case "TYPE":
+			return "string", nil
+		case "GET":
+			return []byte("value"), nil
+		}
+		return nil, fmt.Errorf("unexpected command: %s", cmd)
+	}}
+
+	var reports []Progress
+	logger := &bufLogger{}
+	opts := Options{ProgressInterval: 2, Logger: logger, Progress: func(p Progress) { reports = append(reports, p) }}
+	s := newSampler(opts, AggregatorFunc(AnyKey))
+	s.conn = conn
+	if err := s.observeAll("*"); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Progress{
+		{Sampled: 2, Planned: 5, TotalKeys: 5},
+		{Sampled: 4, Planned: 5, TotalKeys: 5},
+		{Sampled: 5, Planned: 5, TotalKeys: 5, Done: true},
+	}
+	if !reflect.DeepEqual(expected, reports) {
+		t.Errorf("expected progress reports %v, actual: %v", expected, reports)
+	}
+	assertInt(t, 2, len(logger.messages))
+}
+
+func TestBurnIn(t *testing.T) {
+
+	commands := map[string]int{}
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		commands[cmd]++
+		switch cmd {
+		case "RANDOMKEY":
+			return []byte("foo"), nil
+		case "TYPE":
+			return "string", nil
+		}
+		return nil, fmt.Errorf("unexpected command: %s", cmd)
+	}}
+
+	logger := &bufLogger{}
+	s := newSampler(Options{Logger: logger}, AggregatorFunc(AnyKey))
+	s.conn = conn
+	if err := s.burnIn(3); err != nil {
+		t.Fatal(err)
+	}
+	assertInt(t, 3, commands["RANDOMKEY"])
+	assertInt(t, 3, commands["TYPE"])
+	assertInt(t, 0, len(s.stats))
+	assertInt(t, 1, len(logger.messages))
+
+	if _, err := NewOptions(WithBurnIn(-1)); err == nil {
+		t.Error("expected an error for a negative BurnIn")
+	}
+}
+
+func TestStratify(t *testing.T) {
+
+	scanned := map[string]int{}
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		switch cmd {
+		case "SCAN":
+			vt := args[len(args)-1].(string)
+			scanned[vt]++
+			if vt == "set" {
+				// only a single set exists
+				return []interface{}{[]byte("0"), []interface{}{[]byte("set:0")}}, nil
+			}
+			// two keys per page, over two pages
+			cursor := "0"
+			if args[0].(int) == 0 {
+				cursor = "9"
+			}
+			keys := []interface{}{}
+			for i := 0; i < 2; i++ {
+				keys = append(keys, []byte(fmt.Sprintf("%s:%d:%d", vt, scanned[vt], i)))
+			}
+			return []interface{}{[]byte(cursor), keys}, nil
+		case "STRLEN", "SCARD", "LLEN", "ZCARD", "HLEN":
+			return int64(1), nil
+		}
+		return nil, fmt.Errorf("unexpected command: %s", cmd)
+	}}
+
+	s := newSampler(Options{SizeOnly: true}, AggregatorFunc(AnyKey))
+	s.conn = conn
+	s.typeCounts[TypeString] = 5
+	s.typeCounts[TypeHash] = 2
+
+	if err := s.stratify(3); err != nil {
+		t.Fatal(err)
+	}
+
+	// full types are not resampled
+	assertInt(t, 5, int(s.typeCounts[TypeString]))
+	assertInt(t, 0, scanned["string"])
+
+	// under-represented types are topped up to exactly the quota, scanning
+	// only as far as needed
+	assertInt(t, 3, int(s.typeCounts[TypeHash]))
+	assertInt(t, 1, scanned["hash"])
+	assertInt(t, 3, int(s.typeCounts[TypeList]))
+	assertInt(t, 2, scanned["list"])
+	assertInt(t, 3, int(s.typeCounts[TypeSortedSet]))
+	assertInt(t, 2, scanned["zset"])
+
+	// a type with too few keys is exhausted without looping
+	assertInt(t, 1, int(s.typeCounts[TypeSet]))
+	assertInt(t, 1, scanned["set"])
+
+	counts := s.stats[DefaultGroup].TypeCounts()
+	assertInt(t, 1, int(counts[TypeHash]))
+	assertInt(t, 3, int(counts[TypeList]))
+}
+
+func TestAllocate(t *testing.T) {
+
+	scanned := map[string]int{}
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		switch cmd {
+		case "SCAN":
+			vt := args[len(args)-1].(string)
+			scanned[vt]++
+			keys := []interface{}{}
+			for i := 0; i < 10; i++ {
+				keys = append(keys, []byte(fmt.Sprintf("%s:%d", vt, i)))
+			}
+			return []interface{}{[]byte("0"), keys}, nil
+		case "GET":
+			return []byte("value"), nil
+		case "SCARD", "LLEN", "ZCARD", "HLEN":
+			return int64(0), nil
+		case "SRANDMEMBER", "LRANGE", "ZRANGE", "HGETALL":
+			return []interface{}{}, nil
+		}
+		return nil, fmt.Errorf("unexpected command: %s", cmd)
+	}}
+
+	// a pilot sample of 8 strings and 2 hashes
+	newPilot := func() *sampler {
+		s := newSampler(Options{SizeOnly: true}, AggregatorFunc(AnyKey))
+		s.conn = conn
+		s.typeCounts[TypeString] = 8
+		s.typeCounts[TypeHash] = 2
+		return s
+	}
+
+	s := newPilot()
+	if err := s.allocate(ProportionalAllocation, 10); err != nil {
+		t.Fatal(err)
+	}
+	assertInt(t, 8, int(s.typeCounts[TypeString]))
+	assertInt(t, 2, int(s.typeCounts[TypeHash]))
+	assertInt(t, 1, int(s.typeCounts[TypeSet]))
+	assertInt(t, 1, int(s.typeCounts[TypeList]))
+	assertInt(t, 1, int(s.typeCounts[TypeSortedSet]))
+	assertInt(t, 0, scanned["string"])
+
+	s = newPilot()
+	if err := s.allocate(EqualAllocation, 20); err != nil {
+		t.Fatal(err)
+	}
+	assertInt(t, 8, int(s.typeCounts[TypeString]))
+	for _, vt := range []ValueType{TypeHash, TypeSet, TypeList, TypeSortedSet} {
+		assertInt(t, 4, int(s.typeCounts[vt]))
+	}
+
+	counts := s.stats[DefaultGroup].TypeCounts()
+	assertInt(t, 4, len(counts))
+	assertInt(t, 2, int(counts[TypeHash]))
+	assertInt(t, 4, int(counts[TypeSet]))
+
+	assertInt(t, 1, pilotCount(5))
+	assertInt(t, 50, pilotCount(500))
+	if _, err := NewOptions(WithAllocation(Allocation(7))); err == nil {
+		t.Error("expected an error for an unknown Allocation")
+	}
+}
+
+func TestObserveAllNoMatches(t *testing.T) {
+
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		if cmd == "KEYS" {
+			return []interface{}{}, nil
+		}
+		return nil, fmt.Errorf("unexpected command: %s", cmd)
+	}}
+
+	logger := &bufLogger{}
+	s := newSampler(Options{KeysGlob: "usr:*", Logger: logger}, AggregatorFunc(AnyKey))
+	s.conn = conn
+	if err := s.observeAll("usr:*"); err != nil {
+		t.Fatal(err)
+	}
+	assertInt(t, 1, len(s.warnings))
+	assertContains(t, s.warnings[0], `no keys match the glob "usr:*"`)
+	assertInt(t, 1, len(logger.messages))
+}
+
+func TestKeyTransform(t *testing.T) {
+
+	var gets []interface{}
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		if cmd == "GET" {
+			gets = append(gets, args[0])
+			return []byte("value"), nil
+		}
+		return nil, fmt.Errorf("unexpected command: %s", cmd)
+	}}
+
+	ids := regexp.MustCompile(`:[0-9]+:`)
+	transform := func(key string) string { return ids.ReplaceAllString(key, ":*:") }
+	aggregator := AggregatorFunc(func(key string, vt ValueType) []string {
+		return []string{key}
+	})
+
+	s := newSampler(Options{KeyTransform: transform}, aggregator)
+	s.conn = conn
+	for _, key := range []string{"user:12345:cart", "user:678:cart"} {
+		if err := s.observe(key, TypeString); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if fmt.Sprint(gets) != "[user:12345:cart user:678:cart]" {
+		t.Errorf("expected redis commands to use the raw keys, actual: %v", gets)
+	}
+	r, ok := s.stats["user:*:cart"]
+	if !ok || len(s.stats) != 1 {
+		t.Fatalf("expected a single group for the transformed key, actual: %v", s.stats)
+	}
+	assertInt(t, 2, int(r.KeyCount))
+	if !r.StringKeys["user:*:cart"] || len(r.StringKeys) != 1 {
+		t.Errorf("expected the transformed example key, actual: %v", r.StringKeys)
+	}
+}
+
+func TestObserveBinaryKey(t *testing.T) {
+
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		if cmd == "GET" {
+			return []byte("value"), nil
+		}
+		return nil, fmt.Errorf("unexpected command: %s", cmd)
+	}}
+
+	summary := &RunSummary{}
+	s := newSampler(Options{Summary: summary}, AggregatorFunc(AnyKey))
+	s.conn = conn
+	for _, key := range []string{"plain", "bin\x00\xff"} {
+		if err := s.observe(key, TypeString); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.summarize(2, time.Second)
+
+	r := s.stats[DefaultGroup]
+	assertInt(t, 1, int(r.BinaryKeyCount))
+	if !r.BinaryKeys[`bin\x00\xff`] || !r.StringKeys[`bin\x00\xff`] || !r.StringKeys["plain"] {
+		t.Errorf("expected escaped example keys, actual: %v %v", r.BinaryKeys, r.StringKeys)
+	}
+	assertInt(t, 1, len(summary.Warnings))
+	assertContains(t, summary.Warnings[0], "1 observed keys have binary")
+
+	var buf bytes.Buffer
+	if err := RenderText(r, &buf); err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, buf.String(), "--- Binary Key Names (1, 50.00%) ---")
+}
+
+func TestVerbosity(t *testing.T) {
+
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		switch cmd {
+		case "RANDOMKEY":
+			return []byte("foo"), nil
+		case "TYPE":
+			return "string", nil
+		case "GET":
+			return []byte("value"), nil
+		}
+		return nil, fmt.Errorf("unexpected command: %s", cmd)
+	}}
+
+	for level, expected := range map[Verbosity]int{VerbositySilent: 0, VerbosityProgress: 1, VerbosityDebug: 2} {
+		logger := &bufLogger{}
+		s := newSampler(Options{Logger: logger, Verbosity: level}, AggregatorFunc(AnyKey))
+		s.conn = conn
+		if err := s.burnIn(1); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.observe("foo", TypeString); err != nil {
+			t.Fatal(err)
+		}
+		if len(logger.messages) != expected {
+			t.Errorf("expected %d messages at verbosity %d, actual: %q", expected, level, logger.messages)
+		}
+	}
+
+	verbose := &bufLogger{}
+	s := newSampler(Options{Logger: &bufLogger{}, Verbose: verbose, Verbosity: VerbositySilent}, AggregatorFunc(AnyKey))
+	s.conn = conn
+	if err := s.observe("foo", TypeString); err != nil {
+		t.Fatal(err)
+	}
+	assertInt(t, 0, len(verbose.messages))
+
+	if _, err := NewOptions(WithVerbosity(Verbosity(9))); err == nil {
+		t.Error("expected an error for an unknown Verbosity")
+	}
+}
+
+func TestMaxFetchBytes(t *testing.T) {
+
+	values := map[string]string{"small": "abc", "big": strings.Repeat("x", 100)}
+	var fetched []string
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		switch cmd {
+		case "STRLEN":
+			return int64(len(values[args[0].(string)])), nil
+		case "GET":
+			fetched = append(fetched, args[0].(string))
+			return []byte(values[args[0].(string)]), nil
+		case "HLEN":
+			return int64(2), nil
+		case "HKEYS":
+			return []interface{}{[]byte("small"), []byte("big")}, nil
+		case "HSTRLEN":
+			return int64(len(values[args[1].(string)])), nil
+		case "HMGET":
+			var vals []interface{}
+			for _, f := range args[1:] {
+				fetched = append(fetched, "h:"+f.(string))
+				vals = append(vals, []byte(values[f.(string)]))
+			}
+			return vals, nil
+		}
+		return nil, fmt.Errorf("unexpected command: %s", cmd)
+	}}
+
+	s := newSampler(Options{MaxFetchBytes: 10, ElementsPerKey: 2}, AggregatorFunc(AnyKey))
+	s.conn = conn
+	for _, key := range []string{"small", "big"} {
+		if err := s.observe(key, TypeString); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.observe("myhash", TypeHash); err != nil {
+		t.Fatal(err)
+	}
+
+	if fmt.Sprint(fetched) != "[small h:small]" {
+		t.Errorf("expected only the small values to be fetched, actual: %v", fetched)
+	}
+	r := s.stats[DefaultGroup]
+	assertInt(t, 1, int(r.StringSizes[100]))
+	assertInt(t, 1, int(r.StringSizes[3]))
+	assertInt(t, 1, len(r.StringValues))
+	assertInt(t, 1, int(r.HashValueSizes[100]))
+	assertInt(t, 1, int(r.HashValueSizes[3]))
+	if len(r.HashValues) != 1 || !r.HashValues["abc"] {
+		t.Errorf("expected only the small hash value as an example, actual: %v", r.HashValues)
+	}
+
+	if _, err := NewOptions(WithMaxFetchBytes(0)); err == nil {
+		t.Error("expected an error for a MaxFetchBytes of 0")
+	}
+}
+
+func TestListEnds(t *testing.T) {
+
+	list := []string{"m", "payload-1", "payload-22"}
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		switch cmd {
+		case "LLEN":
+			return int64(len(list)), nil
+		case "LRANGE":
+			return []interface{}{[]byte(list[0])}, nil
+		case "LINDEX":
+			i := args[1].(int)
+			if i < 0 {
+				i += len(list)
+			}
+			return []byte(list[i]), nil
+		}
+		return nil, fmt.Errorf("unexpected command: %s", cmd)
+	}}
+
+	s := newSampler(Options{ListEnds: true}, AggregatorFunc(AnyKey))
+	s.conn = conn
+	if err := s.observe("queue", TypeList); err != nil {
+		t.Fatal(err)
+	}
+	r := s.stats[DefaultGroup]
+	assertInt(t, 1, int(r.ListHeadSizes[1]))
+	assertInt(t, 1, int(r.ListTailSizes[10]))
+
+	var buf bytes.Buffer
+	if err := RenderText(r, &buf); err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, buf.String(), "Tail Element Sizes (min: 10 max: 10")
+}
+
+func TestObserveVanishedKey(t *testing.T) {
+
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		return nil, fmt.Errorf("unexpected command: %s", cmd)
+	}}
+
+	s := newSampler(Options{}, AggregatorFunc(AnyKey))
+	s.conn = conn
+	if err := s.observe("gone", parseValueType("none")); err != nil {
+		t.Fatal(err)
+	}
+	assertInt(t, 1, int(s.vanished))
+	assertInt(t, 1, int(s.stats[DefaultGroup].VanishedKeys))
+	assertInt(t, 0, int(s.stats[DefaultGroup].KeyCount))
+}
+
+func TestObserveEmptiedHash(t *testing.T) {
+
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		switch cmd {
+		case "HLEN":
+			return int64(0), nil
+		case "HKEYS":
+			return []interface{}{}, nil
+		}
+		return nil, fmt.Errorf("unexpected command: %s", cmd)
+	}}
+
+	for _, opts := range []Options{{}, {MaxFetchBytes: 64}} {
+		s := newSampler(opts, AggregatorFunc(AnyKey))
+		s.conn = conn
+		if err := s.observe("gone", TypeHash); err != nil {
+			t.Fatal(err)
+		}
+		assertInt(t, 1, int(s.vanished))
+		assertInt(t, 0, int(s.typeCounts[TypeHash]))
+	}
+}
+
+func TestVanishedKeysPerGroup(t *testing.T) {
+
+	info := "# Keyspace\r\ndb0:keys=4,expires=0,avg_ttl=0\r\n"
+	keys := []string{"a:1", "b:1", "a:2", "b:2"}
+	var next int
+	dialer := respDialer{handler: func(args []string) string {
+		switch args[0] {
+		case "INFO":
+			return fmt.Sprintf("$%d\r\n%s\r\n", len(info), info)
+		case "RANDOMKEY":
+			key := keys[next%len(keys)]
+			next++
+			return fmt.Sprintf("$%d\r\n%s\r\n", len(key), key)
+		case "TYPE":
+			if args[1] == "a:2" {
+				return "+none\r\n"
+			}
+			return "+string\r\n"
+		case "GET":
+			return "$5\r\nvalue\r\n"
+		}
+		return "-ERR unexpected command " + args[0] + "\r\n"
+	}}
+
+	byPrefix := AggregatorFunc(func(key string, vt ValueType) []string { return []string{key[:1]} })
+	opts, err := NewOptions(WithProxy(dialer), WithAllowMaster(), WithMinSamples(3), WithLogger(&bufLogger{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, _, err := Run(opts, byPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertInt(t, 1, int(results["a"].VanishedKeys))
+	assertInt(t, 0, int(results["b"].VanishedKeys))
+
+	// merging the groups of one instance counts each vanished key once
+	assertInt(t, 1, int(Sum(results["a"], results["b"]).VanishedKeys))
+}
+
+func TestRandomLiveKey(t *testing.T) {
+
+	var calls int
+	live := true
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		switch cmd {
+		case "RANDOMKEY":
+			calls++
+			return []byte(fmt.Sprintf("key%d", calls)), nil
+		case "TYPE":
+			if live && calls == 3 {
+				return "string", nil
+			}
+			return "none", nil
+		}
+		return nil, fmt.Errorf("unexpected command: %s", cmd)
+	}}
+
+	s := newSampler(Options{}, AggregatorFunc(AnyKey))
+	s.conn = conn
+	key, vt, err := s.randomLiveKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != "key3" || vt != TypeString {
+		t.Errorf("expected the first live key, actual: %s (%s)", key, vt)
+	}
+	assertInt(t, 2, int(s.vanished))
+
+	// an instance whose keys have all expired, but not yet been evicted
+	live, calls = false, 0
+	if _, _, err = s.randomLiveKey(); err != ErrNoKeys {
+		t.Errorf("expected ErrNoKeys, actual: %v", err)
+	}
+	assertInt(t, maxConsecutiveVanished, calls)
+}
+
+func TestObserveOtherTypes(t *testing.T) {
+
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		return nil, fmt.Errorf("unexpected command: %s", cmd)
+	}}
+
+	vt := parseValueType(" ReJSON-RL\r\n")
+	if vt != "ReJSON-RL" {
+		t.Fatalf("expected a module type name to be retained exactly, actual: %q", vt)
+	}
+	if parseValueType(" HASH ") != TypeHash {
+		t.Error("expected a known type name to be lowercased")
+	}
+
+	s := newSampler(Options{}, AggregatorFunc(AnyKey))
+	s.conn = conn
+	if err := s.observe("doc:1", vt); err == nil {
+		t.Error("expected an error for an unsampled type without OtherTypes")
+	}
+
+	var types []ValueType
+	byType := AggregatorFunc(func(key string, valueType ValueType) []string {
+		types = append(types, valueType)
+		return []string{string(valueType)}
+	})
+	s = newSampler(Options{OtherTypes: true}, byType)
+	s.conn = conn
+	for _, key := range []string{"doc:1", "doc:2"} {
+		if err := s.observe(key, vt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := s.stats["ReJSON-RL"]
+	if r == nil {
+		t.Fatalf("expected a group for the module type, actual: %v", types)
+	}
+	assertInt(t, 2, int(r.OtherTypes["ReJSON-RL"]))
+	assertInt(t, 2, int(r.KeyCount))
+
+	var buf bytes.Buffer
+	if err := RenderText(r, &buf); err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, buf.String(), "ReJSON-RL: 2 (100.00%)")
+}
+
+func TestMemoryBudget(t *testing.T) {
+
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		if cmd == "GET" {
+			return []byte("12345"), nil
+		}
+		return nil, fmt.Errorf("unexpected command: %s", cmd)
+	}}
+
+	// each group retains its key and value; the budget admits only the first
+	// group's 10 bytes of examples
+	byKey := AggregatorFunc(func(key string, valueType ValueType) []string { return []string{key} })
+	var summary RunSummary
+	s := newSampler(Options{MemoryBudget: 12, Summary: &summary}, byKey)
+	s.conn = conn
+	for _, key := range []string{"key:a", "key:b", "key:c"} {
+		if err := s.observe(key, TypeString); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	assertInt(t, 2, len(s.stats["key:a"].StringKeys)+len(s.stats["key:a"].StringValues))
+	assertInt(t, 0, len(s.stats["key:c"].StringKeys)+len(s.stats["key:c"].StringValues))
+	assertInt(t, 1, int(s.stats["key:c"].KeyCount))
+	assertInt(t, 10, int(s.budget.used))
+
+	s.summarize(3, time.Second)
+	if !summary.MemoryBudgetReached || len(summary.Warnings) != 1 {
+		t.Errorf("expected the MemoryBudget to be reported as reached, actual: %+v", summary)
+	}
+}
+
+func TestCountingConn(t *testing.T) {
+
+	conn := &countingConn{Conn: &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		switch cmd {
+		case "LLEN":
+			return int64(1), nil
+		case "LRANGE":
+			return []interface{}{[]byte("elem")}, nil
+		case "GET":
+			return []byte("value"), nil
+		}
+		return nil, fmt.Errorf("unexpected command: %s", cmd)
+	}}}
+
+	var summary RunSummary
+	s := newSampler(Options{Summary: &summary}, AggregatorFunc(AnyKey))
+	s.conn, s.counter = conn, conn
+	if err := s.observe("str", TypeString); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.observe("list", TypeList); err != nil {
+		t.Fatal(err)
+	}
+
+	// a GET, and a pipelined LLEN and LRANGE
+	assertInt(t, 3, int(conn.commands))
+	s.summarize(2, time.Second)
+	assertInt(t, 3, int(summary.Commands))
+	assertFloat(t, 1.5, summary.CommandsPerKey, 1e-9)
+}
+
+func TestUniformElements(t *testing.T) {
+
+	members := map[string][]interface{}{
+		"uuids":   {[]byte("0123456789abcdef"), []byte("fedcba9876543210")},
+		"corrupt": {[]byte("0123456789abcdef"), []byte("0123")},
+	}
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		switch cmd {
+		case "SCARD":
+			return int64(2), nil
+		case "SRANDMEMBER":
+			return members[args[0].(string)], nil
+		}
+		return nil, fmt.Errorf("unexpected command: %s", cmd)
+	}}
+
+	s := newSampler(Options{ElementsPerKey: 2, UniformElementSize: 16, UniformMaxCV: 0.1}, AggregatorFunc(AnyKey))
+	s.conn = conn
+	for _, key := range []string{"uuids", "corrupt"} {
+		if err := s.observe(key, TypeSet); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := s.stats[DefaultGroup]
+	assertInt(t, 1, int(r.NonUniformCount))
+	if !r.NonUniformKeys["corrupt"] || len(r.NonUniformKeys) != 1 {
+		t.Errorf("expected only the corrupt key as an example, actual: %v", r.NonUniformKeys)
+	}
+	types := r.NonUniformTypes()
+	if _, ok := types[TypeSet]; !ok || len(types) != 1 {
+		t.Errorf("expected the set element sizes to be flagged, actual: %v", types)
+	}
+
+	var buf bytes.Buffer
+	if err := RenderText(r, &buf); err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, buf.String(), "--- Non-Uniform Elements (1, 50.00%) ---")
+	assertContains(t, buf.String(), "set element sizes vary")
+}
+
+func TestFunnel(t *testing.T) {
+
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		if cmd == "GET" {
+			return []byte("value"), nil
+		}
+		return nil, fmt.Errorf("unexpected command: %s", cmd)
+	}}
+
+	// keys without a prefix are not grouped
+	prefixed := AggregatorFunc(func(key string, valueType ValueType) []string {
+		if strings.HasPrefix(key, "usr:") {
+			return []string{"usr"}
+		}
+		return nil
+	})
+	var summary RunSummary
+	s := newSampler(Options{Summary: &summary, PerTypeBudget: map[ValueType]int{TypeString: 3}}, prefixed)
+	s.conn = conn
+	for _, key := range []string{"usr:1", "other", "usr:2", "usr:3"} {
+		if s.overBudget(TypeString) {
+			s.skipBudgeted(TypeString)
+			continue
+		}
+		if err := s.observe(key, TypeString); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.observe("gone", TypeNone); err != nil {
+		t.Fatal(err)
+	}
+
+	s.summarize(5, time.Second)
+	expected := Funnel{Examined: 5, Vanished: 1, Skipped: 1, Ungrouped: 1, Observed: 2}
+	if summary.Funnel != expected {
+		t.Errorf("expected: %v, actual: %v", expected, summary.Funnel)
+	}
+	assertInt(t, 2, int(s.stats["usr"].KeyCount))
+}
+
+func TestSizeCorrelation(t *testing.T) {
+
+	// sets whose members are all 8 bytes, and lists with members of 1 byte,
+	// except for the shortest list, whose members are of 64 bytes
+	cardinality := map[string]int64{"set1": 1, "set2": 10, "set3": 100, "list1": 1, "list2": 10, "list3": 100}
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		key := args[0].(string)
+		switch cmd {
+		case "SCARD", "LLEN":
+			return cardinality[key], nil
+		case "SRANDMEMBER":
+			return []interface{}{[]byte("members!")}, nil
+		case "LRANGE":
+			if key == "list1" {
+				return []interface{}{[]byte(strings.Repeat("x", 64))}, nil
+			}
+			return []interface{}{[]byte("x")}, nil
+		}
+		return nil, fmt.Errorf("unexpected command: %s", cmd)
+	}}
+
+	var summary RunSummary
+	s := newSampler(Options{Summary: &summary}, AggregatorFunc(AnyKey))
+	s.conn = conn
+	for _, key := range []string{"set1", "set2", "set3", "list1", "list2", "list3"} {
+		vt := TypeSet
+		if strings.HasPrefix(key, "list") {
+			vt = TypeList
+		}
+		if err := s.observe(key, vt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s.summarize(6, time.Second)
+	assertFloat(t, 1, summary.SizeCorrelation[TypeSet], 1e-9)
+	if c := summary.SizeCorrelation[TypeList]; c > 0.9 {
+		t.Errorf("expected a weaker correlation for lists, actual: %f", c)
+	}
+	if _, ok := summary.SizeCorrelation[TypeString]; ok {
+		t.Error("expected no correlation for strings")
+	}
+}
+
+func TestGroupsPerKey(t *testing.T) {
+
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		if cmd == "GET" {
+			return []byte("value"), nil
+		}
+		return nil, fmt.Errorf("unexpected command: %s", cmd)
+	}}
+
+	// every key is counted in both groups
+	fanout := AggregatorFunc(func(key string, valueType ValueType) []string {
+		return []string{"all", key[:1]}
+	})
+	var summary RunSummary
+	s := newSampler(Options{Summary: &summary}, fanout)
+	s.conn = conn
+	for _, key := range []string{"a1", "a2", "b1", "b2"} {
+		if err := s.observe(key, TypeString); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s.summarize(4, time.Second)
+	assertInt(t, 8, int(summary.GroupAssignments))
+	assertFloat(t, 2, summary.GroupsPerKey, 1e-9)
+}
+
+func TestBucketExamples(t *testing.T) {
+
+	values := map[string]string{"a": "x", "b": "xxx", "c": "xxxx", "d": strings.Repeat("x", 1000)}
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		if cmd == "GET" {
+			return []byte(values[args[0].(string)]), nil
+		}
+		return nil, fmt.Errorf("unexpected command: %s", cmd)
+	}}
+
+	s := newSampler(Options{BucketExamples: 1}, AggregatorFunc(AnyKey))
+	s.conn = conn
+	for _, key := range []string{"a", "b", "c", "d"} {
+		if err := s.observe(key, TypeString); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// "b" and "c" share the bucket of size 4, which retains only one of them
+	buckets := s.stats[DefaultGroup].BucketKeys[TypeString]
+	assertInt(t, 3, len(buckets))
+	assertInt(t, 1, len(buckets[4]))
+	if !buckets[1]["a"] || !buckets[1024]["d"] || !(buckets[4]["b"] || buckets[4]["c"]) {
+		t.Errorf("unexpected bucket examples: %v", buckets)
+	}
+
+	merged := NewResults()
+	merged.Merge(s.stats[DefaultGroup])
+	merged.Merge(s.stats[DefaultGroup])
+	if !reflect.DeepEqual(buckets, merged.BucketKeys[TypeString]) {
+		t.Errorf("expected the bucket examples to be merged, actual: %v", merged.BucketKeys)
+	}
+
+	if _, err := NewOptions(WithBucketExamples(0)); err == nil {
+		t.Error("expected an error for fewer than 1 BucketExamples")
+	}
+}
+
+func TestElementSizeFromObject(t *testing.T) {
+
+	usage := interface{}(int64(500))
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		switch cmd {
+		case "SCARD", "LLEN":
+			return int64(10), nil
+		case "SRANDMEMBER", "LRANGE":
+			return []interface{}{[]byte("ab"), []byte("abcd")}, nil
+		case "MEMORY":
+			return usage, nil
+		}
+		return nil, fmt.Errorf("unexpected command: %s", cmd)
+	}}
+
+	s := newSampler(Options{ElementsPerKey: 2, ElementSizeFromObject: true}, AggregatorFunc(AnyKey))
+	s.conn = conn
+	if err := s.observe("set", TypeSet); err != nil {
+		t.Fatal(err)
+	}
+
+	// 500 bytes among 10 members
+	r := s.stats[DefaultGroup]
+	assertInt(t, 1, len(r.SetElementSizes))
+	assertInt(t, 2, int(r.SetElementSizes[50]))
+	assertInt(t, 2, len(r.SetElements))
+
+	// a key that vanishes before MEMORY USAGE falls back to member lengths
+	usage = nil
+	if err := s.observe("list", TypeList); err != nil {
+		t.Fatal(err)
+	}
+	assertInt(t, 1, int(r.ListElementSizes[2]))
+	assertInt(t, 1, int(r.ListElementSizes[4]))
+}
+
+func TestKeyMemory(t *testing.T) {
+
+	usage := map[string]interface{}{"a": int64(50), "b": int64(1000)}
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		switch cmd {
+		case "GET":
+			return []byte("value"), nil
+		case "MEMORY":
+			return usage[args[1].(string)], nil
+		}
+		return nil, fmt.Errorf("unexpected command: %s", cmd)
+	}}
+
+	s := newSampler(Options{KeyMemory: true}, AggregatorFunc(AnyKey))
+	s.conn = conn
+	for _, key := range []string{"a", "b", "vanished"} {
+		if err := s.observe(key, TypeString); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := s.stats[DefaultGroup]
+	assertInt(t, 3, int(r.KeyCount))
+	assertInt(t, 2, len(r.KeyMemorySizes))
+	assertInt(t, 1, int(r.KeyMemorySizes[1000]))
+	assertFloat(t, 1000.0/1050, r.MemoryConcentration().Top1, 1e-9)
+
+	var buf bytes.Buffer
+	if err := RenderText(r, &buf); err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, buf.String(), "Share of memory in the largest 1% of keys: 95.24%")
+}
+
+func TestAggregatorPanic(t *testing.T) {
+
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		return []byte("value"), nil
+	}}
+
+	firstLetter := AggregatorFunc(func(key string, valueType ValueType) []string {
+		return []string{key[:1]}
+	})
+	s := newSampler(Options{}, firstLetter)
+	s.conn = conn
+	err := s.observe("", TypeString)
+	if err == nil || !strings.Contains(err.Error(), `key "" of type string`) {
+		t.Errorf("expected an error naming the key, actual: %v", err)
+	}
+}
+
+func TestMaxIdleTime(t *testing.T) {
+
+	idle := map[string]int64{"hot": 30, "cold": 120}
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		switch cmd {
+		case "OBJECT":
+			return idle[args[1].(string)], nil
+		case "GET":
+			return []byte("value"), nil
+		}
+		return nil, fmt.Errorf("unexpected command: %s", cmd)
+	}}
+
+	s := newSampler(Options{MaxIdleTime: 60}, AggregatorFunc(AnyKey))
+	s.conn = conn
+	for _, key := range []string{"hot", "cold"} {
+		if err := s.observe(key, TypeString); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertInt(t, 1, int(s.stats[DefaultGroup].KeyCount))
+	assertInt(t, 1, int(s.skipped))
+	if len(s.stats[DefaultGroup].IdleTimes) != 0 {
+		t.Error("expected no idle times to be recorded without IdleTime")
+	}
+}
+
+func TestTargetPrecision(t *testing.T) {
+
+	s := newSampler(Options{TargetRelError: 0.05, TargetConfidence: 0.95}, AggregatorFunc(AnyKey))
+	for i := 0; i < minPrecisionSamples; i++ {
+		if s.precise(i) {
+			t.Fatalf("expected sampling to continue after %d keys", i)
+		}
+		s.sizes.add(float64(100 + i%2))
+	}
+	if !s.precise(minPrecisionSamples) {
+		t.Error("expected a low-variance sample to reach the target precision")
+	}
+
+	s = newSampler(Options{TargetRelError: 0.05, TargetConfidence: 0.95}, AggregatorFunc(AnyKey))
+	for i := 0; i < minPrecisionSamples; i++ {
+		s.sizes.add(float64(1 + (i%2)*1000))
+	}
+	if s.precise(minPrecisionSamples) {
+		t.Error("expected a high-variance sample not to reach the target precision")
+	}
+
+	assertInt(t, 5000, sampleCount(Options{TargetRelError: 0.05, MaxSamples: 5000}, 1000000))
+	assertInt(t, 1000, sampleCount(Options{TargetRelError: 0.05}, 1000))
+}
+
+func TestHashByteEstimates(t *testing.T) {
+
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		switch cmd {
+		case "HLEN":
+			return int64(10), nil
+		case "HKEYS":
+			return []interface{}{[]byte("ab"), []byte("cd")}, nil
+		case "HMGET":
+			return []interface{}{[]byte("xyz"), []byte("0123456")}, nil
+		}
+		return nil, fmt.Errorf("unexpected command: %s", cmd)
+	}}
+
+	s := newSampler(Options{ElementsPerKey: 2, HashByteEstimates: true}, AggregatorFunc(AnyKey))
+	s.conn = conn
+	if err := s.observe("myhash", TypeHash); err != nil {
+		t.Fatal(err)
+	}
+	// (2+3 + 2+7) bytes for 2 of 10 fields
+	assertInt(t, 1, int(s.stats[DefaultGroup].HashByteSizes[70]))
+	assertInt(t, 0, estimateHashBytes(0, nil, nil))
+}
+
+func TestByteEstimates(t *testing.T) {
+
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		switch cmd {
+		case "SCARD", "ZCARD", "LLEN", "HLEN":
+			return int64(10), nil
+		case "SRANDMEMBER", "ZRANGE", "LRANGE", "HKEYS":
+			return []interface{}{[]byte("ab"), []byte("abcd")}, nil
+		case "HMGET":
+			return []interface{}{[]byte("x"), []byte("y")}, nil
+		}
+		return nil, fmt.Errorf("unexpected command: %s", cmd)
+	}}
+
+	s := newSampler(Options{ElementsPerKey: 2, ByteEstimates: true}, AggregatorFunc(AnyKey))
+	s.conn = conn
+	for _, vt := range []ValueType{TypeSet, TypeSortedSet, TypeList, TypeHash} {
+		if err := s.observe("key", vt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a mean of 3 bytes for each of 10 elements
+	r := s.stats[DefaultGroup]
+	assertInt(t, 1, int(r.SetByteSizes[30]))
+	assertInt(t, 1, int(r.SortedSetByteSizes[30]))
+	assertInt(t, 1, int(r.ListByteSizes[30]))
+	assertInt(t, 1, int(r.HashByteSizes[40]))
+
+	var buf bytes.Buffer
+	if err := RenderText(r, &buf); err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, buf.String(), "Estimated Bytes per Sorted Set (min: 30 max: 30")
+}
+
+func TestElementAggregator(t *testing.T) {
+
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		switch cmd {
+		case "SCARD", "LLEN":
+			return int64(3), nil
+		case "SRANDMEMBER", "LRANGE":
+			return []interface{}{[]byte("tag:color:red"), []byte("tag:size:xl"), []byte("tag:color:blue")}, nil
+		}
+		return nil, fmt.Errorf("unexpected command: %s", cmd)
+	}}
+
+	byPrefix := ElementAggregatorFunc(func(element string) []string {
+		return []string{strings.Join(strings.SplitN(element, ":", 3)[:2], ":")}
+	})
+	s := newSampler(Options{ElementsPerKey: 3, ElementAggregator: byPrefix}, AggregatorFunc(AnyKey))
+	s.conn = conn
+	for _, vt := range []ValueType{TypeSet, TypeList} {
+		if err := s.observe("mykey", vt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := s.stats[DefaultGroup]
+	assertInt(t, 2, len(r.SetElementClassSizes))
+	assertInt(t, 1, int(r.SetElementClassSizes["tag:color"][13]))
+	assertInt(t, 1, int(r.SetElementClassSizes["tag:color"][14]))
+	assertInt(t, 1, int(r.SetElementClassSizes["tag:size"][11]))
+	assertInt(t, 2, len(r.ListElementClassSizes))
+	assertInt(t, 0, len(r.SortedSetElementClassSizes))
+
+	var buf bytes.Buffer
+	if err := RenderText(r.Clone(), &buf); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(buf.String(), "Element Sizes of tag:size elements ("); n != 2 {
+		t.Errorf("expected element class sizes for sets and lists, actual: %d", n)
+	}
+}
+
+func TestExpectedPatterns(t *testing.T) {
+
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		return []byte("value"), nil
+	}}
+
+	opts, err := NewOptions(WithExpectedPatterns("^user:", "^session:"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newSampler(opts, AggregatorFunc(AnyKey))
+	s.conn = conn
+	for _, key := range []string{"user:1", "session:2", "usr:3", "tmp"} {
+		if err := s.observe(key, TypeString); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := s.stats[DefaultGroup]
+	assertInt(t, 4, int(r.KeyCount))
+	assertInt(t, 2, int(r.UnmatchedCount))
+	if !r.UnmatchedKeys["usr:3"] || !r.UnmatchedKeys["tmp"] || len(r.UnmatchedKeys) != 2 {
+		t.Errorf("unexpected unmatched keys: %v", r.UnmatchedKeys)
+	}
+
+	var buf bytes.Buffer
+	if err := RenderText(r.Clone(), &buf); err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, buf.String(), "--- Unmatched Keys (2, 50.00%) ---")
+
+	if _, err := NewOptions(WithExpectedPatterns("(")); err == nil {
+		t.Error("expected an invalid pattern to be rejected")
+	}
+}
+
+func TestSampleSetSmallerThanCount(t *testing.T) {
+
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		switch cmd {
+		case "SCARD":
+			return int64(2), nil
+		case "SRANDMEMBER":
+			if n := args[1].(int); n != 5 {
+				return nil, fmt.Errorf("expected a count of 5, actual: %d", n)
+			}
+			// redis returns only the distinct members that exist
+			return []interface{}{[]byte("a"), []byte("bb")}, nil
+		}
+		return nil, fmt.Errorf("unexpected command: %s", cmd)
+	}}
+
+	s := newSampler(Options{ElementsPerKey: 5}, AggregatorFunc(AnyKey))
+	s.conn = conn
+	if err := s.observe("myset", TypeSet); err != nil {
+		t.Fatal(err)
+	}
+
+	r := s.stats[DefaultGroup]
+	assertInt(t, 1, int(r.SetSizes[2]))
+	assertInt(t, 2, len(r.SetElementSizes))
+	assertInt(t, 1, int(r.SetElementSizes[1]))
+	assertInt(t, 1, int(r.SetElementSizes[2]))
+	assertInt(t, 2, len(r.SetElements))
+}
+
+func TestDialRetries(t *testing.T) {
+
+	// reserve a port, then close the listener so that connections are refused
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().(*net.TCPAddr)
+	l.Close()
+
+	start := time.Now()
+	_, err = dial(Options{Host: "127.0.0.1", Port: addr.Port, ConnectAttempts: 3, ConnectBackoff: 10 * time.Millisecond})
+	if err == nil {
+		t.Fatal("expected a connection error")
+	}
+
+	// 10ms + 20ms of backoff between the three attempts
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("expected dial to back off between attempts, elapsed: %s", elapsed)
+	}
+}
+
+// stubDialer is a Dialer that records the addresses it is asked to dial, and
+// returns one end of an in-memory pipe
+type stubDialer struct {
+	dialed []string
+}
+
+func (d *stubDialer) Dial(network, addr string) (net.Conn, error) {
+	d.dialed = append(d.dialed, network+"://"+addr)
+	client, server := net.Pipe()
+	server.Close()
+	return client, nil
+}
+
+func TestDialWithProxy(t *testing.T) {
+
+	dialer := &stubDialer{}
+	opts, err := NewOptions(WithHost("redis.internal"), WithPort(6380), WithProxy(dialer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := dial(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	if fmt.Sprint(dialer.dialed) != "[tcp://redis.internal:6380]" {
+		t.Errorf("expected the proxy dialer to be used, actual: %v", dialer.dialed)
+	}
+
+	if _, err := NewOptions(WithProxy(nil)); err == nil {
+		t.Error("expected an error for a nil Dialer")
+	}
+
+	dialErr := errors.New("bastion unreachable")
+	opts, err = NewOptions(WithDialer(func(network, addr string) (net.Conn, error) { return nil, dialErr }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dial(opts); err == nil || !strings.Contains(err.Error(), dialErr.Error()) {
+		t.Errorf("expected the dial func's error, actual: %v", err)
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+
+	var auth []interface{}
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		if cmd != "AUTH" {
+			return nil, fmt.Errorf("unexpected command: %s", cmd)
+		}
+		auth = args
+		return "OK", nil
+	}}
+
+	if err := authenticate(conn, nil); err != nil || auth != nil {
+		t.Fatalf("expected no AUTH without a provider, err: %v", err)
+	}
+
+	rotation := 0
+	provider := func() (string, string, error) {
+		rotation++
+		return "sampler", fmt.Sprintf("secret%d", rotation), nil
+	}
+	for i := 1; i <= 2; i++ {
+		if err := authenticate(conn, provider); err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(auth) != fmt.Sprintf("[sampler secret%d]", i) {
+			t.Errorf("unexpected AUTH arguments: %v", auth)
+		}
+	}
+
+	legacy := func() (string, string, error) { return "", "pw", nil }
+	if err := authenticate(conn, legacy); err != nil || fmt.Sprint(auth) != "[pw]" {
+		t.Errorf("expected a password-only AUTH, actual: %v (%v)", auth, err)
+	}
+
+	failing := func() (string, string, error) { return "", "", errors.New("vault unavailable") }
+	if err := authenticate(conn, failing); err == nil || !strings.Contains(err.Error(), "vault unavailable") {
+		t.Errorf("expected the provider error, actual: %v", err)
+	}
+}
+
+func TestReplicationRole(t *testing.T) {
+
+	info := "# Replication\r\nrole:slave\r\nmaster_host:10.0.0.1\r\n"
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		if cmd == "INFO" && len(args) == 1 && args[0] == "replication" {
+			return []byte(info), nil
+		}
+		return nil, fmt.Errorf("unexpected command: %s", cmd)
+	}}
+
+	role, err := replicationRole(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if role != "slave" {
+		t.Errorf("expected: slave, actual: %q", role)
+	}
+
+	info = "# Replication\r\nconnected_slaves:0\r\n"
+	if _, err = replicationRole(conn); err == nil {
+		t.Error("expected an error when no role is reported")
+	}
+}
+
+func TestProbeGrowth(t *testing.T) {
+
+	info := "# Keyspace\r\ndb0:keys=1100,expires=0,avg_ttl=0\r\n"
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		if cmd == "INFO" && len(args) == 1 && args[0] == "keyspace" {
+			return info, nil
+		}
+		return nil, fmt.Errorf("unexpected command: %s %v", cmd, args)
+	}}
+
+	summary := &RunSummary{}
+	s := newSampler(Options{GrowthProbe: 20 * time.Millisecond, Summary: summary}, AggregatorFunc(AnyKey))
+	s.conn = conn
+	start := time.Now().Add(-time.Second)
+	s.probeGrowth(1000, start)
+	s.summarize(1000, time.Since(start))
+
+	if summary.GrowthWindow < time.Second {
+		t.Errorf("expected a growth window of at least 1s, actual: %v", summary.GrowthWindow)
+	}
+	expected := 100 / summary.GrowthWindow.Seconds()
+	assertFloat(t, expected, summary.KeyGrowthRate, 1e-9)
+
+	// the probe waits for the remainder of the interval
+	before := time.Now()
+	s.probeGrowth(1200, before)
+	if time.Since(before) < 20*time.Millisecond {
+		t.Errorf("expected the probe to wait for the growth probe interval")
+	}
+	if s.growth >= 0 {
+		t.Errorf("expected a negative growth rate, actual: %f", s.growth)
+	}
+
+	conn.handler = func(cmd string, args ...interface{}) (interface{}, error) {
+		return nil, errors.New("connection reset")
+	}
+	s.probeGrowth(1000, start)
+	assertInt(t, 1, len(s.warnings))
+}
+
+// respDialer is a Dialer whose connections are served by `handler`, which
+// returns the raw RESP reply to each command
+type respDialer struct {
+	handler func(args []string) string
+}
+
+func (d respDialer) Dial(network, addr string) (net.Conn, error) {
+	client, server := net.Pipe()
+	go func() {
+		defer server.Close()
+		r := bufio.NewReader(server)
+		for {
+			var n int
+			if _, err := fmt.Fscanf(r, "*%d\r\n", &n); err != nil {
+				return
+			}
+			args := make([]string, n)
+			for i := range args {
+				var size int
+				if _, err := fmt.Fscanf(r, "$%d\r\n", &size); err != nil {
+					return
+				}
+				arg := make([]byte, size+2)
+				if _, err := io.ReadFull(r, arg); err != nil {
+					return
+				}
+				args[i] = string(arg[:size])
+			}
+			if _, err := io.WriteString(server, d.handler(args)); err != nil {
+				return
+			}
+		}
+	}()
+	return client, nil
+}
+
+func TestGrowthProbeSkippedOnFailure(t *testing.T) {
+
+	info := "# Keyspace\r\ndb0:keys=1000,expires=0,avg_ttl=0\r\n"
+	dialer := respDialer{handler: func(args []string) string {
+		if args[0] == "INFO" {
+			return fmt.Sprintf("$%d\r\n%s\r\n", len(info), info)
+		}
+		return "-ERR sampling failed\r\n"
+	}}
+
+	opts, err := NewOptions(WithProxy(dialer), WithAllowMaster(), WithMinSamples(10), WithGrowthProbe(time.Minute), WithLogger(&bufLogger{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if _, _, err = Run(opts, AggregatorFunc(AnyKey)); err == nil || !strings.Contains(err.Error(), "sampling failed") {
+		t.Errorf("expected the sampling error, actual: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("expected a failed run not to wait for the growth probe, elapsed: %s", elapsed)
+	}
+}
+
+func TestKeyCount(t *testing.T) {
+
+	info := "# Keyspace\r\ndb0:keys=1234,expires=0,avg_ttl=0\r\n"
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		if cmd == "INFO" && len(args) == 1 && args[0] == "keyspace" {
+			return info, nil
+		}
+		return nil, fmt.Errorf("unexpected command: %s %v", cmd, args)
+	}}
+
+	count, err := keyCount(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertInt(t, 1234, int(count))
+
+	info = "# Keyspace\r\n"
+	if _, err = keyCount(conn); err != ErrNoKeys {
+		t.Errorf("expected ErrNoKeys, actual: %v", err)
+	}
+}
+
+func TestKeyCountFunc(t *testing.T) {
+
+	dbsize := int64(42)
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		if cmd == "DBSIZE" {
+			return dbsize, nil
+		}
+		return nil, fmt.Errorf("unexpected command: %s %v", cmd, args)
+	}}
+
+	opts, err := NewOptions(WithKeyCountFunc(DBSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, err := countKeys(opts, conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertInt(t, 42, int(count))
+
+	dbsize = 0
+	if _, err = countKeys(opts, conn); err != ErrNoKeys {
+		t.Errorf("expected ErrNoKeys, actual: %v", err)
+	}
+
+	if _, err := NewOptions(WithKeyCountFunc(nil)); err == nil {
+		t.Error("expected an error for a nil KeyCount func")
+	}
+}
+
+func TestSampleCount(t *testing.T) {
+
+	cases := []struct {
+		opts Options
+		keys int64
+		want int
+	}{
+		{Options{MinSamples: 100}, 1000000, 100},
+		{Options{SampleRate: 0.1}, 1000, 100},
+		{Options{MinSamples: 500, SampleRate: 0.1}, 1000, 500},
+		{Options{MinSamples: 50, SampleRate: 0.1}, 1000, 100},
+		{Options{SampleRate: 0.1}, 5, 1},
+	}
+	for _, c := range cases {
+		if got := sampleCount(c.opts, c.keys); got != c.want {
+			t.Errorf("sampleCount(%+v, %d): expected %d, got %d", c.opts, c.keys, c.want, got)
+		}
+	}
+}
+
+func TestSamplerDefaults(t *testing.T) {
+
+	s := NewSampler(WithHost("redis.internal"), WithMinSamples(10), WithSizeOnly())
+	opts, err := s.Options(WithMinSamples(20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Host != "redis.internal" || !opts.SizeOnly {
+		t.Errorf("expected the defaults to be applied, actual: %+v", opts)
+	}
+	assertInt(t, 20, opts.MinSamples)
+
+	if _, err := s.Options(WithSampleRate(2)); err == nil {
+		t.Error("expected an error for an invalid per-call option")
+	}
+	if _, _, err := s.RunMulti(AggregatorFunc(AnyKey), []string{"redis.internal"}); err == nil {
+		t.Error("expected an error for an address without a port")
+	}
+
+	var mu sync.Mutex
+	var dialed []string
+	s = NewSampler(WithMinSamples(10), WithDialer(func(network, addr string) (net.Conn, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		dialed = append(dialed, addr)
+		return nil, errors.New("unreachable")
+	}))
+	if _, _, err := s.RunMulti(AggregatorFunc(AnyKey), []string{"shard0:6379", "shard1:6380"}); err == nil {
+		t.Error("expected the dial error")
+	}
+	sort.Strings(dialed)
+	if fmt.Sprint(dialed) != "[shard0:6379 shard1:6380]" {
+		t.Errorf("expected each instance to be dialed, actual: %v", dialed)
+	}
+}
+
+func TestEstimatePopulations(t *testing.T) {
+
+	s := newSampler(Options{}, AggregatorFunc(AnyKey))
+	a, b := NewResults(), NewResults()
+	a.KeyCount, b.KeyCount, b.VanishedKeys = 30, 9, 1
+	s.stats = map[string]*Results{"a": a, "b": b}
+	s.typeCounts[TypeString] = 39
+	s.vanished = 1
+
+	s.estimatePopulations(1000)
+	assertInt(t, 750, int(a.TotalKeys))
+	assertInt(t, 250, int(b.TotalKeys))
+	assertInt(t, 1000, int(Sum(a, b).TotalKeys))
+}
+
+// prefixServer is a respDialer serving a keyspace of 4 strings, `a:1`,
+// `a:2`, `b:1` and `b:2`, which RANDOMKEY returns in turn
+func prefixServer() respDialer {
+	info := "# Keyspace\r\ndb0:keys=4,expires=0,avg_ttl=0\r\n"
+	keys := []string{"a:1", "b:1", "a:2", "b:2"}
+	var next int
+	return respDialer{handler: func(args []string) string {
+		switch args[0] {
+		case "INFO":
+			return fmt.Sprintf("$%d\r\n%s\r\n", len(info), info)
+		case "RANDOMKEY":
+			key := keys[next%len(keys)]
+			next++
+			return fmt.Sprintf("$%d\r\n%s\r\n", len(key), key)
+		case "TYPE":
+			return "+string\r\n"
+		case "GET":
+			return "$5\r\nvalue\r\n"
+		}
+		return "-ERR unexpected command " + args[0] + "\r\n"
+	}}
+}
+
+func TestReckon(t *testing.T) {
+
+	byPrefix := AggregatorFunc(func(key string, vt ValueType) []string { return []string{key[:1]} })
+	reckon := func(format string, fns ...func(*Options) error) (string, error) {
+		var buf bytes.Buffer
+		fns = append([]func(*Options) error{WithProxy(prefixServer()), WithAllowMaster(), WithMinSamples(6), WithLogger(&bufLogger{})}, fns...)
+		err := Reckon(format, &buf, byPrefix, fns...)
+		return buf.String(), err
+	}
+
+	// one report per group, in order of name, with 6 keys sampled in all
+	out, err := reckon("text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(out, "# of keys sampled: 3") != 2 {
+		t.Errorf("expected a report of 3 keys for each group, actual: %s", out)
+	}
+
+	out, err = reckon("html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := strings.Index(out, "<h1>a <small>3 keys</small></h1>"), strings.Index(out, "<h1>b <small>3 keys</small></h1>")
+	if a < 0 || b < a {
+		t.Errorf("expected a report for group a, followed by one for group b")
+	}
+
+	out, err = reckon("json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := json.NewDecoder(strings.NewReader(out))
+	var names []string
+	var total int64
+	for dec.More() {
+		var r Results
+		if err := dec.Decode(&r); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, r.Name)
+		total += r.KeyCount
+	}
+	if fmt.Sprint(names) != "[a b]" {
+		t.Errorf("expected the reports of groups a and b, actual: %v", names)
+	}
+	assertInt(t, 6, int(total))
+
+	// a single merged report
+	out, err = reckon("text", WithMergedGroups())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(out, "# of keys sampled:") != 1 || !strings.Contains(out, "# of keys sampled: 6") {
+		t.Errorf("expected a single report of 6 keys, actual: %s", out)
+	}
+	out, err = reckon("html", WithMergedGroups())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, out, "<h1>all <small>6 keys</small></h1>")
+
+	if _, err = reckon("csv"); err == nil || err.Error() != "unknown report format: csv" {
+		t.Errorf("expected an unknown format error, actual: %v", err)
+	}
+}
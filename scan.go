@@ -0,0 +1,71 @@
+/*
+ * Copyright (C) 2015 zulily, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reckon
+
+import "github.com/garyburd/redigo/redis"
+
+// scanBatch is the COUNT hint given to each `SCAN` issued by a scanSource
+const scanBatch = 1000
+
+// scanSource is a KeySource that supplies the keys returned by successive
+// `SCAN` commands, from cursor `cursor`, until at least `limit` keys have
+// been supplied or the scan is complete.  A scan can only be resumed from
+// the cursor returned with a batch, so the final batch is supplied in full,
+// even if that exceeds the limit.
+type scanSource struct {
+	conn   redis.Conn
+	cursor uint64
+	limit  int
+
+	batch    []string
+	supplied int
+	complete bool
+}
+
+func (s *scanSource) Next() (string, bool, error) {
+	for len(s.batch) == 0 {
+		if s.complete || s.supplied >= s.limit {
+			return "", false, nil
+		}
+		replies, err := redis.Values(s.conn.Do("SCAN", s.cursor, "COUNT", scanBatch))
+		if err != nil {
+			return "", false, err
+		}
+		if _, err := redis.Scan(replies, &s.cursor, &s.batch); err != nil {
+			return "", false, err
+		}
+		s.complete = s.cursor == 0
+	}
+	key := s.batch[0]
+	s.batch = s.batch[1:]
+	s.supplied++
+	return key, true, nil
+}
+
+func (s *scanSource) Len() (int64, bool) {
+	return 0, false
+}
+
+// observeScan observes the keys of a slice of the keyspace, walked with
+// `SCAN` from ScanCursor, until ScanLimit keys have been examined, and
+// records the cursor from which the next slice begins
+func (s *sampler) observeScan() error {
+	src := &scanSource{conn: s.conn, cursor: s.opts.ScanCursor, limit: s.opts.ScanLimit}
+	err := s.observeSource(src)
+	s.nextCursor, s.scanComplete = src.cursor, src.complete
+	return err
+}
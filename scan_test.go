@@ -0,0 +1,83 @@
+/*
+ * Copyright (C) 2015 zulily, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reckon
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestObserveScan(t *testing.T) {
+
+	// a keyspace of five keys, returned two at a time
+	batches := map[string][]interface{}{
+		"0": {[]byte("7"), []interface{}{[]byte("a"), []byte("b")}},
+		"7": {[]byte("3"), []interface{}{[]byte("c"), []byte("d")}},
+		"3": {[]byte("0"), []interface{}{[]byte("e")}},
+	}
+	var scanned []string
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		switch cmd {
+		case "SCAN":
+			cursor := fmt.Sprint(args[0])
+			scanned = append(scanned, cursor)
+			return batches[cursor], nil
+		case "TYPE":
+			return "string", nil
+		case "GET":
+			return []byte("value"), nil
+		}
+		return nil, fmt.Errorf("unexpected command: %s", cmd)
+	}}
+
+	// a limit of 3 keys ends the first slice after the second batch
+	var summary RunSummary
+	s := newSampler(Options{ScanLimit: 3, Summary: &summary}, AggregatorFunc(AnyKey))
+	s.conn = conn
+	if err := s.observeScan(); err != nil {
+		t.Fatal(err)
+	}
+	s.summarize(5, time.Second)
+	assertInt(t, 4, int(s.stats[DefaultGroup].KeyCount))
+	assertInt(t, 3, int(summary.NextCursor))
+	if summary.ScanComplete {
+		t.Error("expected the scan to be incomplete")
+	}
+
+	// the next slice resumes from the cursor, and completes the scan
+	s = newSampler(Options{ScanLimit: 3, ScanCursor: summary.NextCursor, Summary: &summary}, AggregatorFunc(AnyKey))
+	s.conn = conn
+	if err := s.observeScan(); err != nil {
+		t.Fatal(err)
+	}
+	s.summarize(5, time.Second)
+	assertInt(t, 1, int(s.stats[DefaultGroup].KeyCount))
+	if !summary.ScanComplete || summary.NextCursor != 0 {
+		t.Errorf("expected the scan to be complete, actual cursor: %d", summary.NextCursor)
+	}
+	if fmt.Sprint(scanned) != "[0 7 3]" {
+		t.Errorf("unexpected SCAN cursors: %v", scanned)
+	}
+
+	if _, err := NewOptions(WithScanLimit(0, 0)); err == nil {
+		t.Error("expected an error for a ScanLimit less than 1")
+	}
+	if err := validateSampling(Options{ScanLimit: 10}); err != nil {
+		t.Errorf("expected MinSamples to be ignored with a ScanLimit: %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+/*
+ * Copyright (C) 2015 zulily, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reckon
+
+import (
+	"strings"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// HashSlotCount is the number of hash slots into which redis cluster divides
+// the keyspace
+const HashSlotCount = 16384
+
+// crc16 returns the CRC16 (XMODEM) checksum of `s`, as used by redis cluster
+// to assign keys to hash slots
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for b := 0; b < 8; b++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// HashSlot returns the redis cluster hash slot of `key`, as reported by
+// `CLUSTER KEYSLOT`.  If the key contains a non-empty hash tag (the substring
+// between its first `{` and the following `}`), only the tag is hashed.
+func HashSlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16(key) % HashSlotCount)
+}
+
+// inHashSlots reports whether `key` belongs to one of the HashSlots, which
+// is always true if none are configured
+func (s *sampler) inHashSlots(key string) bool {
+	if len(s.opts.HashSlots) == 0 {
+		return true
+	}
+	slot := HashSlot(key)
+	for _, hs := range s.opts.HashSlots {
+		if slot == hs {
+			return true
+		}
+	}
+	return false
+}
+
+// observeSlots observes every key in each of `slots`, as returned by
+// `CLUSTER GETKEYSINSLOT` from the cluster node that owns them
+func (s *sampler) observeSlots(slots []int) error {
+	var keys []string
+	for _, slot := range slots {
+		n, err := redis.Int(s.conn.Do("CLUSTER", "COUNTKEYSINSLOT", slot))
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			continue
+		}
+		slotKeys, err := redis.Strings(s.conn.Do("CLUSTER", "GETKEYSINSLOT", slot, n))
+		if err != nil {
+			return err
+		}
+		keys = append(keys, slotKeys...)
+	}
+	return s.observeSource(NewKeyList(keys))
+}
@@ -0,0 +1,76 @@
+/*
+ * Copyright (C) 2015 zulily, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reckon
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestHashSlot(t *testing.T) {
+
+	// slots as reported by `CLUSTER KEYSLOT`
+	for key, slot := range map[string]int{
+		"":                     0,
+		"foo":                  12182,
+		"123456789":            12739,
+		"{user1000}.following": 3443,
+		"{user1000}.followers": 3443,
+		"foo{}{bar}":           8363,
+		"foo{{bar}}zap":        4015,
+	} {
+		if s := HashSlot(key); s != slot {
+			t.Errorf("expected %q in slot %d, actual: %d", key, slot, s)
+		}
+	}
+}
+
+func TestObserveSlots(t *testing.T) {
+
+	conn := &fakeConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		switch {
+		case cmd == "CLUSTER" && args[0] == "COUNTKEYSINSLOT":
+			return int64(2), nil
+		case cmd == "CLUSTER" && args[0] == "GETKEYSINSLOT":
+			return []interface{}{[]byte("foo"), []byte("{foo}:bar")}, nil
+		case cmd == "TYPE":
+			return "string", nil
+		case cmd == "GET":
+			return []byte("value"), nil
+		}
+		return nil, fmt.Errorf("unexpected command: %s", cmd)
+	}}
+
+	s := newSampler(Options{HashSlots: []int{12182}, Logger: &bufLogger{}}, AggregatorFunc(AnyKey))
+	s.conn = conn
+	if err := s.observeSlots(s.opts.HashSlots); err != nil {
+		t.Fatal(err)
+	}
+	assertInt(t, 2, int(s.stats[DefaultGroup].KeyCount))
+
+	// keys supplied by a KeySource are filtered to the slot
+	s = newSampler(Options{HashSlots: []int{12182}, Logger: &bufLogger{}}, AggregatorFunc(AnyKey))
+	s.conn = conn
+	if err := s.observeSource(NewKeyList([]string{"foo", "bar", "{foo}x"})); err != nil {
+		t.Fatal(err)
+	}
+	assertInt(t, 2, int(s.stats[DefaultGroup].KeyCount))
+
+	if _, err := NewOptions(WithHashSlot(HashSlotCount)); err == nil {
+		t.Error("expected an error for an out-of-range slot")
+	}
+}
@@ -0,0 +1,181 @@
+/*
+ * Copyright (C) 2015 zulily, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reckon
+
+import (
+	"database/sql"
+	"sort"
+)
+
+// A distribution is one of the frequency maps of a Results instance, labelled
+// with the type of value it describes and what it measures
+type distribution struct {
+	Type    ValueType
+	Measure string
+	Freq    map[int]int64
+}
+
+// distributions returns the frequency maps of the method receiver that
+// describe values of a single type.  Per-class distributions are omitted.
+func (r *Results) distributions() []distribution {
+	return []distribution{
+		{TypeString, "size", r.StringSizes},
+		{TypeString, "set_bits", r.StringBitCounts},
+		{TypeList, "size", r.ListSizes},
+		{TypeList, "element_size", r.ListElementSizes},
+		{TypeList, "head_size", r.ListHeadSizes},
+		{TypeList, "tail_size", r.ListTailSizes},
+		{TypeList, "byte_size", r.ListByteSizes},
+		{TypeSet, "size", r.SetSizes},
+		{TypeSet, "element_size", r.SetElementSizes},
+		{TypeSet, "byte_size", r.SetByteSizes},
+		{TypeSortedSet, "size", r.SortedSetSizes},
+		{TypeSortedSet, "element_size", r.SortedSetElementSizes},
+		{TypeSortedSet, "byte_size", r.SortedSetByteSizes},
+		{TypeHash, "size", r.HashSizes},
+		{TypeHash, "field_size", r.HashElementSizes},
+		{TypeHash, "value_size", r.HashValueSizes},
+		{TypeHash, "byte_size", r.HashByteSizes},
+	}
+}
+
+// sqlSchema creates the tables written by WriteSQL
+var sqlSchema = []string{
+	`CREATE TABLE IF NOT EXISTS results (
+		group_name    TEXT NOT NULL,
+		key_count     INTEGER NOT NULL,
+		total_keys    INTEGER NOT NULL,
+		vanished_keys INTEGER NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS summary (
+		group_name TEXT NOT NULL,
+		type       TEXT NOT NULL,
+		keys       INTEGER NOT NULL,
+		total_size INTEGER NOT NULL,
+		mean_size  REAL NOT NULL,
+		p50_size   INTEGER NOT NULL,
+		p99_size   INTEGER NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS frequencies (
+		group_name TEXT NOT NULL,
+		type       TEXT NOT NULL,
+		measure    TEXT NOT NULL,
+		size       INTEGER NOT NULL,
+		count      INTEGER NOT NULL
+	)`,
+}
+
+// WriteSQLite writes the Results of each of the aggregation groups in
+// `groups` to the SQLite database at `path`, which is created if it does not
+// exist.  See WriteSQL for the schema.  reckon does not itself depend on a
+// SQLite driver: the caller must register one under the name "sqlite", e.g.
+// by importing the pure-Go modernc.org/sqlite package, which requires no cgo.
+func WriteSQLite(groups map[string]*Results, path string) error {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return err
+	}
+	if err = WriteSQL(db, groups); err != nil {
+		db.Close()
+		return err
+	}
+	return db.Close()
+}
+
+// WriteSQL writes the Results of each of the aggregation groups in `groups`
+// to `db`, in a single transaction, creating the following tables if they do
+// not exist, for ad-hoc querying:
+//
+//	results(group_name, key_count, total_keys, vanished_keys)
+//	summary(group_name, type, keys, total_size, mean_size, p50_size, p99_size)
+//	frequencies(group_name, type, measure, size, count)
+//
+// A row of `summary` holds the TypeSummary of one type in one group (see
+// Results.Summary).  A row of `frequencies` holds the number of times a size
+// occurred in one of a group's distributions; `measure` names the
+// distribution, e.g. "size" for the sizes of values, or "element_size" for the
+// sizes of the elements of collections.  Rows are appended to any already
+// present.  Statements use `?` placeholders, as supported by SQLite.
+func WriteSQL(db *sql.DB, groups map[string]*Results) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if err = writeSQL(tx, groups); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// writeSQL creates the schema and inserts the rows of WriteSQL in `tx`
+func writeSQL(tx *sql.Tx, groups map[string]*Results) error {
+	for _, stmt := range sqlSchema {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	insertGroup, err := tx.Prepare("INSERT INTO results VALUES (?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer insertGroup.Close()
+	insertSummary, err := tx.Prepare("INSERT INTO summary VALUES (?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer insertSummary.Close()
+	insertFreq, err := tx.Prepare("INSERT INTO frequencies VALUES (?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer insertFreq.Close()
+
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		r := groups[name]
+		if _, err := insertGroup.Exec(name, r.KeyCount, r.TotalKeys, r.VanishedKeys); err != nil {
+			return err
+		}
+
+		summary := r.Summary()
+		for _, vt := range sampledTypes {
+			ts, ok := summary.Types[vt]
+			if !ok {
+				continue
+			}
+			if _, err := insertSummary.Exec(name, string(vt), ts.Keys, ts.TotalSize, ts.MeanSize, ts.P50Size, ts.P99Size); err != nil {
+				return err
+			}
+		}
+
+		for _, d := range r.distributions() {
+			for size, count := range d.Freq {
+				if _, err := insertFreq.Exec(name, string(d.Type), d.Measure, size, count); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+/*
+ * Copyright (C) 2015 zulily, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reckon
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// recordingDriver is a database/sql driver that records the statements
+// executed on it, and the arguments of each
+type recordingDriver struct {
+	execs     []string
+	committed bool
+}
+
+func (d *recordingDriver) Open(name string) (driver.Conn, error) { return recordingConn{d}, nil }
+
+type recordingConn struct{ d *recordingDriver }
+
+func (c recordingConn) Prepare(query string) (driver.Stmt, error) {
+	return recordingStmt{d: c.d, query: query}, nil
+}
+func (c recordingConn) Close() error              { return nil }
+func (c recordingConn) Begin() (driver.Tx, error) { return recordingTx{c.d}, nil }
+
+type recordingTx struct{ d *recordingDriver }
+
+func (tx recordingTx) Commit() error   { tx.d.committed = true; return nil }
+func (tx recordingTx) Rollback() error { return nil }
+
+type recordingStmt struct {
+	d     *recordingDriver
+	query string
+}
+
+func (s recordingStmt) Close() error  { return nil }
+func (s recordingStmt) NumInput() int { return -1 }
+func (s recordingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.execs = append(s.d.execs, fmt.Sprint(s.query, args))
+	return driver.RowsAffected(1), nil
+}
+func (s recordingStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, fmt.Errorf("unexpected query: %s", s.query)
+}
+
+func TestWriteSQL(t *testing.T) {
+
+	d := &recordingDriver{}
+	sql.Register("reckon-recording", d)
+	db, err := sql.Open("reckon-recording", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err := WriteSQL(db, map[string]*Results{"sample": sampleResults()}); err != nil {
+		t.Fatal(err)
+	}
+	if !d.committed {
+		t.Error("expected the transaction to be committed")
+	}
+
+	all := strings.Join(d.execs, "\n")
+	assertContains(t, all, "CREATE TABLE IF NOT EXISTS frequencies")
+	assertContains(t, all, "INSERT INTO results VALUES (?, ?, ?, ?)[sample 6 0 0]")
+	assertContains(t, all, "INSERT INTO summary VALUES (?, ?, ?, ?, ?, ?, ?)[sample string 2 10 5 5 5]")
+	assertContains(t, all, "INSERT INTO frequencies VALUES (?, ?, ?, ?, ?)[sample set element_size 6 1]")
+}
@@ -16,7 +16,17 @@
 
 package reckon
 
-import "math"
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
 
 const (
 	// MaxExampleKeys sets an upper bound on the number of example keys that will
@@ -36,16 +46,139 @@ type Statistics struct {
 	Min    int
 	Max    int
 	StdDev float64
+
+	// Count is the number of observations summarized by the statistics
+	Count int64
+
+	// MeanCI is the half-width of the approximate 95% confidence interval on
+	// the mean, i.e. the true mean lies within Mean ± MeanCI with roughly 95%
+	// confidence
+	MeanCI float64
 }
 
+// z95 is the z-score corresponding to a two-sided 95% confidence level
+const z95 = 1.959964
+
 // NewStatistics creates a new zero-valued Statistics instance
 func NewStatistics() *Statistics {
 	return &Statistics{
 		Mean:   math.NaN(),
 		StdDev: math.NaN(),
+		MeanCI: math.NaN(),
+	}
+}
+
+// WithPopulation returns a copy of `s` whose confidence interval has been
+// narrowed by the finite population correction, given that the observations
+// were sampled from a population (e.g. keyspace) of `population` members.  If
+// the population is not larger than the number of observations (which can
+// happen, since keys are sampled with replacement), `s` is returned as is.
+func (s Statistics) WithPopulation(population int64) Statistics {
+	if population > s.Count && s.Count > 0 {
+		s.MeanCI *= math.Sqrt(float64(population-s.Count) / float64(population-1))
+	}
+	return s
+}
+
+// CoeffVar returns the coefficient of variation of the observations, i.e. the
+// ratio of their standard deviation to their mean, or NaN if the mean is zero
+func (s Statistics) CoeffVar() float64 {
+	if s.Mean == 0 {
+		return math.NaN()
+	}
+	return s.StdDev / s.Mean
+}
+
+// zScore returns the z-score corresponding to a two-sided `confidence` level
+// (e.g. 0.95)
+func zScore(confidence float64) float64 {
+	return math.Sqrt2 * math.Erfinv(confidence)
+}
+
+// runningStats accumulates the mean and variance of a stream of observations,
+// using Welford's online algorithm
+type runningStats struct {
+	n    int64
+	mean float64
+	m2   float64
+}
+
+func (r *runningStats) add(x float64) {
+	r.n++
+	delta := x - r.mean
+	r.mean += delta / float64(r.n)
+	r.m2 += delta * (x - r.mean)
+}
+
+// meanCI returns the half-width of the confidence interval on the mean that
+// corresponds to the z-score `z`
+func (r *runningStats) meanCI(z float64) float64 {
+	if r.n < 2 {
+		return math.NaN()
+	}
+	return z * math.Sqrt(r.m2/float64(r.n-1)/float64(r.n))
+}
+
+// spread returns the mean and sample standard deviation of the observations,
+// the latter being NaN for fewer than two observations
+func (r *runningStats) spread() Spread {
+	if r.n < 2 {
+		return Spread{Mean: r.mean, StdDev: math.NaN()}
+	}
+	return Spread{Mean: r.mean, StdDev: math.Sqrt(r.m2 / float64(r.n-1))}
+}
+
+// maxSizePairs is the number of (cardinality, bytes) pairs retained by a
+// sizePairs
+const maxSizePairs = 10000
+
+// sizePairs retains a uniformly-chosen sample of at most maxSizePairs of the
+// (cardinality, total bytes) pairs observed for collections of one type
+type sizePairs struct {
+	seen  int64
+	pairs [][2]float64
+}
+
+func (p *sizePairs) add(cardinality, bytes int) {
+	p.seen++
+	pair := [2]float64{float64(cardinality), float64(bytes)}
+	if len(p.pairs) < maxSizePairs {
+		p.pairs = append(p.pairs, pair)
+		return
+	}
+	if j := rand.Int63n(p.seen); j < maxSizePairs {
+		p.pairs[j] = pair
 	}
 }
 
+// correlation returns the Pearson correlation coefficient of the retained
+// pairs, or NaN if there are fewer than two pairs, or either the cardinality
+// or the bytes do not vary
+func (p *sizePairs) correlation() float64 {
+	if len(p.pairs) < 2 {
+		return math.NaN()
+	}
+	var mx, my float64
+	for _, pair := range p.pairs {
+		mx += pair[0]
+		my += pair[1]
+	}
+	n := float64(len(p.pairs))
+	mx, my = mx/n, my/n
+
+	var sxy, sxx, syy float64
+	for _, pair := range p.pairs {
+		dx, dy := pair[0]-mx, pair[1]-my
+		sxy += dx * dy
+		sxx += dx * dx
+		syy += dy * dy
+	}
+	if sxx == 0 || syy == 0 {
+		return math.NaN()
+	}
+	return sxy / math.Sqrt(sxx*syy)
+}
+
 // powerOfTwo returns the smallest power of two that is greater than or equal to `n`
 func powerOfTwo(n int) int {
 	p := 1
@@ -101,22 +234,234 @@ func ComputeStatistics(m map[int]int64) Statistics {
 		kf, vf := float64(k), float64(v)
 		sd += ((kf - mean) * (kf - mean)) * vf
 	}
+	stdDev := math.Sqrt(sd / float64(count-1))
 
 	return Statistics{
 		Mean:   mean,
 		Min:    min,
 		Max:    max,
-		StdDev: math.Sqrt(sd / float64(count-1)),
+		StdDev: stdDev,
+		Count:  count,
+		MeanCI: z95 * stdDev / math.Sqrt(float64(count)),
 	}
 }
 
-// add adds `elem` to the "set" (a map[<type>]bool is an idiomatic golang "set") if the
-// current size of the set is less than `maxsize`
-func add(set map[string]bool, elem string, maxsize int) {
-	if len(set) >= maxsize {
-		return
+// A Concentration describes how unevenly a total (e.g. of memory) is shared
+// among the observations of a frequency map.  See ComputeConcentration.
+type Concentration struct {
+	// Top1, Top5 and Top10 are the fractions of the total accounted for by
+	// the largest 1%, 5% and 10% of the observations (at least one)
+	Top1, Top5, Top10 float64
+
+	// Gini is the Gini coefficient of the observations: 0 if they are all
+	// the same size, approaching 1 as the total is concentrated in fewer of
+	// them
+	Gini float64
+}
+
+// ComputeConcentration computes the Concentration of the sizes in frequency
+// map `m`.  Every field is NaN if `m` is empty or its sizes sum to zero.
+func ComputeConcentration(m map[int]int64) Concentration {
+	sizes := make([]int, 0, len(m))
+	var n, total int64
+	for size, count := range m {
+		sizes = append(sizes, size)
+		n += count
+		total += int64(size) * count
+	}
+	if total == 0 {
+		return Concentration{Top1: math.NaN(), Top5: math.NaN(), Top10: math.NaN(), Gini: math.NaN()}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(sizes)))
+
+	// the share of the total held by the largest `fraction` of observations
+	top := func(fraction float64) float64 {
+		remaining := int64(math.Ceil(fraction * float64(n)))
+		var sum int64
+		for _, size := range sizes {
+			if remaining <= 0 {
+				break
+			}
+			count := m[size]
+			if count > remaining {
+				count = remaining
+			}
+			sum += int64(size) * count
+			remaining -= count
+		}
+		return float64(sum) / float64(total)
+	}
+
+	// G = 2 * Σ(rank * size) / (n * total) - (n + 1) / n, ranking the
+	// observations in ascending order of size from 1
+	var weighted, rank float64
+	for i := len(sizes) - 1; i >= 0; i-- {
+		count := float64(m[sizes[i]])
+		// the sum of the ranks rank+1 ... rank+count
+		weighted += float64(sizes[i]) * (count*rank + count*(count+1)/2)
+		rank += count
+	}
+	gini := 2*weighted/(float64(n)*float64(total)) - (float64(n)+1)/float64(n)
+
+	return Concentration{Top1: top(0.01), Top5: top(0.05), Top10: top(0.10), Gini: gini}
+}
+
+// MemoryConcentration computes the Concentration of the memory used by the
+// sampled keys (see KeyMemorySizes), e.g. to find whether a few large keys
+// account for most of the memory used
+func (r *Results) MemoryConcentration() Concentration {
+	return ComputeConcentration(r.KeyMemorySizes)
+}
+
+// An IdleBucket counts the keys whose idle time falls within a coarse range
+type IdleBucket struct {
+	Label string
+	Count int64
+}
+
+// idleBounds are the exclusive upper bounds, in seconds, of each IdleBucket
+// but the last
+var idleBounds = []struct {
+	label string
+	bound int
+}{
+	{"< 1 minute", 60},
+	{"< 1 hour", 60 * 60},
+	{"< 1 day", 24 * 60 * 60},
+}
+
+// ComputeIdleBuckets groups a frequency map of idle times (in seconds) into
+// buckets of seconds, minutes, hours and days
+func ComputeIdleBuckets(m map[int]int64) []IdleBucket {
+	buckets := make([]IdleBucket, len(idleBounds)+1)
+	for i, b := range idleBounds {
+		buckets[i].Label = b.label
+	}
+	buckets[len(idleBounds)].Label = ">= 1 day"
+
+	for k, v := range m {
+		i := 0
+		for i < len(idleBounds) && k >= idleBounds[i].bound {
+			i++
+		}
+		buckets[i].Count += v
+	}
+	return buckets
+}
+
+// add offers `elem` to the "set" (a map[<type>]bool is an idiomatic golang
+// "set"), which holds at most `maxsize` elements.  Elements are retained using
+// reservoir sampling (Algorithm R), so that the set is a uniform random subset
+// of all the distinct elements offered, rather than the first `maxsize` of
+// them.  `seen` counts the distinct elements offered to the set so far.  add
+// reports whether `elem` was added, and the member it replaced, if any.
+func add(set map[string]bool, seen *int64, elem string, maxsize int) (evicted string, added bool) {
+	if set[elem] {
+		return "", false
+	}
+	if *seen < int64(len(set)) {
+		// the set was populated directly, rather than via add
+		*seen = int64(len(set))
+	}
+	*seen++
+	if len(set) < maxsize {
+		set[elem] = true
+		return "", true
+	}
+
+	// the new element replaces a uniformly-chosen member with probability
+	// maxsize/seen
+	j := rand.Int63n(*seen)
+	if j >= int64(len(set)) {
+		return "", false
+	}
+	for k := range set {
+		if j == 0 {
+			delete(set, k)
+			evicted = k
+			break
+		}
+		j--
 	}
 	set[elem] = true
+	return evicted, true
+}
+
+// A memoryBudget caps the total length of the examples retained by all of the
+// Results that share it.  See the `MemoryBudget` field of Options.
+type memoryBudget struct {
+	limit, used int64
+
+	// exhausted is set once an example has been discarded for lack of budget
+	exhausted bool
+}
+
+// retain adds `elem` to an example set of the method receiver, like add,
+// unless doing so would exceed the receiver's memoryBudget, if any
+func (r *Results) retain(set map[string]bool, seen *int64, elem string, maxsize int) {
+	b := r.budget
+	if b == nil || set[elem] {
+		add(set, seen, elem, maxsize)
+		return
+	}
+	if b.used+int64(len(elem)) > b.limit {
+		b.exhausted = true
+		return
+	}
+	if evicted, ok := add(set, seen, elem, maxsize); ok {
+		b.used += int64(len(elem) - len(evicted))
+	}
+}
+
+// exampleCounts tracks the number of distinct elements offered to each of the
+// example sets of a Results instance, for reservoir sampling
+type exampleCounts struct {
+	stringKeys, stringValues           int64
+	setKeys, setElements               int64
+	sortedSetKeys, sortedSetElements   int64
+	hashKeys, hashElements, hashValues int64
+	listKeys, listElements             int64
+	unmatchedKeys, binaryKeys          int64
+	nonUniformKeys                     int64
+}
+
+// countExamples returns exampleCounts for the example sets of `r`, assuming
+// that each set has been offered only the elements that it holds
+func countExamples(r *Results) exampleCounts {
+	return exampleCounts{
+		stringKeys:        int64(len(r.StringKeys)),
+		stringValues:      int64(len(r.StringValues)),
+		setKeys:           int64(len(r.SetKeys)),
+		setElements:       int64(len(r.SetElements)),
+		sortedSetKeys:     int64(len(r.SortedSetKeys)),
+		sortedSetElements: int64(len(r.SortedSetElements)),
+		hashKeys:          int64(len(r.HashKeys)),
+		hashElements:      int64(len(r.HashElements)),
+		hashValues:        int64(len(r.HashValues)),
+		listKeys:          int64(len(r.ListKeys)),
+		listElements:      int64(len(r.ListElements)),
+		unmatchedKeys:     int64(len(r.UnmatchedKeys)),
+		binaryKeys:        int64(len(r.BinaryKeys)),
+		nonUniformKeys:    int64(len(r.NonUniformKeys)),
+	}
+}
+
+// merge adds the counts in `other` to the method receiver
+func (c *exampleCounts) merge(other exampleCounts) {
+	c.stringKeys += other.stringKeys
+	c.stringValues += other.stringValues
+	c.setKeys += other.setKeys
+	c.setElements += other.setElements
+	c.sortedSetKeys += other.sortedSetKeys
+	c.sortedSetElements += other.sortedSetElements
+	c.hashKeys += other.hashKeys
+	c.hashElements += other.hashElements
+	c.hashValues += other.hashValues
+	c.listKeys += other.listKeys
+	c.listElements += other.listElements
+	c.unmatchedKeys += other.unmatchedKeys
+	c.binaryKeys += other.binaryKeys
+	c.nonUniformKeys += other.nonUniformKeys
 }
 
 // Results stores data about sampled redis data structures. Map keys represent
@@ -127,23 +472,85 @@ type Results struct {
 	Name     string
 	KeyCount int64
 
+	// Version is the version of the serialized form of Results (see
+	// ResultsVersion).  Results loaded from an older or newer form are
+	// converted to the current one.
+	Version int
+
+	// TotalKeys is the estimated number of keys belonging to the group in the
+	// redis instance(s) from which the results were sampled: the group's
+	// share of the sampled keys, scaled to the instance's key count.  It is
+	// the population of the group's keys, so the TotalKeys of the groups of
+	// one instance, and of the same group across instances, can be summed.
+	TotalKeys int64
+
+	// VanishedKeys is the number of sampled keys assigned to the group that
+	// no longer existed (i.e. had type `none`) by the time they were to be
+	// observed.  The Aggregator assigns such keys as keys of type
+	// TypeNone, so a group that is chosen by type counts none.  A high count
+	// relative to KeyCount indicates a rapidly changing keyspace.
+	VanishedKeys int64
+
+	// Approximate indicates that sizes are recorded in the frequency maps
+	// using approximate, bounded histograms (see ApproxHistogram), rather
+	// than exactly.
+	Approximate bool
+
+	// ScaleFactor, if non-zero, indicates that every count in the method
+	// receiver is an estimate for the full keyspace, extrapolated from the
+	// sampled counts by Scale.
+	ScaleFactor float64
+
+	// UniformElementSize and UniformMaxCV, if non-zero, are the element size
+	// that was expected of every sampled set, sorted set, list and hash field
+	// (see the `UniformElementSize` field of Options), and the coefficient of
+	// variation above which NonUniformTypes flags a type's element sizes.
+	UniformElementSize int
+	UniformMaxCV       float64
+
+	// NonUniformCount is the number of sampled keys with an element (or hash
+	// field) whose size is not UniformElementSize, and NonUniformKeys holds
+	// examples of them.  They are only populated when sampling with
+	// UniformElementSize.
+	NonUniformCount int64
+	NonUniformKeys  map[string]bool
+
 	// Strings
 	StringSizes  map[int]int64
 	StringKeys   map[string]bool
 	StringValues map[string]bool
 
+	// StringIntegers is the number of sampled string values that redis can
+	// store using its compact integer encoding
+	StringIntegers int64
+
+	// StringBitCounts is the distribution of the number of set bits in
+	// sampled string values, as reported by `BITCOUNT`.  It is only populated
+	// when sampling with BitmapStats.
+	StringBitCounts map[int]int64
+
 	// Sets
 	SetSizes        map[int]int64
 	SetElementSizes map[int]int64
 	SetKeys         map[string]bool
 	SetElements     map[string]bool
 
+	// SetElementClassSizes holds an element size distribution for each
+	// class of set member, keyed by class.  It is only populated when
+	// sampling with an ElementAggregator.
+	SetElementClassSizes map[string]map[int]int64
+
 	// Sorted Sets
 	SortedSetSizes        map[int]int64
 	SortedSetElementSizes map[int]int64
 	SortedSetKeys         map[string]bool
 	SortedSetElements     map[string]bool
 
+	// SortedSetElementClassSizes holds an element size distribution for
+	// each class of sorted set member, keyed by class.  It is only populated
+	// when sampling with an ElementAggregator.
+	SortedSetElementClassSizes map[string]map[int]int64
+
 	// Hashes
 	HashSizes        map[int]int64
 	HashElementSizes map[int]int64
@@ -152,30 +559,118 @@ type Results struct {
 	HashElements     map[string]bool
 	HashValues       map[string]bool
 
+	// HashFieldValueSizes holds a value size distribution for each class of
+	// hash field, keyed by class.  It is only populated when sampling with a
+	// HashFieldAggregator.
+	HashFieldValueSizes map[string]map[int]int64
+
+	// HashByteSizes is the distribution of the estimated total length, in
+	// bytes, of the fields and values of each sampled hash.  It is only
+	// populated when sampling with HashByteEstimates.
+	HashByteSizes map[int]int64
+
+	// SetByteSizes, SortedSetByteSizes and ListByteSizes are the
+	// distributions of the estimated total length, in bytes, of the elements
+	// of each sampled set, sorted set and list.  They are only populated when
+	// sampling with ByteEstimates.
+	SetByteSizes       map[int]int64
+	SortedSetByteSizes map[int]int64
+	ListByteSizes      map[int]int64
+
 	// Lists
 	ListSizes        map[int]int64
 	ListElementSizes map[int]int64
 	ListKeys         map[string]bool
 	ListElements     map[string]bool
+
+	// ListElementClassSizes holds an element size distribution for each
+	// class of list element, keyed by class.  It is only populated when
+	// sampling with an ElementAggregator.
+	ListElementClassSizes map[string]map[int]int64
+
+	// ListHeadSizes and ListTailSizes are the distributions of the sizes of
+	// the first and last elements of each sampled list.  They are only
+	// populated when sampling with ListEnds.
+	ListHeadSizes map[int]int64
+	ListTailSizes map[int]int64
+
+	// UnmatchedCount is the number of sampled keys (of any type) that matched
+	// none of the ExpectedPatterns, and UnmatchedKeys holds examples of them.
+	// They are only populated when sampling with ExpectedPatterns.
+	UnmatchedCount int64
+	UnmatchedKeys  map[string]bool
+
+	// BinaryKeyCount is the number of sampled keys (of any type) whose names
+	// are not valid UTF-8, or contain non-printable characters, and
+	// BinaryKeys holds examples of them.  Such keys are recorded in every
+	// example set with those bytes hex-escaped (see EscapeKey).
+	BinaryKeyCount int64
+	BinaryKeys     map[string]bool
+
+	// OtherTypes is the number of sampled keys of each type that reckon does
+	// not sample, by the exact type name returned by `TYPE`.  It is only
+	// populated when sampling with OtherTypes.
+	OtherTypes map[string]int64
+
+	// BucketKeys holds example keys of each type, keyed by the power-of-two
+	// size bucket (see ComputePowerOfTwoFreq) into which their sizes fall.
+	// It is only populated when sampling with BucketExamples.
+	BucketKeys map[ValueType]map[int]map[string]bool
+
+	// Sources maps example keys, values and elements to the name of the
+	// Results from which they were merged by MergeFrom.  Examples that were
+	// not merged by MergeFrom have no source.
+	Sources map[string]string
+
+	// AccessFrequencies is the distribution of the logarithmic access
+	// frequency counters of sampled keys (of any type), as reported by
+	// `OBJECT FREQ`.  It is only populated when sampling with AccessFreq.
+	AccessFrequencies map[int]int64
+
+	// IdleTimes is the distribution of the number of seconds since sampled
+	// keys (of any type) were last accessed, as reported by `OBJECT IDLETIME`.
+	// It is only populated when sampling with IdleTime.
+	IdleTimes map[int]int64
+
+	// KeyMemorySizes is the distribution of the memory, in bytes, used by
+	// sampled keys (of any type) and their values, as reported by `MEMORY
+	// USAGE`.  It is only populated when sampling with KeyMemory.
+	KeyMemorySizes map[int]int64
+
+	// seen counts the elements offered to each example set, and bucketSeen
+	// those offered to each set of BucketKeys
+	seen       exampleCounts
+	bucketSeen map[sizeBucket]int64
+
+	// budget, if non-nil, caps the examples retained by this and other Results
+	budget *memoryBudget
 }
 
 // NewResults constructs a new, zero-valued Results struct
 func NewResults() *Results {
 	return &Results{
+		Version: ResultsVersion,
+
 		StringSizes:  make(map[int]int64),
 		StringKeys:   make(map[string]bool),
 		StringValues: make(map[string]bool),
 
+		StringBitCounts: make(map[int]int64),
+
 		SetSizes:        make(map[int]int64),
 		SetElementSizes: make(map[int]int64),
 		SetKeys:         make(map[string]bool),
 		SetElements:     make(map[string]bool),
 
+		SetElementClassSizes: make(map[string]map[int]int64),
+
 		SortedSetSizes:        make(map[int]int64),
 		SortedSetElementSizes: make(map[int]int64),
 		SortedSetKeys:         make(map[string]bool),
 		SortedSetElements:     make(map[string]bool),
 
+		SortedSetElementClassSizes: make(map[string]map[int]int64),
+
 		HashSizes:        make(map[int]int64),
 		HashElementSizes: make(map[int]int64),
 		HashValueSizes:   make(map[int]int64),
@@ -183,10 +678,30 @@ func NewResults() *Results {
 		HashElements:     make(map[string]bool),
 		HashValues:       make(map[string]bool),
 
+		HashFieldValueSizes: make(map[string]map[int]int64),
+		HashByteSizes:       make(map[int]int64),
+		SetByteSizes:        make(map[int]int64),
+		SortedSetByteSizes:  make(map[int]int64),
+		ListByteSizes:       make(map[int]int64),
+
 		ListSizes:        make(map[int]int64),
 		ListElementSizes: make(map[int]int64),
 		ListKeys:         make(map[string]bool),
 		ListElements:     make(map[string]bool),
+
+		ListElementClassSizes: make(map[string]map[int]int64),
+		ListHeadSizes:         make(map[int]int64),
+		ListTailSizes:         make(map[int]int64),
+
+		UnmatchedKeys:     make(map[string]bool),
+		OtherTypes:        make(map[string]int64),
+		BucketKeys:        make(map[ValueType]map[int]map[string]bool),
+		BinaryKeys:        make(map[string]bool),
+		NonUniformKeys:    make(map[string]bool),
+		Sources:           make(map[string]string),
+		AccessFrequencies: make(map[int]int64),
+		IdleTimes:         make(map[int]int64),
+		KeyMemorySizes:    make(map[int]int64),
 	}
 }
 
@@ -241,7 +756,22 @@ func trimAndSum(m map[int]int64, threshold float64) int64 {
 // can be used to combine sampling results from multiple redis instances into a
 // single result set.
 func (r *Results) Merge(other *Results) {
+	r.initMaps()
 	r.KeyCount += other.KeyCount
+	r.TotalKeys += other.TotalKeys
+	r.VanishedKeys += other.VanishedKeys
+	r.Approximate = r.Approximate || other.Approximate
+	if r.ScaleFactor == 0 {
+		r.ScaleFactor = other.ScaleFactor
+	}
+	if r.UniformElementSize == 0 {
+		r.UniformElementSize, r.UniformMaxCV = other.UniformElementSize, other.UniformMaxCV
+	}
+	r.seen.merge(other.seen)
+	r.StringIntegers += other.StringIntegers
+	r.UnmatchedCount += other.UnmatchedCount
+	r.BinaryKeyCount += other.BinaryKeyCount
+	r.NonUniformCount += other.NonUniformCount
 
 	// union all sets
 	union(r.StringKeys, other.StringKeys)
@@ -255,9 +785,21 @@ func (r *Results) Merge(other *Results) {
 	union(r.HashValues, other.HashValues)
 	union(r.ListKeys, other.ListKeys)
 	union(r.ListElements, other.ListElements)
+	union(r.UnmatchedKeys, other.UnmatchedKeys)
+	union(r.BinaryKeys, other.BinaryKeys)
+	union(r.NonUniformKeys, other.NonUniformKeys)
+	for vt, n := range other.OtherTypes {
+		r.OtherTypes[vt] += n
+	}
+	for vt, buckets := range other.BucketKeys {
+		for size, keys := range buckets {
+			union(r.bucketKeys(vt, size), keys)
+		}
+	}
 
 	// merge all frequency tables
 	merge(r.StringSizes, other.StringSizes)
+	merge(r.StringBitCounts, other.StringBitCounts)
 	merge(r.SetSizes, other.SetSizes)
 	merge(r.SetElementSizes, other.SetElementSizes)
 	merge(r.SortedSetSizes, other.SortedSetSizes)
@@ -265,47 +807,491 @@ func (r *Results) Merge(other *Results) {
 	merge(r.HashSizes, other.HashSizes)
 	merge(r.HashElementSizes, other.HashElementSizes)
 	merge(r.HashValueSizes, other.HashValueSizes)
+	merge(r.HashByteSizes, other.HashByteSizes)
+	merge(r.SetByteSizes, other.SetByteSizes)
+	merge(r.SortedSetByteSizes, other.SortedSetByteSizes)
+	merge(r.ListByteSizes, other.ListByteSizes)
 	merge(r.ListSizes, other.ListSizes)
 	merge(r.ListElementSizes, other.ListElementSizes)
+	merge(r.ListHeadSizes, other.ListHeadSizes)
+	merge(r.ListTailSizes, other.ListTailSizes)
+	merge(r.AccessFrequencies, other.AccessFrequencies)
+	merge(r.IdleTimes, other.IdleTimes)
+	merge(r.KeyMemorySizes, other.KeyMemorySizes)
+
+	mergeClasses(r.HashFieldValueSizes, other.HashFieldValueSizes)
+	mergeClasses(r.SetElementClassSizes, other.SetElementClassSizes)
+	mergeClasses(r.SortedSetElementClassSizes, other.SortedSetElementClassSizes)
+	mergeClasses(r.ListElementClassSizes, other.ListElementClassSizes)
+
+	for example, source := range other.Sources {
+		if _, ok := r.Sources[example]; !ok {
+			r.Sources[example] = source
+		}
+	}
+}
+
+// MergeFrom merges `other` into the method receiver, like Merge, recording
+// `source` (e.g. the address of the redis instance from which `other` was
+// sampled) as the source of each of the examples of `other` that has no
+// source already.  If an example is merged from more than one source, the
+// first is retained.  See the `Sources` field of Results.
+func (r *Results) MergeFrom(source string, other *Results) {
+	r.Merge(other)
+	for _, set := range other.exampleSets() {
+		for example := range *set {
+			if _, ok := r.Sources[example]; !ok {
+				r.Sources[example] = source
+			}
+		}
+	}
+}
+
+// Clone returns a deep copy of the method receiver.  The frequency maps and
+// example sets of the returned Results share no storage with the original, so
+// the copy can be rendered or merged without mutating the receiver.
+func (r *Results) Clone() *Results {
+	c := NewResults()
+	c.Name = r.Name
+	c.Merge(r)
+	return c
+}
+
+// Scale returns a copy of the method receiver in which every count (of keys,
+// and in each frequency map) is multiplied by `total`/`observed`,
+// extrapolating the results of sampling `observed` keys to a keyspace of
+// `total` keys.  Example sets, TotalKeys and VanishedKeys are unchanged.  The
+// copy's ScaleFactor labels its counts as estimates.  Merging scaled results
+// with unscaled ones is not meaningful.
+func (r *Results) Scale(observed, total int64) *Results {
+	c := r.Clone()
+	if observed <= 0 {
+		return c
+	}
+	f := float64(total) / float64(observed)
+	scale := func(n int64) int64 { return int64(math.Round(float64(n) * f)) }
+
+	c.KeyCount = scale(c.KeyCount)
+	c.StringIntegers = scale(c.StringIntegers)
+	c.UnmatchedCount = scale(c.UnmatchedCount)
+	c.BinaryKeyCount = scale(c.BinaryKeyCount)
+	c.NonUniformCount = scale(c.NonUniformCount)
+	for vt, n := range c.OtherTypes {
+		c.OtherTypes[vt] = scale(n)
+	}
+
+	maps := []map[int]int64{
+		c.StringSizes, c.StringBitCounts,
+		c.SetSizes, c.SetElementSizes,
+		c.SortedSetSizes, c.SortedSetElementSizes,
+		c.HashSizes, c.HashElementSizes, c.HashValueSizes, c.HashByteSizes,
+		c.SetByteSizes, c.SortedSetByteSizes, c.ListByteSizes,
+		c.ListSizes, c.ListElementSizes, c.ListHeadSizes, c.ListTailSizes,
+		c.AccessFrequencies, c.IdleTimes, c.KeyMemorySizes,
+	}
+	for _, classes := range []map[string]map[int]int64{c.SetElementClassSizes, c.SortedSetElementClassSizes, c.HashFieldValueSizes, c.ListElementClassSizes} {
+		for _, m := range classes {
+			maps = append(maps, m)
+		}
+	}
+	for _, m := range maps {
+		for size, n := range m {
+			m[size] = scale(n)
+		}
+	}
+
+	if c.ScaleFactor == 0 {
+		c.ScaleFactor = 1
+	}
+	c.ScaleFactor *= f
+	return c
+}
+
+// A SizeCount is a size from a frequency map, along with the number of times
+// it occurred
+type SizeCount struct {
+	Size  int
+	Count int64
+}
+
+// sizes returns the size frequency map for ValueType `vt`
+func (r *Results) sizes(vt ValueType) map[int]int64 {
+	switch vt {
+	case TypeString:
+		return r.StringSizes
+	case TypeList:
+		return r.ListSizes
+	case TypeSet:
+		return r.SetSizes
+	case TypeSortedSet:
+		return r.SortedSetSizes
+	case TypeHash:
+		return r.HashSizes
+	}
+	return nil
+}
+
+// TopSizes returns up to `n` of the most frequent sizes of values of type
+// `vt` (string lengths, or numbers of members), ordered by descending count.
+// Sizes with equal counts are ordered by ascending size.
+func (r *Results) TopSizes(vt ValueType, n int) []SizeCount {
+	m := r.sizes(vt)
+	top := make([]SizeCount, 0, len(m))
+	for size, count := range m {
+		top = append(top, SizeCount{Size: size, Count: count})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Size < top[j].Size
+	})
+	if n >= 0 && len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
+
+// TypeCounts returns the number of keys of each ValueType that were observed,
+// omitting types of which no keys were observed
+func (r *Results) TypeCounts() map[ValueType]int64 {
+	counts := map[ValueType]int64{}
+	for _, vt := range sampledTypes {
+		var n int64
+		for _, count := range r.sizes(vt) {
+			n += count
+		}
+		if n > 0 {
+			counts[vt] = n
+		}
+	}
+	return counts
+}
+
+// Add returns a new Results combining the method receiver and `other`, in the
+// manner of Merge.  Unlike Merge, neither the receiver nor `other` is
+// modified.  The returned Results takes its Name from the receiver.
+func (r *Results) Add(other *Results) *Results {
+	c := r.Clone()
+	c.Merge(other)
+	return c
+}
+
+// Sum returns a new Results combining all of `results`, in the manner of
+// Merge, without modifying any of them.  The returned Results is unnamed.
+func Sum(results ...*Results) *Results {
+	s := NewResults()
+	for _, r := range results {
+		s.Merge(r)
+	}
+	return s
+}
+
+// approxBits is the number of significant bits retained by approxSize, which
+// bounds the relative error of an approximated size to 2^-approxBits
+const approxBits = 5
+
+// approxSize maps `n` to the midpoint of a log-linear histogram bucket.  Sizes
+// below 2^approxBits are exact; larger sizes retain only their approxBits most
+// significant bits, so there are at most 2^(approxBits-1) buckets per power
+// of two.
+func approxSize(n int) int {
+	shift := bits.Len(uint(n)) - approxBits
+	if shift <= 0 {
+		return n
+	}
+	return (n>>uint(shift))<<uint(shift) + 1<<uint(shift-1)
+}
+
+// bucket returns the frequency map key under which the size `n` is recorded
+func (r *Results) bucket(n int) int {
+	if r.Approximate {
+		return approxSize(n)
+	}
+	return n
+}
+
+func (r *Results) observeSet(key string, length int, members []string) {
+	r.observeSetSizes(key, length, members, lengths(members))
+}
+
+// observeSetSizes is like observeSet, but records `sizes` as the sizes of
+// the members
+func (r *Results) observeSetSizes(key string, length int, members []string, sizes []int) {
+	r.KeyCount++
+	r.SetSizes[r.bucket(length)]++
+	r.retain(r.SetKeys, &r.seen.setKeys, key, MaxExampleKeys)
+	for i, m := range members {
+		r.SetElementSizes[r.bucket(sizes[i])]++
+		r.retain(r.SetElements, &r.seen.setElements, m, MaxExampleElements)
+	}
+}
+
+func (r *Results) observeSortedSet(key string, length int, members []string) {
+	r.observeSortedSetSizes(key, length, members, lengths(members))
 }
 
-func (r *Results) observeSet(key string, length int, member string) {
+// observeSortedSetSizes is like observeSortedSet, but records `sizes` as the sizes of
+// the members
+func (r *Results) observeSortedSetSizes(key string, length int, members []string, sizes []int) {
 	r.KeyCount++
-	r.SetSizes[length]++
-	r.SetElementSizes[len(member)]++
-	add(r.SetKeys, key, MaxExampleKeys)
-	add(r.SetElements, member, MaxExampleElements)
+	r.SortedSetSizes[r.bucket(length)]++
+	r.retain(r.SortedSetKeys, &r.seen.sortedSetKeys, key, MaxExampleKeys)
+	for i, m := range members {
+		r.SortedSetElementSizes[r.bucket(sizes[i])]++
+		r.retain(r.SortedSetElements, &r.seen.sortedSetElements, m, MaxExampleElements)
+	}
+}
+
+// observeHash records a sampled hash, along with one or more of its fields
+// and their corresponding values
+func (r *Results) observeHash(key string, length int, fields, values []string) {
+	r.observeHashSizes(key, length, fields, values, lengths(values))
 }
 
-func (r *Results) observeSortedSet(key string, length int, member string) {
+// observeHashSizes is like observeHash, but records `sizes` as the sizes of
+// the hash values.  A value that was too large to fetch (see MaxFetchBytes)
+// is empty in `values`, and is not recorded as an example.
+func (r *Results) observeHashSizes(key string, length int, fields, values []string, sizes []int) {
 	r.KeyCount++
-	r.SortedSetSizes[length]++
-	r.SortedSetElementSizes[len(member)]++
-	add(r.SortedSetKeys, key, MaxExampleKeys)
-	add(r.SortedSetElements, member, MaxExampleElements)
+	r.HashSizes[r.bucket(length)]++
+	r.retain(r.HashKeys, &r.seen.hashKeys, key, MaxExampleKeys)
+	for i, f := range fields {
+		r.HashElementSizes[r.bucket(len(f))]++
+		r.retain(r.HashElements, &r.seen.hashElements, f, MaxExampleElements)
+		if i < len(sizes) {
+			r.HashValueSizes[r.bucket(sizes[i])]++
+			if len(values[i]) == sizes[i] {
+				r.retain(r.HashValues, &r.seen.hashValues, values[i], MaxExampleValues)
+			}
+		}
+	}
+}
+
+// lengths returns the length of each of `values`
+func lengths(values []string) []int {
+	ls := make([]int, len(values))
+	for i, v := range values {
+		ls[i] = len(v)
+	}
+	return ls
+}
+
+// observeHashFieldClasses records the size of each of the hash `values`
+// under each of the field classes of the corresponding field
+func (r *Results) observeHashFieldClasses(classes [][]string, values []string) {
+	observeClassSizes(r.HashFieldValueSizes, classes, lengths(values), r.bucket)
+}
+
+// observeClasses records the size of each of `values` in the per-class
+// frequency maps `m`, under each of the corresponding `classes`.  Sizes are
+// mapped to frequency map keys with `bucket`.
+func observeClasses(m map[string]map[int]int64, classes [][]string, values []string, bucket func(int) int) {
+	observeClassSizes(m, classes, lengths(values), bucket)
+}
+
+// observeClassSizes is like observeClasses, but records `sizes`, rather than
+// the sizes of values
+func observeClassSizes(m map[string]map[int]int64, classes [][]string, sizes []int, bucket func(int) int) {
+	for i, cs := range classes {
+		if i >= len(sizes) {
+			break
+		}
+		for _, c := range cs {
+			if _, ok := m[c]; !ok {
+				m[c] = make(map[int]int64)
+			}
+			m[c][bucket(sizes[i])]++
+		}
+	}
+}
+
+// mergeClasses merges the per-class frequency maps `b` into `a`
+func mergeClasses(a, b map[string]map[int]int64) {
+	for class, sizes := range b {
+		if _, ok := a[class]; !ok {
+			a[class] = make(map[int]int64)
+		}
+		merge(a[class], sizes)
+	}
+}
+
+func (r *Results) observeList(key string, length int, members []string) {
+	r.observeListSizes(key, length, members, lengths(members))
+}
+
+// observeListSizes is like observeList, but records `sizes` as the sizes of
+// the members
+func (r *Results) observeListSizes(key string, length int, members []string, sizes []int) {
+	r.KeyCount++
+	r.ListSizes[r.bucket(length)]++
+	r.retain(r.ListKeys, &r.seen.listKeys, key, MaxExampleKeys)
+	for i, m := range members {
+		r.ListElementSizes[r.bucket(sizes[i])]++
+		r.retain(r.ListElements, &r.seen.listElements, m, MaxExampleElements)
+	}
+}
+
+// isInteger reports whether redis would store the string value `s` using its
+// integer encoding, i.e. whether `s` is the canonical base-10 representation
+// of a 64-bit signed integer
+func isInteger(s string) bool {
+	if len(s) == 0 || len(s) > 20 {
+		return false
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	return err == nil && strconv.FormatInt(n, 10) == s
 }
 
-func (r *Results) observeHash(key string, length int, field string, value string) {
+func (r *Results) observeString(key, value, example string) {
 	r.KeyCount++
-	r.HashSizes[length]++
-	r.HashValueSizes[len(value)]++
-	r.HashElementSizes[len(field)]++
-	add(r.HashKeys, key, MaxExampleKeys)
-	add(r.HashElements, field, MaxExampleElements)
-	add(r.HashValues, value, MaxExampleValues)
+	r.StringSizes[r.bucket(len(value))]++
+	if isInteger(value) {
+		r.StringIntegers++
+	}
+	r.retain(r.StringKeys, &r.seen.stringKeys, key, MaxExampleKeys)
+	r.retain(r.StringValues, &r.seen.stringValues, example, MaxExampleValues)
+}
+
+// observeUnmatched records a sampled key that matched none of the
+// ExpectedPatterns
+func (r *Results) observeUnmatched(key string) {
+	r.UnmatchedCount++
+	r.retain(r.UnmatchedKeys, &r.seen.unmatchedKeys, key, MaxExampleKeys)
+}
+
+// binaryKey reports whether the name of `key` is not valid UTF-8, or contains
+// non-printable characters
+func binaryKey(key string) bool {
+	if !utf8.ValidString(key) {
+		return true
+	}
+	for _, c := range key {
+		if !unicode.IsPrint(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// EscapeKey returns a printable form of the name of `key`, in which each byte
+// of any invalid UTF-8 sequence or non-printable character is replaced by a
+// `\xNN` hex escape, and backslashes are doubled.  Other characters are
+// unchanged.
+func EscapeKey(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); {
+		c, n := utf8.DecodeRuneInString(key[i:])
+		switch {
+		case c == '\\':
+			b.WriteString(`\\`)
+		case c == utf8.RuneError && n == 1, !unicode.IsPrint(c):
+			for _, x := range []byte(key[i : i+n]) {
+				fmt.Fprintf(&b, `\x%02x`, x)
+			}
+		default:
+			b.WriteString(key[i : i+n])
+		}
+		i += n
+	}
+	return b.String()
+}
+
+// observeNonUniform records a sampled key with an element whose size is not
+// the UniformElementSize
+func (r *Results) observeNonUniform(key string) {
+	r.NonUniformCount++
+	r.retain(r.NonUniformKeys, &r.seen.nonUniformKeys, key, MaxExampleKeys)
+}
+
+// A sizeBucket identifies a power-of-two size bucket of a ValueType
+type sizeBucket struct {
+	vt   ValueType
+	size int
 }
 
-func (r *Results) observeList(key string, length int, member string) {
+// bucketKeys returns the set of BucketKeys for the bucket of type `vt` with
+// power-of-two size `size`, creating it if necessary
+func (r *Results) bucketKeys(vt ValueType, size int) map[string]bool {
+	buckets, ok := r.BucketKeys[vt]
+	if !ok {
+		buckets = make(map[int]map[string]bool)
+		r.BucketKeys[vt] = buckets
+	}
+	keys, ok := buckets[size]
+	if !ok {
+		keys = make(map[string]bool)
+		buckets[size] = keys
+	}
+	return keys
+}
+
+// observeBucketExample records `key`, of type `vt` and size `size`, among at
+// most `max` examples of its power-of-two size bucket
+func (r *Results) observeBucketExample(vt ValueType, size int, key string, max int) {
+	b := sizeBucket{vt: vt, size: powerOfTwo(size)}
+	if r.bucketSeen == nil {
+		r.bucketSeen = make(map[sizeBucket]int64)
+	}
+	seen := r.bucketSeen[b]
+	r.retain(r.bucketKeys(b.vt, b.size), &seen, key, max)
+	r.bucketSeen[b] = seen
+}
+
+// NonUniformTypes returns the coefficient of variation of the element sizes
+// (or hash field sizes) of each type whose coefficient of variation exceeds
+// UniformMaxCV.  Varying sizes where uniform ones were expected, e.g. of
+// fixed-length IDs, can indicate serialization bugs or corrupt data.  It
+// returns nil unless UniformMaxCV is set.
+func (r *Results) NonUniformTypes() map[ValueType]float64 {
+	if r.UniformMaxCV <= 0 {
+		return nil
+	}
+	types := make(map[ValueType]float64)
+	for vt, m := range map[ValueType]map[int]int64{
+		TypeSet:       r.SetElementSizes,
+		TypeSortedSet: r.SortedSetElementSizes,
+		TypeList:      r.ListElementSizes,
+		TypeHash:      r.HashElementSizes,
+	} {
+		if cv := ComputeStatistics(m).CoeffVar(); cv > r.UniformMaxCV {
+			types[vt] = cv
+		}
+	}
+	return types
+}
+
+// observeOtherType records a sampled key of a type that reckon does not sample
+func (r *Results) observeOtherType(vt ValueType) {
 	r.KeyCount++
-	r.ListSizes[length]++
-	r.ListElementSizes[len(member)]++
-	add(r.ListKeys, key, MaxExampleKeys)
-	add(r.ListElements, member, MaxExampleElements)
+	r.OtherTypes[string(vt)]++
 }
 
-func (r *Results) observeString(key, value string) {
+// observeBinaryKey records a sampled key with a binary name, which has
+// already been escaped with EscapeKey
+func (r *Results) observeBinaryKey(key string) {
+	r.BinaryKeyCount++
+	r.retain(r.BinaryKeys, &r.seen.binaryKeys, key, MaxExampleKeys)
+}
+
+// observeSize records only the size of a value, as gathered in SizeOnly mode
+func (r *Results) observeSize(key string, vt ValueType, size int) {
 	r.KeyCount++
-	r.StringSizes[len(value)]++
-	add(r.StringKeys, key, MaxExampleKeys)
-	add(r.StringValues, value, MaxExampleValues)
+	switch vt {
+	case TypeString:
+		r.StringSizes[r.bucket(size)]++
+		r.retain(r.StringKeys, &r.seen.stringKeys, key, MaxExampleKeys)
+	case TypeList:
+		r.ListSizes[r.bucket(size)]++
+		r.retain(r.ListKeys, &r.seen.listKeys, key, MaxExampleKeys)
+	case TypeSet:
+		r.SetSizes[r.bucket(size)]++
+		r.retain(r.SetKeys, &r.seen.setKeys, key, MaxExampleKeys)
+	case TypeSortedSet:
+		r.SortedSetSizes[r.bucket(size)]++
+		r.retain(r.SortedSetKeys, &r.seen.sortedSetKeys, key, MaxExampleKeys)
+	case TypeHash:
+		r.HashSizes[r.bucket(size)]++
+		r.retain(r.HashKeys, &r.seen.hashKeys, key, MaxExampleKeys)
+	}
 }
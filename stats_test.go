@@ -17,7 +17,11 @@
 package reckon
 
 import (
+	"bytes"
 	"math"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -71,6 +75,7 @@ func TestStatistics(t *testing.T) {
 	assertInt(t, 45, stats.Min)
 	assertFloat(t, 13415.93333, stats.Mean, epsilon)
 	assertFloat(t, 35152.65287, stats.StdDev, epsilon)
+	assertFloat(t, 35152.65287/13415.93333, stats.CoeffVar(), epsilon)
 }
 
 func TestStatisticsZeroValues(t *testing.T) {
@@ -82,4 +87,391 @@ func TestStatisticsZeroValues(t *testing.T) {
 	assertInt(t, 0, stats.Min)
 	assertNaN(t, stats.Mean)
 	assertNaN(t, stats.StdDev)
+	assertNaN(t, stats.CoeffVar())
+}
+
+func TestClone(t *testing.T) {
+
+	original := func() *Results {
+		r := NewResults()
+		r.Name = "original"
+		r.observeString("foo", "bar", "bar")
+		r.observeSet("myset", 3, []string{"baz"})
+		r.observeHash("myhash", 2, []string{"field"}, []string{"value"})
+		return r
+	}
+	r := original()
+
+	c := r.Clone()
+	assertResults(t, r, c)
+	if c.Name != r.Name {
+		t.Errorf("expected: %s, actual: %s", r.Name, c.Name)
+	}
+	assertInt(t, int(r.KeyCount), int(c.KeyCount))
+	assertInt(t, 1, int(c.StringSizes[3]))
+	assertInt(t, 1, int(c.SetSizes[3]))
+	assertInt(t, 1, int(c.HashValueSizes[5]))
+
+	c.observeString("foo2", "quux", "quux")
+	c.observeSet("myset2", 3, []string{"abc"})
+	c.Merge(r)
+	c.HashKeys["another"] = true
+
+	assertInt(t, 3, int(r.KeyCount))
+	assertInt(t, 1, int(r.StringSizes[3]))
+	assertInt(t, 0, int(r.StringSizes[4]))
+	assertInt(t, 1, int(r.SetSizes[3]))
+	assertInt(t, 1, len(r.StringKeys))
+	assertInt(t, 1, len(r.SetElements))
+	assertInt(t, 1, len(r.HashKeys))
+	assertResults(t, original(), r)
+
+	assertInt(t, 8, int(c.KeyCount))
+	assertInt(t, 3, int(c.SetSizes[3]))
+	assertInt(t, 2, len(c.HashKeys))
+}
+
+func TestIsInteger(t *testing.T) {
+
+	for _, s := range []string{"0", "7", "-42", "9223372036854775807", "-9223372036854775808"} {
+		if !isInteger(s) {
+			t.Errorf("expected %q to be an integer", s)
+		}
+	}
+
+	for _, s := range []string{"", "007", "+1", " 1", "1.5", "-0", "9223372036854775808", "abc"} {
+		if isInteger(s) {
+			t.Errorf("expected %q not to be an integer", s)
+		}
+	}
+
+	r := NewResults()
+	r.observeString("a", "123", "123")
+	r.observeString("b", "12a", "12a")
+	assertInt(t, 1, int(r.StringIntegers))
+}
+
+func TestMeanConfidenceInterval(t *testing.T) {
+
+	m := make(map[int]int64)
+	m[-1] = 1
+	m[13] = 1
+	m[67] = 1
+	m[999] = 1
+	m[342] = 1
+
+	stats := ComputeStatistics(m)
+	assertInt(t, 5, int(stats.Count))
+	assertFloat(t, 370.93167, stats.MeanCI, 0.001)
+
+	// sampling half of a population of 10 narrows the interval
+	assertFloat(t, 276.47614, stats.WithPopulation(10).MeanCI, 0.001)
+
+	// a population no larger than the sample leaves the interval untouched
+	assertFloat(t, 370.93167, stats.WithPopulation(5).MeanCI, 0.001)
+
+	assertNaN(t, ComputeStatistics(make(map[int]int64)).MeanCI)
+}
+
+func TestIdleBuckets(t *testing.T) {
+
+	m := make(map[int]int64)
+	m[0] = 1
+	m[59] = 2
+	m[60] = 3
+	m[86399] = 4
+	m[86400] = 5
+
+	buckets := ComputeIdleBuckets(m)
+	assertInt(t, 4, len(buckets))
+	assertInt(t, 3, int(buckets[0].Count))
+	assertInt(t, 3, int(buckets[1].Count))
+	assertInt(t, 4, int(buckets[2].Count))
+	assertInt(t, 5, int(buckets[3].Count))
+}
+
+func TestApproxSize(t *testing.T) {
+
+	for n := 0; n < 1<<approxBits; n++ {
+		assertInt(t, n, approxSize(n))
+	}
+
+	buckets := make(map[int]bool)
+	for n := 1; n < 1<<20; n++ {
+		a := approxSize(n)
+		if err := math.Abs(float64(a-n)) / float64(n); err > 1.0/(1<<approxBits) {
+			t.Fatalf("approxSize(%d) = %d, relative error: %f", n, a, err)
+		}
+		buckets[a] = true
+	}
+	if len(buckets) > 1<<approxBits+20*(1<<(approxBits-1)) {
+		t.Errorf("expected a bounded number of buckets, actual: %d", len(buckets))
+	}
+
+	r := NewResults()
+	r.Approximate = true
+	for n := 1024; n < 2048; n++ {
+		r.observeString("key", strings.Repeat("x", n), "")
+	}
+	assertInt(t, 1<<(approxBits-1), len(r.StringSizes))
+	assertFloat(t, 1535.5, ComputeStatistics(r.StringSizes).Mean, 1)
+	if !r.Clone().Approximate {
+		t.Error("expected Clone to preserve Approximate")
+	}
+}
+
+func TestAddReservoirSample(t *testing.T) {
+
+	// offer 1000 distinct elements to a 10-element set, many times over;
+	// each element should be retained in about 1% of the trials, regardless
+	// of the order in which it was offered
+	const n, size, trials = 1000, 10, 5000
+	retained := make([]int, 10)
+	for i := 0; i < trials; i++ {
+		set := make(map[string]bool)
+		var seen int64
+		for e := 0; e < n; e++ {
+			add(set, &seen, strconv.Itoa(e), size)
+		}
+		assertInt(t, size, len(set))
+		for k := range set {
+			e, _ := strconv.Atoi(k)
+			retained[e*len(retained)/n]++
+		}
+	}
+
+	// each decile of the offered elements is expected to have 5000 retained
+	// elements, with a standard deviation of about 70
+	expected := float64(trials * size / len(retained))
+	for d, count := range retained {
+		if math.Abs(float64(count)-expected) > 0.1*expected {
+			t.Errorf("decile %d: expected about %.0f retained elements, actual: %d", d, expected, count)
+		}
+	}
+
+	// re-offering a retained element does not change the set
+	set := map[string]bool{"a": true}
+	var seen int64 = 1
+	add(set, &seen, "a", 1)
+	assertInt(t, 1, int(seen))
+}
+
+func TestRunningStats(t *testing.T) {
+
+	assertFloat(t, z95, zScore(0.95), 1e-6)
+
+	var r runningStats
+	if !math.IsNaN(r.meanCI(z95)) {
+		t.Error("expected a NaN confidence interval without observations")
+	}
+
+	m := map[int]int64{1: 3, 4: 2, 10: 1}
+	for size, n := range m {
+		for i := int64(0); i < n; i++ {
+			r.add(float64(size))
+		}
+	}
+	s := ComputeStatistics(m)
+	assertFloat(t, s.Mean, r.mean, 1e-9)
+	assertFloat(t, s.MeanCI, r.meanCI(z95), 1e-5)
+}
+
+func TestSizePairs(t *testing.T) {
+
+	var p sizePairs
+	assertNaN(t, p.correlation())
+	p.add(1, 10)
+	assertNaN(t, p.correlation())
+
+	// bytes proportional to cardinality
+	p.add(2, 20)
+	p.add(4, 40)
+	assertFloat(t, 1, p.correlation(), 1e-9)
+
+	// bytes that shrink as cardinality grows
+	var q sizePairs
+	for i := 1; i <= 5; i++ {
+		q.add(i, 100-i*3)
+	}
+	assertFloat(t, -1, q.correlation(), 1e-9)
+
+	// bytes that do not vary
+	var r sizePairs
+	r.add(1, 5)
+	r.add(3, 5)
+	assertNaN(t, r.correlation())
+
+	// only maxSizePairs pairs are retained
+	for i := 0; i < maxSizePairs+10; i++ {
+		r.add(i, i)
+	}
+	assertInt(t, maxSizePairs, len(r.pairs))
+	assertInt(t, maxSizePairs+12, int(r.seen))
+}
+
+func TestComputeConcentration(t *testing.T) {
+
+	c := ComputeConcentration(map[int]int64{})
+	assertNaN(t, c.Top1)
+	assertNaN(t, c.Gini)
+
+	c = ComputeConcentration(map[int]int64{8: 4})
+	assertFloat(t, 0.25, c.Top10, 1e-9)
+	assertFloat(t, 0, c.Gini, 1e-9)
+
+	// one key of 10 bytes holds all of the memory among 4 keys
+	c = ComputeConcentration(map[int]int64{0: 3, 10: 1})
+	assertFloat(t, 1, c.Top1, 1e-9)
+	assertFloat(t, 0.75, c.Gini, 1e-9)
+
+	// 100 keys: 90 of 1 byte, 10 of 91 bytes
+	c = ComputeConcentration(map[int]int64{1: 90, 91: 10})
+	assertFloat(t, 0.091, c.Top1, 1e-9)
+	assertFloat(t, 0.455, c.Top5, 1e-9)
+	assertFloat(t, 0.91, c.Top10, 1e-9)
+	assertFloat(t, 0.81, c.Gini, 1e-9)
+}
+
+func TestAddAndSum(t *testing.T) {
+
+	a := NewResults()
+	a.Name = "a"
+	a.observeString("foo", "bar", "bar")
+	b := NewResults()
+	b.observeString("foo2", "quux", "quux")
+	b.observeList("mylist", 2, []string{"x", "yy"})
+	aBefore, bBefore := a.Clone(), b.Clone()
+
+	c := a.Add(b)
+	if c.Name != "a" {
+		t.Errorf("expected: a, actual: %s", c.Name)
+	}
+	assertInt(t, 3, int(c.KeyCount))
+	assertInt(t, 1, int(c.StringSizes[3]))
+	assertInt(t, 1, int(c.StringSizes[4]))
+	assertInt(t, 2, len(c.StringKeys))
+
+	s := Sum(a, b, c)
+	assertInt(t, 6, int(s.KeyCount))
+	assertInt(t, 2, int(s.ListSizes[2]))
+	assertInt(t, 0, int(Sum().KeyCount))
+
+	if !reflect.DeepEqual(a, aBefore) || !reflect.DeepEqual(b, bBefore) {
+		t.Error("expected Add and Sum not to modify their operands")
+	}
+}
+
+func TestMergeFrom(t *testing.T) {
+
+	a := NewResults()
+	a.observeString("foo", "bar", "bar")
+	b := NewResults()
+	b.observeString("foo", "baz", "baz")
+	b.observeList("mylist", 2, []string{"x", "yy"})
+
+	r := NewResults()
+	r.MergeFrom("redis-a:6379", a)
+	r.MergeFrom("redis-b:6379", b)
+	assertInt(t, 3, int(r.KeyCount))
+
+	expected := map[string]string{
+		"foo":    "redis-a:6379",
+		"bar":    "redis-a:6379",
+		"baz":    "redis-b:6379",
+		"mylist": "redis-b:6379",
+		"yy":     "redis-b:6379",
+	}
+	for example, source := range expected {
+		if r.Sources[example] != source {
+			t.Errorf("expected the source of %q to be %s, actual: %q", example, source, r.Sources[example])
+		}
+	}
+
+	// sources survive further merges
+	all := NewResults()
+	all.MergeFrom("cluster", r)
+	if all.Sources["baz"] != "redis-b:6379" || all.Clone().Sources["foo"] != "redis-a:6379" {
+		t.Errorf("expected sources to be preserved, actual: %v", all.Sources)
+	}
+
+	var buf bytes.Buffer
+	if err := RenderText(r, &buf); err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, buf.String(), " mylist (from redis-b:6379)")
+}
+
+func TestScale(t *testing.T) {
+
+	r := NewResults()
+	r.observeString("foo", "bar", "bar")
+	r.observeString("foo2", "quux", "quux")
+	r.observeHash("h", 2, []string{"f"}, []string{"v"})
+	r.SetElementClassSizes["numeric"] = map[int]int64{3: 2}
+	before := r.Clone()
+
+	scaled := r.Scale(3, 300)
+	assertInt(t, 300, int(scaled.KeyCount))
+	assertInt(t, 100, int(scaled.StringSizes[3]))
+	assertInt(t, 100, int(scaled.HashValueSizes[1]))
+	assertInt(t, 200, int(scaled.SetElementClassSizes["numeric"][3]))
+	assertFloat(t, 100, scaled.ScaleFactor, 1e-9)
+	assertInt(t, 2, len(scaled.StringKeys))
+	assertInt(t, 700, int(scaled.Summary().Types[TypeString].TotalSize))
+
+	if !reflect.DeepEqual(r, before) {
+		t.Error("expected Scale not to modify the receiver")
+	}
+	assertFloat(t, 200, scaled.Scale(1, 2).ScaleFactor, 1e-9)
+
+	var buf bytes.Buffer
+	if err := RenderText(scaled, &buf); err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, buf.String(), "# of keys (estimated): 300")
+}
+
+func TestTopSizes(t *testing.T) {
+
+	r := NewResults()
+	for _, v := range []string{"a", "bb", "bb", "ccc", "ccc", "ddd", "dddd"} {
+		r.observeString(v, v, v)
+	}
+
+	top := r.TopSizes(TypeString, 2)
+	expected := []SizeCount{{Size: 3, Count: 3}, {Size: 2, Count: 2}}
+	if !reflect.DeepEqual(expected, top) {
+		t.Errorf("expected: %v, actual: %v", expected, top)
+	}
+
+	// ties are ordered by size
+	top = r.TopSizes(TypeString, 10)
+	assertInt(t, 4, len(top))
+	assertInt(t, 1, top[2].Size)
+	assertInt(t, 4, top[3].Size)
+
+	assertInt(t, 0, len(r.TopSizes(TypeHash, 3)))
+}
+
+func TestEscapeKey(t *testing.T) {
+
+	cases := map[string]string{
+		"user:42":     "user:42",
+		"caf\u00e9":   "caf\u00e9",
+		"a\x00b":      `a\x00b`,
+		"bad\xff\xfe": `bad\xff\xfe`,
+		"tab\there\\": `tab\x09here\\`,
+		"bell\u0085":  `bell\xc2\x85`,
+	}
+	for key, expected := range cases {
+		if actual := EscapeKey(key); actual != expected {
+			t.Errorf("expected EscapeKey(%q) to be %q, actual: %q", key, expected, actual)
+		}
+	}
+
+	for key, binary := range map[string]bool{"user:42": false, "caf\u00e9": false, "a\x00b": true, "bad\xff": true, "line\n": true} {
+		if binaryKey(key) != binary {
+			t.Errorf("expected binaryKey(%q) to be %v", key, binary)
+		}
+	}
 }
@@ -0,0 +1,53 @@
+/*
+ * Copyright (C) 2015 zulily, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reckon
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// Observation is a single sampled key, assigned to a single aggregation
+// group.  RunNDJSON emits one Observation per line of output.
+type Observation struct {
+	Group string    `json:"group"`
+	Key   string    `json:"key"`
+	Type  ValueType `json:"type"`
+
+	// Size is the length of a string value, or the number of members of any
+	// other type of value
+	Size int       `json:"size"`
+	Time time.Time `json:"ts"`
+}
+
+// RunNDJSON performs the sampling operation configured by `fns` (see
+// NewOptions), writing each observation to `w` as newline-delimited JSON as
+// sampling proceeds.  No Results are accumulated in memory, so the memory
+// used by RunNDJSON remains flat regardless of the number of keys sampled;
+// aggregation is left to the consumer of the output.
+func RunNDJSON(w io.Writer, aggregator Aggregator, fns ...func(*Options) error) error {
+	opts, err := NewOptions(fns...)
+	if err != nil {
+		return err
+	}
+
+	s := newSampler(opts, aggregator)
+	s.stream = json.NewEncoder(w)
+	_, err = s.run()
+	return err
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright (C) 2015 zulily, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reckon
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// streamServer is a respDialer serving a keyspace of a string, a value of a
+// module type, and a key that vanishes, which RANDOMKEY returns in turn
+func streamServer() respDialer {
+	info := "# Keyspace\r\ndb0:keys=3,expires=0,avg_ttl=0\r\n"
+	keys := []string{"gone", "str", "doc"}
+	types := map[string]string{"gone": "none", "str": "string", "doc": "ReJSON-RL"}
+	var next int
+	return respDialer{handler: func(args []string) string {
+		switch args[0] {
+		case "INFO":
+			return fmt.Sprintf("$%d\r\n%s\r\n", len(info), info)
+		case "RANDOMKEY":
+			key := keys[next%len(keys)]
+			next++
+			return fmt.Sprintf("$%d\r\n%s\r\n", len(key), key)
+		case "TYPE":
+			return "+" + types[args[1]] + "\r\n"
+		case "GET":
+			return "$5\r\nvalue\r\n"
+		}
+		return "-ERR unexpected command " + args[0] + "\r\n"
+	}}
+}
+
+// failingWriter is an io.Writer whose writes always fail
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+func TestRunNDJSON(t *testing.T) {
+
+	twoGroups := AggregatorFunc(func(key string, vt ValueType) []string {
+		if vt == TypeString {
+			return []string{"strings", "all"}
+		}
+		return []string{"all"}
+	})
+	var buf bytes.Buffer
+	err := RunNDJSON(&buf, twoGroups, WithProxy(streamServer()), WithAllowMaster(), WithMinSamples(2),
+		WithOtherTypes(), WithLogger(&bufLogger{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var actual []string
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var o Observation
+		if err := dec.Decode(&o); err != nil {
+			t.Fatal(err)
+		}
+		if o.Time.IsZero() {
+			t.Error("expected each observation to be timestamped")
+		}
+		actual = append(actual, fmt.Sprintf("%s %s %s %d", o.Group, o.Key, o.Type, o.Size))
+	}
+	// the vanished key is not emitted, and the key of a type that is not
+	// sampled is emitted without a size
+	expected := "strings str string 5,all str string 5,all doc ReJSON-RL 0"
+	if strings.Join(actual, ",") != expected {
+		t.Errorf("expected: %s, actual: %s", expected, strings.Join(actual, ","))
+	}
+
+	// without OtherTypes, a type that is not sampled fails the run
+	err = RunNDJSON(&bytes.Buffer{}, twoGroups, WithProxy(streamServer()), WithAllowMaster(), WithMinSamples(2), WithLogger(&bufLogger{}))
+	if err == nil || !strings.Contains(err.Error(), "unknown type") {
+		t.Errorf("expected an unknown type error, actual: %v", err)
+	}
+
+	// a failure to write an observation aborts the run
+	err = RunNDJSON(failingWriter{}, twoGroups, WithProxy(streamServer()), WithAllowMaster(), WithMinSamples(2), WithLogger(&bufLogger{}))
+	if err == nil || err.Error() != "broken pipe" {
+		t.Errorf("expected the write error, actual: %v", err)
+	}
+}
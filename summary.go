@@ -0,0 +1,371 @@
+/*
+ * Copyright (C) 2015 zulily, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reckon
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"sort"
+	"time"
+)
+
+// RunSummary describes what a sampling operation did, as opposed to the
+// statistics it gathered.  Run populates a RunSummary when one is supplied via
+// the `Summary` field of Options.
+type RunSummary struct {
+	// Address is the host:port address of the sampled redis instance
+	Address string
+
+	// TotalKeys is the number of keys present in the redis instance
+	TotalKeys int64
+
+	// Sampled is the number of keys sampled, including those that were
+	// subsequently skipped
+	Sampled int64
+
+	// TypeCounts is the number of keys observed for each ValueType
+	TypeCounts map[ValueType]int64
+
+	// Skipped is the number of sampled keys that were not observed, e.g.
+	// because the budget for their type had been exhausted
+	Skipped int64
+
+	// OverBudget is the number of sampled keys of each ValueType that were
+	// skipped because the type's PerTypeBudget had been reached.  These are
+	// included in Skipped.
+	OverBudget map[ValueType]int64
+
+	// Vanished is the number of sampled keys that were not observed because
+	// they expired or were deleted before they could be
+	Vanished int64
+
+	// MemoryBudgetReached is set if examples were discarded because the
+	// MemoryBudget had been reached
+	MemoryBudgetReached bool
+
+	// Commands is the number of commands issued to the redis instance, and
+	// CommandsPerKey is the number issued per observed key, including those
+	// issued to select the keys to observe (e.g. `RANDOMKEY` and `TYPE`)
+	Commands       int64
+	CommandsPerKey float64
+
+	// GroupAssignments is the number of groups to which observed keys were
+	// assigned by the Aggregator, and GroupsPerKey is the mean number per
+	// observed key.  A GroupsPerKey above 1 means that the Aggregator fans
+	// keys out to several groups, so that the key counts of the groups sum to
+	// more than the number of keys observed.
+	GroupAssignments int64
+	GroupsPerKey     float64
+
+	// Funnel accounts for how the keys examined were reduced to those
+	// observed
+	Funnel Funnel
+
+	// SizeCorrelation is the Pearson correlation coefficient between the
+	// number of elements and the estimated total bytes of the elements of the
+	// collections of each type that were sampled.  A coefficient near 1 means
+	// that memory use is driven by cardinality, and a coefficient near 0
+	// that it is driven by the sizes of the elements.  Types with fewer than
+	// two such collections, or whose sizes did not vary, are omitted.
+	SizeCorrelation map[ValueType]float64
+
+	// NextCursor is the `SCAN` cursor from which the next slice of the
+	// keyspace begins, when sampling with a ScanLimit, and ScanComplete is
+	// set once the scan has reached the end of the keyspace.  See the
+	// `ScanLimit` field of Options.
+	NextCursor   uint64
+	ScanComplete bool
+
+	// Duration is the time taken by the sampling operation
+	Duration time.Duration
+
+	// KeyGrowthRate is the rate, in keys per second, at which the number of
+	// keys in the redis instance changed over GrowthWindow.  It is negative
+	// if the keyspace shrank.  See the `GrowthProbe` field of Options.
+	KeyGrowthRate float64
+
+	// GrowthWindow is the time between the readings of the key count from
+	// which KeyGrowthRate was computed, or zero if it was not measured
+	GrowthWindow time.Duration
+
+	// Warnings describes any conditions encountered during sampling that may
+	// affect the validity of the results
+	Warnings []string
+}
+
+// A Funnel accounts for each of the keys examined by a sampling operation, by
+// the stage at which it was discarded, if any, before being observed into the
+// Results of at least one aggregation group.  It explains why fewer keys were
+// observed than the number of samples requested.
+type Funnel struct {
+	// Examined is the number of keys obtained, e.g. from `RANDOMKEY`, `KEYS`
+	// or a KeySource.  Each key is counted each time it is obtained.
+	Examined int64
+
+	// Filtered is the number of keys examined that belong to another shard
+	// (see ShardCount) or hash slot (see HashSlots)
+	Filtered int64
+
+	// Vanished is the number of keys that expired or were deleted before they
+	// could be observed
+	Vanished int64
+
+	// Skipped is the number of keys of a type whose PerTypeBudget had been
+	// reached, or that had been idle for longer than MaxIdleTime
+	Skipped int64
+
+	// Ungrouped is the number of keys for which the Aggregator returned no
+	// groups
+	Ungrouped int64
+
+	// Observed is the number of keys observed into at least one group
+	Observed int64
+}
+
+// String summarizes the funnel on a single line
+func (f Funnel) String() string {
+	return fmt.Sprintf("examined %d → filtered %d → vanished %d → skipped %d → ungrouped %d → observed %d",
+		f.Examined, f.Filtered, f.Vanished, f.Skipped, f.Ungrouped, f.Observed)
+}
+
+// RunSummaryVersion is the version of the file format written by
+// WriteRunSummary.  It is incremented whenever a change to the format could
+// break existing consumers; fields may be added without a version change.
+const RunSummaryVersion = 1
+
+// runSummaryFile is the stable, serialized form of a RunSummary
+type runSummaryFile struct {
+	Version         int                `json:"version"`
+	Address         string             `json:"address"`
+	TotalKeys       int64              `json:"total_keys"`
+	Sampled         int64              `json:"keys_sampled"`
+	Skipped         int64              `json:"keys_skipped"`
+	Vanished        int64              `json:"keys_vanished"`
+	TypeCounts      map[string]int64   `json:"type_counts"`
+	OverBudget      map[string]int64   `json:"over_budget"`
+	BudgetReached   bool               `json:"memory_budget_reached"`
+	Funnel          funnelFile         `json:"funnel"`
+	Commands        int64              `json:"commands"`
+	SizeCorrelation map[string]float64 `json:"size_correlation"`
+	CommandsPerKey  float64            `json:"commands_per_key"`
+	Assignments     int64              `json:"group_assignments"`
+	GroupsPerKey    float64            `json:"groups_per_key"`
+	NextCursor      uint64             `json:"next_cursor"`
+	ScanComplete    bool               `json:"scan_complete"`
+	DurationSeconds float64            `json:"duration_seconds"`
+	KeyGrowthRate   float64            `json:"key_growth_per_second"`
+	GrowthWindow    float64            `json:"growth_window_seconds"`
+	Warnings        []string           `json:"warnings"`
+}
+
+// funnelFile is the serialized form of a Funnel
+type funnelFile struct {
+	Examined  int64 `json:"examined"`
+	Filtered  int64 `json:"filtered"`
+	Vanished  int64 `json:"vanished"`
+	Skipped   int64 `json:"skipped"`
+	Ungrouped int64 `json:"ungrouped"`
+	Observed  int64 `json:"observed"`
+}
+
+// WriteRunSummary writes `summary` to the file at `path` as a compact JSON
+// document, suitable for making assertions about a sampling operation in
+// scheduled jobs (e.g. failing if too many keys were skipped).  Unlike
+// RenderJSON, no statistics are included.  The document carries a "version"
+// field; see RunSummaryVersion.
+func WriteRunSummary(summary RunSummary, path string) error {
+	f := runSummaryFile{
+		Version:         RunSummaryVersion,
+		Address:         summary.Address,
+		TotalKeys:       summary.TotalKeys,
+		Sampled:         summary.Sampled,
+		Skipped:         summary.Skipped,
+		Vanished:        summary.Vanished,
+		TypeCounts:      make(map[string]int64),
+		OverBudget:      make(map[string]int64),
+		BudgetReached:   summary.MemoryBudgetReached,
+		Funnel:          funnelFile(summary.Funnel),
+		Commands:        summary.Commands,
+		CommandsPerKey:  summary.CommandsPerKey,
+		Assignments:     summary.GroupAssignments,
+		GroupsPerKey:    summary.GroupsPerKey,
+		SizeCorrelation: make(map[string]float64),
+		NextCursor:      summary.NextCursor,
+		ScanComplete:    summary.ScanComplete,
+		DurationSeconds: summary.Duration.Seconds(),
+		KeyGrowthRate:   summary.KeyGrowthRate,
+		GrowthWindow:    summary.GrowthWindow.Seconds(),
+		Warnings:        summary.Warnings,
+	}
+	for vt, n := range summary.TypeCounts {
+		f.TypeCounts[string(vt)] = n
+	}
+	for vt, n := range summary.OverBudget {
+		f.OverBudget[string(vt)] = n
+	}
+	for vt, c := range summary.SizeCorrelation {
+		f.SizeCorrelation[string(vt)] = c
+	}
+	if f.Warnings == nil {
+		f.Warnings = []string{}
+	}
+
+	b, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(b, '\n'), 0644)
+}
+
+// A TypeSummary is a compact rollup of the values of one ValueType in a
+// Results instance.  Sizes are lengths in bytes for strings, and numbers of
+// members for other types.
+type TypeSummary struct {
+	// Keys is the number of keys of the type that were observed
+	Keys int64
+
+	// TotalSize is the sum of the sizes of the observed values
+	TotalSize int64
+
+	// MeanSize, P50Size and P99Size are the mean, median and 99th percentile
+	// sizes of the observed values
+	MeanSize float64
+	P50Size  int
+	P99Size  int
+
+	// Examples is the number of example keys of the type that were captured
+	Examples int
+}
+
+// A ResultsSummary holds headline numbers for each ValueType in a Results
+// instance, without its frequency maps, e.g. for rendering in a dashboard.
+// See Results.Summary.
+type ResultsSummary struct {
+	Name     string
+	KeyCount int64
+
+	// Types holds a TypeSummary for each ValueType of which keys were
+	// observed
+	Types map[ValueType]TypeSummary
+}
+
+// Summary computes a ResultsSummary from the frequency maps of the method
+// receiver
+func (r *Results) Summary() ResultsSummary {
+	summary := ResultsSummary{Name: r.Name, KeyCount: r.KeyCount, Types: make(map[ValueType]TypeSummary)}
+	examples := map[ValueType]int{
+		TypeString:    len(r.StringKeys),
+		TypeList:      len(r.ListKeys),
+		TypeSet:       len(r.SetKeys),
+		TypeSortedSet: len(r.SortedSetKeys),
+		TypeHash:      len(r.HashKeys),
+	}
+
+	for _, vt := range sampledTypes {
+		m := r.sizes(vt)
+		stats := ComputeStatistics(m)
+		if stats.Count == 0 {
+			continue
+		}
+		var total int64
+		for size, count := range m {
+			total += int64(size) * count
+		}
+		summary.Types[vt] = TypeSummary{
+			Keys:      stats.Count,
+			TotalSize: total,
+			MeanSize:  stats.Mean,
+			P50Size:   percentile(m, 0.50),
+			P99Size:   percentile(m, 0.99),
+			Examples:  examples[vt],
+		}
+	}
+	return summary
+}
+
+// percentile returns the smallest size in frequency map `m` such that at
+// least fraction `p` of the observations are no larger (the nearest-rank
+// percentile), or zero if `m` is empty
+func percentile(m map[int]int64, p float64) int {
+	sizes := make([]int, 0, len(m))
+	var n int64
+	for size, count := range m {
+		sizes = append(sizes, size)
+		n += count
+	}
+	sort.Ints(sizes)
+
+	var cumulative int64
+	for _, size := range sizes {
+		cumulative += m[size]
+		if float64(cumulative) >= p*float64(n) {
+			return size
+		}
+	}
+	return 0
+}
+
+// elementSizes returns the frequency maps of the sizes of the elements of
+// values of ValueType `vt`: the fields and values of hashes, or the members of
+// the other collection types
+func (r *Results) elementSizes(vt ValueType) []map[int]int64 {
+	switch vt {
+	case TypeList:
+		return []map[int]int64{r.ListElementSizes}
+	case TypeSet:
+		return []map[int]int64{r.SetElementSizes}
+	case TypeSortedSet:
+		return []map[int]int64{r.SortedSetElementSizes}
+	case TypeHash:
+		return []map[int]int64{r.HashElementSizes, r.HashValueSizes}
+	}
+	return nil
+}
+
+// EstimatedBytes estimates the total number of bytes held in the values of
+// the observed keys, from their sizes alone, without querying redis.  For
+// each ValueType, the mean bytes per key (the mean string length, or the mean
+// number of elements multiplied by the mean element size, counting both the
+// fields and values of hashes) is multiplied by the number of keys of the
+// type.  This is a rough approximation: it omits key names, redis' per-key
+// and per-element overhead, and the savings of compact encodings, and the
+// collections whose element sizes were not sampled (e.g. with SizeOnly)
+// contribute nothing.  For Results returned by Scale, it estimates the bytes
+// of the full keyspace.
+func (r *Results) EstimatedBytes() int64 {
+	var total float64
+	for _, vt := range sampledTypes {
+		sizes := ComputeStatistics(r.sizes(vt))
+		if sizes.Count == 0 {
+			continue
+		}
+		perKey := sizes.Mean
+		if vt != TypeString {
+			var element float64
+			for _, m := range r.elementSizes(vt) {
+				if stats := ComputeStatistics(m); stats.Count > 0 {
+					element += stats.Mean
+				}
+			}
+			perKey *= element
+		}
+		total += perKey * float64(sizes.Count)
+	}
+	return int64(math.Round(total))
+}
@@ -0,0 +1,144 @@
+/*
+ * Copyright (C) 2015 zulily, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reckon
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriteRunSummary(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "reckon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "summary.json")
+	summary := RunSummary{
+		Address:    "localhost:6379",
+		TotalKeys:  1000,
+		Sampled:    100,
+		Skipped:    5,
+		Vanished:   2,
+		TypeCounts: map[ValueType]int64{TypeString: 60, TypeHash: 35},
+		Duration:   1500 * time.Millisecond,
+
+		KeyGrowthRate: -2.5,
+		GrowthWindow:  time.Minute,
+
+		MemoryBudgetReached: true,
+		Funnel:              Funnel{Examined: 100, Skipped: 5, Vanished: 2, Observed: 93},
+		SizeCorrelation:     map[ValueType]float64{TypeSet: 0.75},
+		GroupAssignments:    186,
+		GroupsPerKey:        2,
+	}
+	if err := WriteRunSummary(summary, path); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	assertFloat(t, float64(RunSummaryVersion), doc["version"].(float64), 1e-9)
+	assertFloat(t, 100, doc["keys_sampled"].(float64), 1e-9)
+	assertFloat(t, 5, doc["keys_skipped"].(float64), 1e-9)
+	assertFloat(t, 2, doc["keys_vanished"].(float64), 1e-9)
+	assertFloat(t, 1.5, doc["duration_seconds"].(float64), 1e-9)
+	assertFloat(t, -2.5, doc["key_growth_per_second"].(float64), 1e-9)
+	assertFloat(t, 60, doc["growth_window_seconds"].(float64), 1e-9)
+	assertFloat(t, 93, doc["funnel"].(map[string]interface{})["observed"].(float64), 1e-9)
+	assertFloat(t, 2, doc["groups_per_key"].(float64), 1e-9)
+	assertFloat(t, 0.75, doc["size_correlation"].(map[string]interface{})["set"].(float64), 1e-9)
+	if doc["memory_budget_reached"] != true {
+		t.Errorf("expected memory_budget_reached, actual: %v", doc["memory_budget_reached"])
+	}
+	assertFloat(t, 35, doc["type_counts"].(map[string]interface{})["hash"].(float64), 1e-9)
+	if w, ok := doc["warnings"].([]interface{}); !ok || len(w) != 0 {
+		t.Errorf("expected an empty warnings list, actual: %v", doc["warnings"])
+	}
+}
+
+func TestResultsSummary(t *testing.T) {
+
+	r := NewResults()
+	r.Name = "users"
+	r.KeyCount = 103
+	r.StringSizes = map[int]int64{10: 50, 20: 49, 1000: 1}
+	r.StringKeys = map[string]bool{"user:1": true, "user:2": true}
+	r.HashSizes = map[int]int64{4: 3}
+	r.HashKeys = map[string]bool{"session:1": true}
+
+	summary := r.Summary()
+	if summary.Name != "users" || summary.KeyCount != 103 {
+		t.Errorf("unexpected summary: %+v", summary)
+	}
+	assertInt(t, 2, len(summary.Types))
+
+	strs := summary.Types[TypeString]
+	assertInt(t, 100, int(strs.Keys))
+	assertInt(t, 500+980+1000, int(strs.TotalSize))
+	assertFloat(t, 24.8, strs.MeanSize, 1e-9)
+	assertInt(t, 10, strs.P50Size)
+	assertInt(t, 20, strs.P99Size)
+	assertInt(t, 2, strs.Examples)
+
+	hashes := summary.Types[TypeHash]
+	assertInt(t, 3, int(hashes.Keys))
+	assertInt(t, 12, int(hashes.TotalSize))
+	assertInt(t, 4, hashes.P50Size)
+	assertInt(t, 4, hashes.P99Size)
+	assertInt(t, 1, hashes.Examples)
+
+	assertInt(t, 0, percentile(map[int]int64{}, 0.5))
+	assertInt(t, 1000, percentile(r.StringSizes, 1))
+}
+
+func TestEstimatedBytes(t *testing.T) {
+
+	r := NewResults()
+	assertInt(t, 0, int(r.EstimatedBytes()))
+
+	r.observeString("a", "12345", "12345")
+	r.observeString("b", "123", "123")
+	// 2 strings of mean length 4
+	assertInt(t, 8, int(r.EstimatedBytes()))
+
+	r.observeHash("h", 10, []string{"field"}, []string{"va"})
+	r.observeList("l", 3, []string{"elem"})
+	// plus 10 × (5 + 2) for the hash, and 3 × 4 for the list
+	assertInt(t, 90, int(r.EstimatedBytes()))
+	assertInt(t, 180, int(r.Scale(1, 2).EstimatedBytes()))
+
+	var buf bytes.Buffer
+	if err := RenderText(r, &buf); err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, buf.String(), "Estimated bytes in values: 90")
+}
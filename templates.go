@@ -17,8 +17,19 @@
 package reckon
 
 import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"text/template"
 )
 
@@ -27,41 +38,204 @@ func summarize(m map[int]int64) int64 {
 	return trimAndSum(m, 0.01)
 }
 
+// fmtFloat formats `n` to two decimal places, or as "n/a" if it is undefined,
+// e.g. the mean of an empty distribution
 func fmtFloat(n float64) string {
+	if !defined(n) {
+		return "n/a"
+	}
 	return fmt.Sprintf("%.2f", n)
 }
 
+// share formats the fraction `f` as a percentage, to two decimal places
+func share(f float64) string {
+	return fmtFloat(100 * f)
+}
+
+// defined reports whether `n` is neither NaN nor infinite
+func defined(n float64) bool {
+	return !math.IsNaN(n) && !math.IsInf(n, 0)
+}
+
 func percentage(n, total int64) string {
 	return fmt.Sprintf("%.2f", 100.0*float64(n)/float64(total))
 }
 
+var (
+	chartJSOnce sync.Once
+	chartJSData string
+)
+
 // chartJS returns the static js what we need on the HTML templates in order to
 // render charts.  The js itself has been turned into Go src using go-bindata.
-// This func panics if there is any error accessing the embedded asset data.
+// The asset is decompressed once and reused, so rendering reports for many
+// groups does not repeatedly inflate it.  This func panics if there is any
+// error accessing the embedded asset data.
 func chartJS() string {
-	data, err := Asset("Chart.min.js")
-	if err != nil {
-		panic(err)
-	}
-	return string(data)
+	chartJSOnce.Do(func() {
+		data, err := Asset("Chart.min.js")
+		if err != nil {
+			panic(err)
+		}
+		chartJSData = string(data)
+	})
+	return chartJSData
+}
+
+// keyStats computes the statistics for a frequency map with one observation
+// per sampled key, correcting the confidence interval on the mean for the
+// `population` of keys from which they were sampled
+func keyStats(m map[int]int64, population int64) Statistics {
+	return ComputeStatistics(m).WithPopulation(population)
 }
 
+// DefaultChartBuckets is the default number of bars in the charts of an HTML
+// report.  See the `ChartBuckets` field of RenderOptions.
+const DefaultChartBuckets = 30
+
+// otherBucket labels the bar of a chart that collects the sizes that did not
+// earn a bar of their own
+const otherBucket = "other"
+
+// chartBar is a single bar of a chart: a size, or otherBucket, and its
+// frequency
+type chartBar struct {
+	Label string
+	Count int64
+}
+
+// chartData is the data supplied to the "barchart" HTML template: the
+// frequency map to chart, and the maximum number of bars to chart it with
 type chartData struct {
 	DOMElement string
 	Data       map[int]int64
+	Buckets    int
 }
 
-func barChart(domElement string, freq map[int]int64) chartData {
+func barChart(domElement string, freq map[int]int64, buckets int) chartData {
 	return chartData{
 		DOMElement: domElement,
 		Data:       freq,
+		Buckets:    buckets,
 	}
 }
 
-// RenderHTML renders an HTML report for a Results instance to the supplied
-// io.Writer
-func RenderHTML(s *Results, out io.Writer) error {
+// Bars returns a bar for each size in the chart's Data, in ascending order
+// of size.  If there are more than Buckets sizes, only the Buckets most
+// frequent have bars, followed by an otherBucket bar that sums the
+// frequencies of the rest.
+func (c chartData) Bars() []chartBar {
+	sizes := make([]int, 0, len(c.Data))
+	for size := range c.Data {
+		sizes = append(sizes, size)
+	}
+	var other int64
+	if c.Buckets > 0 && len(sizes) > c.Buckets {
+		sort.Slice(sizes, func(i, j int) bool {
+			if c.Data[sizes[i]] != c.Data[sizes[j]] {
+				return c.Data[sizes[i]] > c.Data[sizes[j]]
+			}
+			return sizes[i] < sizes[j]
+		})
+		for _, size := range sizes[c.Buckets:] {
+			other += c.Data[size]
+		}
+		sizes = sizes[:c.Buckets]
+	}
+	sort.Ints(sizes)
 
+	bars := make([]chartBar, 0, len(sizes)+1)
+	for _, size := range sizes {
+		bars = append(bars, chartBar{Label: strconv.Itoa(size), Count: c.Data[size]})
+	}
+	if other > 0 {
+		bars = append(bars, chartBar{Label: otherBucket, Count: other})
+	}
+	return bars
+}
+
+// A powerBucket is a row of a combined frequency table: a power-of-two size,
+// its frequency, and the raw sizes (and their frequencies) that it contains
+type powerBucket struct {
+	Size  int
+	Count int64
+	Sizes []SizeCount
+}
+
+// combinedTable is the data supplied to the "combinedfreq" HTML template
+type combinedTable struct {
+	Total   int64
+	Buckets []powerBucket
+}
+
+// combinedFreq groups the sizes in frequency map `m` by the power-of-two
+// buckets computed by ComputePowerOfTwoFreq, both in ascending order of size
+func combinedFreq(m map[int]int64) combinedTable {
+	table := combinedTable{Total: summarize(m)}
+	buckets := make(map[int]*powerBucket)
+	for size, count := range m {
+		p := powerOfTwo(size)
+		b, ok := buckets[p]
+		if !ok {
+			b = &powerBucket{Size: p}
+			buckets[p] = b
+		}
+		b.Count += count
+		b.Sizes = append(b.Sizes, SizeCount{Size: size, Count: count})
+	}
+
+	for _, b := range buckets {
+		sort.Slice(b.Sizes, func(i, j int) bool { return b.Sizes[i].Size < b.Sizes[j].Size })
+		table.Buckets = append(table.Buckets, *b)
+	}
+	sort.Slice(table.Buckets, func(i, j int) bool { return table.Buckets[i].Size < table.Buckets[j].Size })
+	return table
+}
+
+// sparkLevels are the characters used by sparkline, in increasing height
+var sparkLevels = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline summarizes a frequency map of sizes on a single line: each
+// character represents the frequency of one power-of-two size bucket, from
+// the smallest bucket present to the largest.  Empty buckets are blank.
+func sparkline(m map[int]int64) string {
+	pf := ComputePowerOfTwoFreq(m)
+	if len(pf) == 0 {
+		return ""
+	}
+
+	lo, hi := -1, 0
+	var peak int64
+	for p, n := range pf {
+		if lo < 0 || p < lo {
+			lo = p
+		}
+		if p > hi {
+			hi = p
+		}
+		if n > peak {
+			peak = n
+		}
+	}
+
+	var line []rune
+	for p := lo; ; p *= 2 {
+		if n := pf[p]; n > 0 {
+			level := int(float64(n) / float64(peak) * float64(len(sparkLevels)-1))
+			line = append(line, sparkLevels[level])
+		} else {
+			line = append(line, ' ')
+		}
+		if p >= hi {
+			break
+		}
+	}
+	return fmt.Sprintf("%d %s %d", lo, string(line), hi)
+}
+
+// trimExamples reduces each of the example sets in `s` to its maximum size,
+// since merging multiple Results may have grown them beyond those limits
+func trimExamples(s *Results) {
 	s.StringKeys = trim(s.StringKeys, MaxExampleKeys)
 	s.StringValues = trim(s.StringValues, MaxExampleValues)
 	s.SetKeys = trim(s.SetKeys, MaxExampleKeys)
@@ -73,43 +247,523 @@ func RenderHTML(s *Results, out io.Writer) error {
 	s.HashValues = trim(s.HashValues, MaxExampleValues)
 	s.ListKeys = trim(s.ListKeys, MaxExampleKeys)
 	s.ListElements = trim(s.ListElements, MaxExampleElements)
+	s.UnmatchedKeys = trim(s.UnmatchedKeys, MaxExampleKeys)
+	s.BinaryKeys = trim(s.BinaryKeys, MaxExampleKeys)
+	s.NonUniformKeys = trim(s.NonUniformKeys, MaxExampleKeys)
+	for _, buckets := range s.BucketKeys {
+		for size, keys := range buckets {
+			buckets[size] = trim(keys, MaxExampleKeys)
+		}
+	}
+}
+
+// bucketExamples are the BucketKeys of one power-of-two size bucket, in
+// sorted order
+type bucketExamples struct {
+	Size int
+	Keys []string
+}
+
+// sortedBucketKeys returns the BucketKeys of `s` of type `vt`, in ascending
+// order of size
+func sortedBucketKeys(s *Results, vt ValueType) []bucketExamples {
+	buckets := make([]bucketExamples, 0, len(s.BucketKeys[vt]))
+	for size, keys := range s.BucketKeys[vt] {
+		b := bucketExamples{Size: size}
+		for k := range keys {
+			b.Keys = append(b.Keys, k)
+		}
+		sort.Strings(b.Keys)
+		buckets = append(buckets, b)
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Size < buckets[j].Size })
+	return buckets
+}
+
+// A View is one of the ways in which a report can present a frequency
+// distribution
+type View int
+
+const (
+	// ViewRaw presents a table of each distinct size and its frequency
+	ViewRaw View = iota
+	// ViewPowerOfTwo presents a table of sizes rounded up to powers of two
+	ViewPowerOfTwo
+	// ViewChart presents a bar chart of the distribution (HTML reports only)
+	ViewChart
+)
+
+// RenderOptions configures the reports produced by the Render* funcs
+type RenderOptions struct {
+	// Raw, PowerOfTwo and Chart select which views of each frequency
+	// distribution are included in a report.  See View.
+	Raw        bool
+	PowerOfTwo bool
+	Chart      bool
+
+	// Tabs lays out the sections of an HTML report for each value type as
+	// tabs, rather than stacking them vertically.  It has no effect on
+	// other report formats.
+	Tabs bool
+
+	// Combined merges the Raw view of each distribution in an HTML report
+	// into its PowerOfTwo view: each power-of-two row of the table can be
+	// expanded to show the raw sizes it contains.  It has no effect unless
+	// both views are included, nor on other report formats.
+	Combined bool
+
+	// InspectHost and InspectPort, if set, render each example key in an HTML
+	// report alongside the `redis-cli` command that inspects it on that redis
+	// instance (e.g. `GET` for a string, `HGETALL` for a hash), with a button
+	// that copies the command to the clipboard.  Keys with binary names, which
+	// are reported in an escaped form, have no command.  See inspectCommand.
+	InspectHost string
+	InspectPort int
+
+	// ChartBuckets is the maximum number of bars in each chart of an HTML
+	// report.  The sizes of a distribution with more distinct sizes than this
+	// are charted by the ChartBuckets most frequent, and an "other" bar that
+	// collects the rest.  It defaults to DefaultChartBuckets.
+	ChartBuckets int
+
+	// Funcs are added to the funcs available to the report templates,
+	// replacing any built-in func of the same name.  See WithFuncs.
+	Funcs template.FuncMap
+}
+
+// WithViews limits the views of each frequency distribution included in a
+// report to those given.  By default, every view is included.
+func WithViews(views ...View) func(*RenderOptions) error {
+	return func(opts *RenderOptions) error {
+		opts.Raw, opts.PowerOfTwo, opts.Chart = false, false, false
+		for _, v := range views {
+			switch v {
+			case ViewRaw:
+				opts.Raw = true
+			case ViewPowerOfTwo:
+				opts.PowerOfTwo = true
+			case ViewChart:
+				opts.Chart = true
+			default:
+				return fmt.Errorf("unknown view: %d", v)
+			}
+		}
+		return nil
+	}
+}
+
+// WithTabs lays out the type sections of HTML reports as tabs.  See the
+// `Tabs` field of RenderOptions.
+func WithTabs() func(*RenderOptions) error {
+	return func(opts *RenderOptions) error {
+		opts.Tabs = true
+		return nil
+	}
+}
+
+// WithCombinedTables merges the raw and power-of-two tables of HTML reports.
+// See the `Combined` field of RenderOptions.
+func WithCombinedTables() func(*RenderOptions) error {
+	return func(opts *RenderOptions) error {
+		opts.Combined = true
+		return nil
+	}
+}
+
+// WithChartBuckets limits the charts of HTML reports to at most `n` bars.
+// See the `ChartBuckets` field of RenderOptions.
+func WithChartBuckets(n int) func(*RenderOptions) error {
+	return func(opts *RenderOptions) error {
+		if n < 1 {
+			return errors.New("ChartBuckets must be at least 1")
+		}
+		opts.ChartBuckets = n
+		return nil
+	}
+}
+
+// WithFuncs adds the funcs in `fm` to those available to the report
+// templates, e.g. to replace a built-in formatter, or to render a custom
+// template (see FuncMap) with helpers of one's own alongside reckon's.  The
+// funcs of successive calls are merged.
+func WithFuncs(fm template.FuncMap) func(*RenderOptions) error {
+	return func(opts *RenderOptions) error {
+		if opts.Funcs == nil {
+			opts.Funcs = make(template.FuncMap)
+		}
+		for name, fn := range fm {
+			opts.Funcs[name] = fn
+		}
+		return nil
+	}
+}
+
+// WithInspectCommands renders example keys in HTML reports with the
+// `redis-cli` commands that inspect them on the redis instance at `host` and
+// `port`.  See the `InspectHost` field of RenderOptions.
+func WithInspectCommands(host string, port int) func(*RenderOptions) error {
+	return func(opts *RenderOptions) error {
+		if host == "" {
+			return errors.New("InspectHost cannot be empty")
+		}
+		if port <= 0 || port > 65535 {
+			return errors.New("InspectPort must be between 1 and 65535")
+		}
+		opts.InspectHost, opts.InspectPort = host, port
+		return nil
+	}
+}
+
+// inspectFormats are the redis commands with which inspectCommand inspects a
+// key of each type, with the key substituted for the verb
+var inspectFormats = map[ValueType]string{
+	TypeString:    "GET %s",
+	TypeList:      "LRANGE %s 0 -1",
+	TypeSet:       "SMEMBERS %s",
+	TypeSortedSet: "ZRANGE %s 0 -1 WITHSCORES",
+	TypeHash:      "HGETALL %s",
+}
+
+// shellQuote quotes `s` as a single argument to a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// inspectCommand returns the `redis-cli` command that inspects `key`, of type
+// `vt`, on the redis instance at `host` and `port`.  Keys of other types are
+// inspected with `TYPE`.
+func inspectCommand(host string, port int, vt ValueType, key string) string {
+	format, ok := inspectFormats[vt]
+	if !ok {
+		format = "TYPE %s"
+	}
+	return fmt.Sprintf("redis-cli -h %s -p %d "+format, shellQuote(host), port, shellQuote(key))
+}
+
+// keyExamples is the data supplied to the "keyexamples" HTML template: a set
+// of example keys, all of type Type
+type keyExamples struct {
+	Type ValueType
+	Keys map[string]bool
+}
+
+// newRenderOptions applies each of the supplied funcs, in order, to the
+// default RenderOptions
+func newRenderOptions(fns []func(*RenderOptions) error) (RenderOptions, error) {
+	opts := RenderOptions{Raw: true, PowerOfTwo: true, Chart: true, ChartBuckets: DefaultChartBuckets}
+	for _, fn := range fns {
+		if err := fn(&opts); err != nil {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
+// reportData is the data supplied to the report templates
+type reportData struct {
+	*Results
+	View RenderOptions
+}
+
+// firstTab returns the id of the first tab of a tabbed HTML report for `s`,
+// which is the only tab shown until another is selected
+func firstTab(s *Results) string {
+	switch {
+	case len(s.StringKeys) > 0:
+		return "strings"
+	case len(s.SetKeys) > 0:
+		return "sets"
+	case len(s.SortedSetKeys) > 0:
+		return "sortedsets"
+	case len(s.ListKeys) > 0:
+		return "lists"
+	case len(s.HashKeys) > 0:
+		return "hashes"
+	case len(s.AccessFrequencies) > 0:
+		return "accessfrequencies"
+	case len(s.IdleTimes) > 0:
+		return "idletimes"
+	case len(s.KeyMemorySizes) > 0:
+		return "keymemory"
+	}
+	return ""
+}
 
-	fm := template.FuncMap{
+// htmlFuncs returns the funcs available to the HTML report template for `s`
+func htmlFuncs(s *Results, opts RenderOptions) template.FuncMap {
+	return template.FuncMap{
 		"summarize":  summarize,
 		"percentage": percentage,
 		"power":      ComputePowerOfTwoFreq,
+		"combine":    combinedFreq,
 		"stats":      ComputeStatistics,
+		"keyStats":   keyStats,
 		"fmtFloat":   fmtFloat,
-		"barChart":   barChart,
+		"share":      share,
+		"defined":    defined,
+		"barChart": func(domElement string, freq map[int]int64) chartData {
+			return barChart(domElement, freq, opts.ChartBuckets)
+		},
 		"chartJS":    chartJS,
+		"firstTab":   func() string { return firstTab(s) },
+		"idle":       ComputeIdleBuckets,
+		"source":     func(example string) string { return s.Sources[example] },
+		"typed":      func(vt ValueType, keys map[string]bool) keyExamples { return keyExamples{Type: vt, Keys: keys} },
+		"bucketKeys": func(vt ValueType) []bucketExamples { return sortedBucketKeys(s, vt) },
+		"inspect": func(vt ValueType, key string) string {
+			// BinaryKeys retains only some of the escaped keys, so any key
+			// that may have been escaped is offered no command, since it
+			// would name a different key
+			if opts.InspectHost == "" || EscapeKey(key) != key {
+				return ""
+			}
+			return inspectCommand(opts.InspectHost, opts.InspectPort, vt, key)
+		},
 	}
-	t := template.Must(template.New("htmloutput").Funcs(fm).Parse(htmlTmpl))
-	return t.ExecuteTemplate(out, "base", s)
 }
 
-// RenderText renders a plaintext report for a Results instance to the supplied
-// io.Writer
-func RenderText(s *Results, out io.Writer) error {
-
-	s.StringKeys = trim(s.StringKeys, MaxExampleKeys)
-	s.StringValues = trim(s.StringValues, MaxExampleValues)
-	s.SetKeys = trim(s.SetKeys, MaxExampleKeys)
-	s.SetElements = trim(s.SetElements, MaxExampleElements)
-	s.SortedSetKeys = trim(s.SortedSetKeys, MaxExampleKeys)
-	s.SortedSetElements = trim(s.SortedSetElements, MaxExampleElements)
-	s.HashKeys = trim(s.HashKeys, MaxExampleKeys)
-	s.HashElements = trim(s.HashElements, MaxExampleElements)
-	s.HashValues = trim(s.HashValues, MaxExampleValues)
-	s.ListKeys = trim(s.ListKeys, MaxExampleKeys)
-	s.ListElements = trim(s.ListElements, MaxExampleElements)
-
-	fm := template.FuncMap{
+// textFuncs returns the funcs available to the plaintext report template for
+// `s`
+func textFuncs(s *Results, opts RenderOptions) template.FuncMap {
+	return template.FuncMap{
 		"summarize":  summarize,
 		"percentage": percentage,
 		"power":      ComputePowerOfTwoFreq,
 		"stats":      ComputeStatistics,
+		"keyStats":   keyStats,
 		"fmtFloat":   fmtFloat,
+		"share":      share,
+		"defined":    defined,
+		"idle":       ComputeIdleBuckets,
+		"sparkline":  sparkline,
+		"bucketKeys": func(vt ValueType) []bucketExamples { return sortedBucketKeys(s, vt) },
+		"source":     func(example string) string { return s.Sources[example] },
+	}
+}
+
+// withFuncs returns `base` with each of `extra` added, replacing any func in
+// `base` of the same name
+func withFuncs(base, extra template.FuncMap) template.FuncMap {
+	for name, fn := range extra {
+		base[name] = fn
+	}
+	return base
+}
+
+// FuncMap returns the funcs available to the report templates of the given
+// `format`, "html" or "text", for `s`, so that a custom template can be
+// rendered with the same helpers as the built-in reports.  The funcs are
+// those of the text/template package, like the templates themselves; the
+// "html" func escapes text for HTML.  Any funcs supplied via WithFuncs are
+// included.
+func FuncMap(s *Results, format string, fns ...func(*RenderOptions) error) (template.FuncMap, error) {
+	opts, err := newRenderOptions(fns)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case "html":
+		return withFuncs(htmlFuncs(s, opts), opts.Funcs), nil
+	case "text":
+		return withFuncs(textFuncs(s, opts), opts.Funcs), nil
+	}
+	return nil, fmt.Errorf("unknown report format: %s", format)
+}
+
+// RenderHTML renders an HTML report for a Results instance to the supplied
+// io.Writer.  The report is streamed to `out` as it is rendered, rather than
+// being buffered in memory in its entirety; the largest single write is the
+// inlined Chart.js source.
+func RenderHTML(s *Results, out io.Writer, fns ...func(*RenderOptions) error) error {
+
+	opts, err := newRenderOptions(fns)
+	if err != nil {
+		return err
+	}
+	trimExamples(s)
+	opts.Combined = opts.Combined && opts.Raw && opts.PowerOfTwo
+
+	fm := withFuncs(htmlFuncs(s, opts), opts.Funcs)
+	t := template.Must(template.New("htmloutput").Funcs(fm).Parse(htmlTmpl))
+	return t.ExecuteTemplate(out, "base", reportData{Results: s, View: opts})
+}
+
+// RenderText renders a plaintext report for a Results instance to the supplied
+// io.Writer
+func RenderText(s *Results, out io.Writer, fns ...func(*RenderOptions) error) error {
+
+	opts, err := newRenderOptions(fns)
+	if err != nil {
+		return err
 	}
+	trimExamples(s)
+
+	fm := withFuncs(textFuncs(s, opts), opts.Funcs)
 	t := template.Must(template.New("output").Funcs(fm).Parse(statsTempl))
-	return t.ExecuteTemplate(out, "base", s)
+	return t.ExecuteTemplate(out, "base", reportData{Results: s, View: opts})
+}
+
+// RenderJSON renders a Results instance as JSON to the supplied io.Writer.
+// RenderOptions are accepted for symmetry with the other renderers, but have
+// no effect on the JSON output.
+func RenderJSON(s *Results, out io.Writer, fns ...func(*RenderOptions) error) error {
+
+	if _, err := newRenderOptions(fns); err != nil {
+		return err
+	}
+	trimExamples(s)
+	return json.NewEncoder(out).Encode(s)
+}
+
+// reportFileName encodes `group` as a file name that is valid on every
+// common filesystem: it is escaped as a URL path segment, along with any
+// colon or leading dot, so that distinct groups are given distinct names.
+func reportFileName(group string) string {
+	name := strings.Replace(url.PathEscape(group), ":", "%3A", -1)
+	if strings.HasPrefix(name, ".") {
+		name = "%2E" + name[1:]
+	}
+	return name
+}
+
+// reportFileNames returns the groups of `results` in sorted order, along
+// with the file name of each (see reportFileName).  An error is returned if
+// two groups' file names differ only in case, since they would refer to the
+// same file on a case-insensitive filesystem.
+func reportFileNames(results map[string]*Results) ([]string, map[string]string, error) {
+	groups := make([]string, 0, len(results))
+	for group := range results {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+
+	names := make(map[string]string, len(groups))
+	owners := make(map[string]string, len(groups))
+	for _, group := range groups {
+		name := reportFileName(group)
+		if owner, ok := owners[strings.ToLower(name)]; ok {
+			return nil, nil, fmt.Errorf("groups %q and %q have the same report file name: %s", owner, group, name)
+		}
+		owners[strings.ToLower(name)] = group
+		names[group] = name
+	}
+	return groups, names, nil
+}
+
+// RenderAll renders both an HTML and a plaintext report for each of the
+// aggregation groups in `results`, writing them to `<group>.html` and
+// `<group>.txt` respectively in the directory `dir`, which is created if it
+// does not exist.  Group names are escaped as URL path segments (e.g. `a/b`
+// is written to `a%2Fb.html`), along with any colon or leading dot; an error
+// is returned, before any report is written, if two groups' file names differ
+// only in case.  The Name of each Results instance is set to its group.
+func RenderAll(results map[string]*Results, dir string, fns ...func(*RenderOptions) error) error {
+
+	groups, names, err := reportFileNames(results)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	for _, group := range groups {
+		r := results[group]
+		r.Name = group
+		base := filepath.Join(dir, names[group])
+		// rendering trims the frequency maps, so each format renders a copy
+		if err := renderFile(base+".html", r.Clone(), RenderHTML, fns); err != nil {
+			return err
+		}
+		if err := renderFile(base+".txt", r.Clone(), RenderText, fns); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// zipIndexTmpl renders the index.html entry of the archive written by
+// RenderZip
+const zipIndexTmpl = `<!DOCTYPE html>
+<html>
+	<head><meta charset="utf-8"><title>reckon</title></head>
+	<body>
+		<h1>reckon <small>{{len .}} groups</small></h1>
+		<ul>
+		{{range .}}<li><a href="{{html (path .Path)}}">{{html .Group}}</a> ({{.KeyCount}} keys)</li>
+		{{end}}</ul>
+	</body>
+</html>
+`
+
+// zipEntry describes one group's report in the archive written by RenderZip
+type zipEntry struct {
+	Group    string
+	Path     string
+	KeyCount int64
+}
+
+// RenderZip renders a report for each of the aggregation groups in `results`
+// as an entry of a zip archive written to `out`, along with an `index.html`
+// entry that links to each of them.  The `format` of the reports is one of
+// "html", "text" or "json".  Entries are named as the files of RenderAll
+// are, and the Name of each Results instance is set to its group.
+func RenderZip(results map[string]*Results, out io.Writer, format string, fns ...func(*RenderOptions) error) error {
+	var render func(*Results, io.Writer, ...func(*RenderOptions) error) error
+	var ext string
+	switch format {
+	case "html":
+		render, ext = RenderHTML, ".html"
+	case "text":
+		render, ext = RenderText, ".txt"
+	case "json":
+		render, ext = RenderJSON, ".json"
+	default:
+		return fmt.Errorf("unknown report format: %q", format)
+	}
+
+	groups, names, err := reportFileNames(results)
+	if err != nil {
+		return err
+	}
+
+	z := zip.NewWriter(out)
+	entries := make([]zipEntry, 0, len(groups))
+	for _, group := range groups {
+		r := results[group]
+		r.Name = group
+		entry := zipEntry{Group: group, Path: names[group] + ext, KeyCount: r.KeyCount}
+		w, err := z.Create(entry.Path)
+		if err != nil {
+			return err
+		}
+		// rendering trims the frequency maps, so a copy is rendered
+		if err = render(r.Clone(), w, fns...); err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+	}
+
+	w, err := z.Create("index.html")
+	if err != nil {
+		return err
+	}
+	t := template.Must(template.New("index").Funcs(template.FuncMap{"html": template.HTMLEscapeString, "path": url.PathEscape}).Parse(zipIndexTmpl))
+	if err = t.Execute(w, entries); err != nil {
+		return err
+	}
+	return z.Close()
+}
+
+// renderFile creates the file at `path` and renders `r` to it with `render`
+func renderFile(path string, r *Results, render func(*Results, io.Writer, ...func(*RenderOptions) error) error, fns []func(*RenderOptions) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = render(r, f, fns...); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
 }
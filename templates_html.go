@@ -45,120 +45,383 @@ const (
     <div class="container">
       <div class="jumbotron">
         <h1>{{.Name}} <small>{{.KeyCount}} keys</small></h1>
+        <p>An estimated {{.EstimatedBytes}} bytes are held in values, from their mean sizes, excluding key names and redis overhead.</p>
+        {{if .VanishedKeys}}<p>{{.VanishedKeys}} sampled keys vanished before they could be observed.</p>{{end}}
+        {{if .ScaleFactor}}<p>All counts are estimates for the full keyspace, scaled by {{fmtFloat .ScaleFactor}} from the sampled counts.</p>{{end}}
+        {{if .Approximate}}<p>Sizes are approximate, to within about 3%.</p>{{end}}
       </div>
 
-			{{ if .StringKeys }}
-			  <h1>Strings <small>{{summarize .StringSizes}}</small> </h1>
+			{{ if .UnmatchedKeys }}
+			  <h1>Unmatched Keys <small>{{.UnmatchedCount}} ({{percentage .UnmatchedCount .KeyCount}}%)</small> </h1>
+				<div class="panel panel-warning">
+					<div class="panel-body">
+						<p>These sampled keys matched none of the expected patterns.</p>
+						<h3>Example keys:</h3> {{template "keyexamples" (typed "unknown" .UnmatchedKeys)}}
+					</div>
+				</div>
+			{{ end }}
+
+			{{ if .BinaryKeys }}
+			  <h1>Binary Key Names <small>{{.BinaryKeyCount}} ({{percentage .BinaryKeyCount .KeyCount}}%)</small> </h1>
+				<div class="panel panel-warning">
+					<div class="panel-body">
+						<p>The names of these sampled keys are not valid UTF-8, or contain non-printable characters, which are shown hex-escaped.</p>
+						<h3>Example keys:</h3> {{template "examples" .BinaryKeys}}
+					</div>
+				</div>
+			{{ end }}
+
+			{{ if .NonUniformKeys }}
+			  <h1>Non-Uniform Elements <small>{{.NonUniformCount}} ({{percentage .NonUniformCount .KeyCount}}%)</small> </h1>
+				<div class="panel panel-warning">
+					<div class="panel-body">
+						<p>These sampled keys have elements that are not the expected {{.UniformElementSize}} bytes long, which may indicate a serialization bug or corrupt data.</p>
+						{{range $t, $cv := .NonUniformTypes}}<p>The element sizes of type <code>{{$t}}</code> vary, with a coefficient of variation of {{fmtFloat $cv}}.</p>{{end}}
+						<h3>Example keys:</h3> {{template "examples" .NonUniformKeys}}
+					</div>
+				</div>
+			{{ end }}
+
+			{{ if .OtherTypes }}
+			  <h1>Other Types</h1>
 				<div class="panel panel-default">
 					<div class="panel-body">
-						<h3>Example keys:</h3> {{template "examples" .StringKeys}}
-						<h3>Value Sizes: {{template "stats" .StringSizes}}</h3>
-						{{template "freq" .StringSizes}}
-						{{template "barchart" barChart "StringSizes" .StringSizes}}
-						<h3>2<sup><var>n</var></sup> Value Sizes:</h3>
-						{{template "freq" power .StringSizes}}
+						<p>The values of these sampled keys are of types that reckon does not examine.</p>
+						<table class="table table-striped">
+							<thead><tr><th>Type</th><th># of keys</th><th>%</th></tr></thead>
+							<tbody>
+							{{range $t, $n := .OtherTypes}}<tr><td><code>{{$t}}</code></td> <td>{{$n}}</td> <td>{{percentage $n $.KeyCount}}%</td></tr>{{end}}
+							</tbody>
+						</table>
 					</div>
 				</div>
 			{{ end }}
 
-			{{ if .SetKeys }}
+			{{if $.View.Tabs}}
+			{{ $first := firstTab }}
+			<ul class="nav nav-tabs" role="tablist" id="typeTabs">
+				{{if .StringKeys}}<li role="presentation"{{if eq $first "strings"}} class="active"{{end}}><a href="#strings" aria-controls="strings" role="tab" data-toggle="tab">Strings</a></li>{{end}}
+				{{if .SetKeys}}<li role="presentation"{{if eq $first "sets"}} class="active"{{end}}><a href="#sets" aria-controls="sets" role="tab" data-toggle="tab">Sets</a></li>{{end}}
+				{{if .SortedSetKeys}}<li role="presentation"{{if eq $first "sortedsets"}} class="active"{{end}}><a href="#sortedsets" aria-controls="sortedsets" role="tab" data-toggle="tab">Sorted Sets</a></li>{{end}}
+				{{if .ListKeys}}<li role="presentation"{{if eq $first "lists"}} class="active"{{end}}><a href="#lists" aria-controls="lists" role="tab" data-toggle="tab">Lists</a></li>{{end}}
+				{{if .HashKeys}}<li role="presentation"{{if eq $first "hashes"}} class="active"{{end}}><a href="#hashes" aria-controls="hashes" role="tab" data-toggle="tab">Hashes</a></li>{{end}}
+				{{if .AccessFrequencies}}<li role="presentation"{{if eq $first "accessfrequencies"}} class="active"{{end}}><a href="#accessfrequencies" aria-controls="accessfrequencies" role="tab" data-toggle="tab">Access Frequencies</a></li>{{end}}
+				{{if .IdleTimes}}<li role="presentation"{{if eq $first "idletimes"}} class="active"{{end}}><a href="#idletimes" aria-controls="idletimes" role="tab" data-toggle="tab">Idle Times</a></li>{{end}}
+				{{if .KeyMemorySizes}}<li role="presentation"{{if eq $first "keymemory"}} class="active"{{end}}><a href="#keymemory" aria-controls="keymemory" role="tab" data-toggle="tab">Key Memory</a></li>{{end}}
+			</ul>
+			<div class="tab-content">
+				{{if .StringKeys}}<div role="tabpanel" class="tab-pane{{if eq $first "strings"}} active{{end}}" id="strings">{{template "strings" $}}</div>{{end}}
+				{{if .SetKeys}}<div role="tabpanel" class="tab-pane{{if eq $first "sets"}} active{{end}}" id="sets">{{template "sets" $}}</div>{{end}}
+				{{if .SortedSetKeys}}<div role="tabpanel" class="tab-pane{{if eq $first "sortedsets"}} active{{end}}" id="sortedsets">{{template "sortedsets" $}}</div>{{end}}
+				{{if .ListKeys}}<div role="tabpanel" class="tab-pane{{if eq $first "lists"}} active{{end}}" id="lists">{{template "lists" $}}</div>{{end}}
+				{{if .HashKeys}}<div role="tabpanel" class="tab-pane{{if eq $first "hashes"}} active{{end}}" id="hashes">{{template "hashes" $}}</div>{{end}}
+				{{if .AccessFrequencies}}<div role="tabpanel" class="tab-pane{{if eq $first "accessfrequencies"}} active{{end}}" id="accessfrequencies">{{template "accessfrequencies" $}}</div>{{end}}
+				{{if .IdleTimes}}<div role="tabpanel" class="tab-pane{{if eq $first "idletimes"}} active{{end}}" id="idletimes">{{template "idletimes" $}}</div>{{end}}
+				{{if .KeyMemorySizes}}<div role="tabpanel" class="tab-pane{{if eq $first "keymemory"}} active{{end}}" id="keymemory">{{template "keymemory" $}}</div>{{end}}
+			</div>
+			{{else}}
+			{{if .StringKeys}}{{template "strings" $}}{{end}}
+			{{if .SetKeys}}{{template "sets" $}}{{end}}
+			{{if .SortedSetKeys}}{{template "sortedsets" $}}{{end}}
+			{{if .ListKeys}}{{template "lists" $}}{{end}}
+			{{if .HashKeys}}{{template "hashes" $}}{{end}}
+			{{if .AccessFrequencies}}{{template "accessfrequencies" $}}{{end}}
+			{{if .IdleTimes}}{{template "idletimes" $}}{{end}}
+			{{if .KeyMemorySizes}}{{template "keymemory" $}}{{end}}
+			{{end}}
+
+		 </container>
+
+		<script src="https://ajax.googleapis.com/ajax/libs/jquery/1.11.2/jquery.min.js"></script>
+		<script src="https://maxcdn.bootstrapcdn.com/bootstrap/3.3.4/js/bootstrap.min.js"></script>
+		{{if $.View.InspectHost}}
+		<script type="text/javascript">
+			// copies the redis-cli command preceding a copy button
+			function copyCommand(button) {
+				navigator.clipboard.writeText($(button).prev("code").text());
+			}
+		</script>
+		{{end}}
+	</body>
+</html>
+
+{{end}}
+
+{{define "strings"}}
+			  {{ $strings := summarize .StringSizes }}
+			  <h1>Strings <small>{{$strings}}</small> </h1>
+				<div class="panel panel-default">
+					<div class="panel-body">
+						<h3>Example keys:</h3> {{template "keyexamples" (typed "string" .StringKeys)}}
+						<h3>Integer-encoded values: <small>{{.StringIntegers}} ({{percentage .StringIntegers $strings}}%)</small></h3>
+						<h3>Value Sizes: {{template "stats" keyStats .StringSizes $.TotalKeys}}</h3>
+						{{if and $.View.Raw (not $.View.Combined)}}{{template "freq" .StringSizes}}{{end}}
+						{{if $.View.Chart}}{{template "barchart" barChart "StringSizes" .StringSizes}}{{end}}
+						{{if $.View.PowerOfTwo}}
+						<h3>2<sup><var>n</var></sup> Value Sizes:</h3>
+						{{if $.View.Combined}}{{template "combinedfreq" combine .StringSizes}}{{else}}{{template "freq" power .StringSizes}}{{end}}
+						{{end}}
+						{{template "bucketkeys" bucketKeys "string"}}
+						{{if .StringBitCounts}}
+						<h3>Set Bits: {{template "stats" stats .StringBitCounts}}</h3>
+						{{if and $.View.Raw (not $.View.Combined)}}{{template "freq" .StringBitCounts}}{{end}}
+						{{if $.View.Chart}}{{template "barchart" barChart "StringBitCounts" .StringBitCounts}}{{end}}
+						{{if $.View.PowerOfTwo}}
+						<h3>2<sup><var>n</var></sup> Set Bits:</h3>
+						{{if $.View.Combined}}{{template "combinedfreq" combine .StringBitCounts}}{{else}}{{template "freq" power .StringBitCounts}}{{end}}
+						{{end}}
+						{{end}}
+					</div>
+				</div>
+{{end}}
+
+{{define "sets"}}
 			  <h1>Sets <small>{{summarize .SetSizes}}</small> </h1>
 				<div class="panel panel-default">
 					<div class="panel-body">
-						<h3>Example keys:</h3> {{template "examples" .SetKeys}}
-						<h3>Sizes: {{template "stats" .SetSizes}}</h3>
-						{{template "freq" .SetSizes}}
-						{{template "barchart" barChart "SetSizes" .SetSizes}}
+						<h3>Example keys:</h3> {{template "keyexamples" (typed "set" .SetKeys)}}
+						<h3>Sizes: {{template "stats" keyStats .SetSizes $.TotalKeys}}</h3>
+						{{if and $.View.Raw (not $.View.Combined)}}{{template "freq" .SetSizes}}{{end}}
+						{{if $.View.Chart}}{{template "barchart" barChart "SetSizes" .SetSizes}}{{end}}
+						{{if $.View.PowerOfTwo}}
 						<h3>2<sup><var>n</var></sup> Sizes:</h3>
-						{{template "freq" power .SetSizes}}
+						{{if $.View.Combined}}{{template "combinedfreq" combine .SetSizes}}{{else}}{{template "freq" power .SetSizes}}{{end}}
+						{{end}}
+						{{template "bucketkeys" bucketKeys "set"}}
 
 						<h3>Example elements:</h3> {{template "examples" .SetElements}}
-						<h3>Element Sizes: {{template "stats" .SetElementSizes}}</h3>
-						{{template "freq" .SetElementSizes}}
-						{{template "barchart" barChart "SetElementSizes" .SetElementSizes}}
+						<h3>Element Sizes: {{template "stats" stats .SetElementSizes}}</h3>
+						{{if and $.View.Raw (not $.View.Combined)}}{{template "freq" .SetElementSizes}}{{end}}
+						{{if $.View.Chart}}{{template "barchart" barChart "SetElementSizes" .SetElementSizes}}{{end}}
+						{{if $.View.PowerOfTwo}}
 						<h3>2<sup><var>n</var></sup> Element Sizes:</h3>
-						{{template "freq" power .SetElementSizes}}
+						{{if $.View.Combined}}{{template "combinedfreq" combine .SetElementSizes}}{{else}}{{template "freq" power .SetElementSizes}}{{end}}
+						{{end}}
+						{{if .SetByteSizes}}
+						<h3>Estimated Bytes per Set: {{template "stats" stats .SetByteSizes}}</h3>
+						{{if and $.View.Raw (not $.View.Combined)}}{{template "freq" .SetByteSizes}}{{end}}
+						{{if $.View.Chart}}{{template "barchart" barChart "SetByteSizes" .SetByteSizes}}{{end}}
+						{{if $.View.PowerOfTwo}}
+						<h3>2<sup><var>n</var></sup> Estimated Bytes per Set:</h3>
+						{{if $.View.Combined}}{{template "combinedfreq" combine .SetByteSizes}}{{else}}{{template "freq" power .SetByteSizes}}{{end}}
+						{{end}}
+						{{end}}
+						{{range $class, $sizes := .SetElementClassSizes}}
+						<h3>Element Sizes of <code>{{html $class}}</code> elements: {{template "stats" stats $sizes}}</h3>
+						{{if and $.View.Raw (not $.View.Combined)}}{{template "freq" $sizes}}{{end}}
+						{{if $.View.PowerOfTwo}}
+						<h3>2<sup><var>n</var></sup> Element Sizes of <code>{{html $class}}</code> elements:</h3>
+						{{if $.View.Combined}}{{template "combinedfreq" combine $sizes}}{{else}}{{template "freq" power $sizes}}{{end}}
+						{{end}}
+						{{end}}
 					</div>
 				</div>
-			{{ end }}
+{{end}}
 
-			{{ if .SortedSetKeys }}
+{{define "sortedsets"}}
 			  <h1>Sorted Sets <small>{{summarize .SortedSetSizes}}</small> </h1>
 				<div class="panel panel-default">
 					<div class="panel-body">
-						<h3>Example keys:</h3> {{template "examples" .SortedSetKeys}}
-						<h3>Sizes: {{template "stats" .SortedSetSizes}}</h3>
-						{{template "freq" .SortedSetSizes}}
-						{{template "barchart" barChart "SortedSetSizes" .SortedSetSizes}}
+						<h3>Example keys:</h3> {{template "keyexamples" (typed "zset" .SortedSetKeys)}}
+						<h3>Sizes: {{template "stats" keyStats .SortedSetSizes $.TotalKeys}}</h3>
+						{{if and $.View.Raw (not $.View.Combined)}}{{template "freq" .SortedSetSizes}}{{end}}
+						{{if $.View.Chart}}{{template "barchart" barChart "SortedSetSizes" .SortedSetSizes}}{{end}}
+						{{if $.View.PowerOfTwo}}
 						<h3>2<sup><var>n</var></sup> Sizes:</h3>
-						{{template "freq" power .SortedSetSizes}}
+						{{if $.View.Combined}}{{template "combinedfreq" combine .SortedSetSizes}}{{else}}{{template "freq" power .SortedSetSizes}}{{end}}
+						{{end}}
+						{{template "bucketkeys" bucketKeys "zset"}}
 
 						<h3>Example elements:</h3> {{template "examples" .SortedSetElements}}
-						<h3>Element Sizes: {{template "stats" .SortedSetElementSizes}}</h3>
-						{{template "freq" .SortedSetElementSizes}}
-						{{template "barchart" barChart "SortedSetElementSizes" .SortedSetElementSizes}}
+						<h3>Element Sizes: {{template "stats" stats .SortedSetElementSizes}}</h3>
+						{{if and $.View.Raw (not $.View.Combined)}}{{template "freq" .SortedSetElementSizes}}{{end}}
+						{{if $.View.Chart}}{{template "barchart" barChart "SortedSetElementSizes" .SortedSetElementSizes}}{{end}}
+						{{if $.View.PowerOfTwo}}
 						<h3>2<sup><var>n</var></sup> Element Sizes:</h3>
-						{{template "freq" power .SortedSetElementSizes}}
+						{{if $.View.Combined}}{{template "combinedfreq" combine .SortedSetElementSizes}}{{else}}{{template "freq" power .SortedSetElementSizes}}{{end}}
+						{{end}}
+						{{if .SortedSetByteSizes}}
+						<h3>Estimated Bytes per Sorted Set: {{template "stats" stats .SortedSetByteSizes}}</h3>
+						{{if and $.View.Raw (not $.View.Combined)}}{{template "freq" .SortedSetByteSizes}}{{end}}
+						{{if $.View.Chart}}{{template "barchart" barChart "SortedSetByteSizes" .SortedSetByteSizes}}{{end}}
+						{{if $.View.PowerOfTwo}}
+						<h3>2<sup><var>n</var></sup> Estimated Bytes per Sorted Set:</h3>
+						{{if $.View.Combined}}{{template "combinedfreq" combine .SortedSetByteSizes}}{{else}}{{template "freq" power .SortedSetByteSizes}}{{end}}
+						{{end}}
+						{{end}}
+						{{range $class, $sizes := .SortedSetElementClassSizes}}
+						<h3>Element Sizes of <code>{{html $class}}</code> elements: {{template "stats" stats $sizes}}</h3>
+						{{if and $.View.Raw (not $.View.Combined)}}{{template "freq" $sizes}}{{end}}
+						{{if $.View.PowerOfTwo}}
+						<h3>2<sup><var>n</var></sup> Element Sizes of <code>{{html $class}}</code> elements:</h3>
+						{{if $.View.Combined}}{{template "combinedfreq" combine $sizes}}{{else}}{{template "freq" power $sizes}}{{end}}
+						{{end}}
+						{{end}}
 					</div>
 				</div>
-			{{ end }}
+{{end}}
 
-			{{ if .ListKeys }}
+{{define "lists"}}
 			  <h1>Lists <small>{{summarize .ListSizes}}</small> </h1>
 				<div class="panel panel-default">
 					<div class="panel-body">
-						<h3>Example keys:</h3> {{template "examples" .ListKeys}}
-						<h3>Sizes: {{template "stats" .ListSizes}}</h3>
-						{{template "freq" .ListSizes}}
-						{{template "barchart" barChart "ListSizes" .ListSizes}}
+						<h3>Example keys:</h3> {{template "keyexamples" (typed "list" .ListKeys)}}
+						<h3>Sizes: {{template "stats" keyStats .ListSizes $.TotalKeys}}</h3>
+						{{if and $.View.Raw (not $.View.Combined)}}{{template "freq" .ListSizes}}{{end}}
+						{{if $.View.Chart}}{{template "barchart" barChart "ListSizes" .ListSizes}}{{end}}
+						{{if $.View.PowerOfTwo}}
 						<h3>2<sup><var>n</var></sup> Sizes:</h3>
-						{{template "freq" power .ListSizes}}
+						{{if $.View.Combined}}{{template "combinedfreq" combine .ListSizes}}{{else}}{{template "freq" power .ListSizes}}{{end}}
+						{{end}}
+						{{template "bucketkeys" bucketKeys "list"}}
 
 						<h3>Example elements:</h3> {{template "examples" .ListElements}}
-						<h3>Element Sizes: {{template "stats" .ListElementSizes}}</h3>
-						{{template "freq" .ListElementSizes}}
-						{{template "barchart" barChart "ListElementSizes" .ListElementSizes}}
+						<h3>Element Sizes: {{template "stats" stats .ListElementSizes}}</h3>
+						{{if and $.View.Raw (not $.View.Combined)}}{{template "freq" .ListElementSizes}}{{end}}
+						{{if $.View.Chart}}{{template "barchart" barChart "ListElementSizes" .ListElementSizes}}{{end}}
+						{{if $.View.PowerOfTwo}}
 						<h3>2<sup><var>n</var></sup> Element Sizes:</h3>
-						{{template "freq" power .ListElementSizes}}
+						{{if $.View.Combined}}{{template "combinedfreq" combine .ListElementSizes}}{{else}}{{template "freq" power .ListElementSizes}}{{end}}
+						{{end}}
+						{{if .ListHeadSizes}}
+						<h3>Head Element Sizes: {{template "stats" stats .ListHeadSizes}}</h3>
+						{{if and $.View.Raw (not $.View.Combined)}}{{template "freq" .ListHeadSizes}}{{end}}
+						{{if $.View.Chart}}{{template "barchart" barChart "ListHeadSizes" .ListHeadSizes}}{{end}}
+						{{if $.View.PowerOfTwo}}
+						<h3>2<sup><var>n</var></sup> Head Element Sizes:</h3>
+						{{if $.View.Combined}}{{template "combinedfreq" combine .ListHeadSizes}}{{else}}{{template "freq" power .ListHeadSizes}}{{end}}
+						{{end}}
+						<h3>Tail Element Sizes: {{template "stats" stats .ListTailSizes}}</h3>
+						{{if and $.View.Raw (not $.View.Combined)}}{{template "freq" .ListTailSizes}}{{end}}
+						{{if $.View.Chart}}{{template "barchart" barChart "ListTailSizes" .ListTailSizes}}{{end}}
+						{{if $.View.PowerOfTwo}}
+						<h3>2<sup><var>n</var></sup> Tail Element Sizes:</h3>
+						{{if $.View.Combined}}{{template "combinedfreq" combine .ListTailSizes}}{{else}}{{template "freq" power .ListTailSizes}}{{end}}
+						{{end}}
+						{{end}}
+						{{if .ListByteSizes}}
+						<h3>Estimated Bytes per List: {{template "stats" stats .ListByteSizes}}</h3>
+						{{if and $.View.Raw (not $.View.Combined)}}{{template "freq" .ListByteSizes}}{{end}}
+						{{if $.View.Chart}}{{template "barchart" barChart "ListByteSizes" .ListByteSizes}}{{end}}
+						{{if $.View.PowerOfTwo}}
+						<h3>2<sup><var>n</var></sup> Estimated Bytes per List:</h3>
+						{{if $.View.Combined}}{{template "combinedfreq" combine .ListByteSizes}}{{else}}{{template "freq" power .ListByteSizes}}{{end}}
+						{{end}}
+						{{end}}
+						{{range $class, $sizes := .ListElementClassSizes}}
+						<h3>Element Sizes of <code>{{html $class}}</code> elements: {{template "stats" stats $sizes}}</h3>
+						{{if and $.View.Raw (not $.View.Combined)}}{{template "freq" $sizes}}{{end}}
+						{{if $.View.PowerOfTwo}}
+						<h3>2<sup><var>n</var></sup> Element Sizes of <code>{{html $class}}</code> elements:</h3>
+						{{if $.View.Combined}}{{template "combinedfreq" combine $sizes}}{{else}}{{template "freq" power $sizes}}{{end}}
+						{{end}}
+						{{end}}
 					</div>
 				</div>
-			{{ end }}
+{{end}}
 
-			{{ if .HashKeys}}
+{{define "hashes"}}
 			  <h1>Hashes <small>{{summarize .HashSizes}}</small> </h1>
 				<div class="panel panel-default">
 					<div class="panel-body">
-						<h3>Example keys:</h3> {{template "examples" .HashKeys}}
-						<h3>Sizes: {{template "stats" .HashSizes}}</h3>
-						{{template "freq" .HashSizes}}
-						{{template "barchart" barChart "HashSizes" .HashSizes}}
+						<h3>Example keys:</h3> {{template "keyexamples" (typed "hash" .HashKeys)}}
+						<h3>Sizes: {{template "stats" keyStats .HashSizes $.TotalKeys}}</h3>
+						{{if and $.View.Raw (not $.View.Combined)}}{{template "freq" .HashSizes}}{{end}}
+						{{if $.View.Chart}}{{template "barchart" barChart "HashSizes" .HashSizes}}{{end}}
+						{{if $.View.PowerOfTwo}}
 						<h3>2<sup><var>n</var></sup> Sizes:</h3>
-						{{template "freq" power .HashSizes}}
+						{{if $.View.Combined}}{{template "combinedfreq" combine .HashSizes}}{{else}}{{template "freq" power .HashSizes}}{{end}}
+						{{end}}
+						{{template "bucketkeys" bucketKeys "hash"}}
 
 						<h3>Example elements:</h3> {{template "examples" .HashElements}}
-						<h3>Element Sizes: {{template "stats" .HashElementSizes}}</h3>
-						{{template "freq" .HashElementSizes}}
-						{{template "barchart" barChart "HashElementSizes" .HashElementSizes}}
+						<h3>Element Sizes: {{template "stats" stats .HashElementSizes}}</h3>
+						{{if and $.View.Raw (not $.View.Combined)}}{{template "freq" .HashElementSizes}}{{end}}
+						{{if $.View.Chart}}{{template "barchart" barChart "HashElementSizes" .HashElementSizes}}{{end}}
+						{{if $.View.PowerOfTwo}}
 						<h3>2<sup><var>n</var></sup> Element Sizes:</h3>
-						{{template "freq" power .HashElementSizes}}
+						{{if $.View.Combined}}{{template "combinedfreq" combine .HashElementSizes}}{{else}}{{template "freq" power .HashElementSizes}}{{end}}
+						{{end}}
 
 						<h3>Example values:</h3> {{template "examples" .HashValues}}
-						<h3>Value Sizes: {{template "stats" .HashValueSizes}}</h3>
-						{{template "freq" .HashValueSizes}}
-						{{template "barchart" barChart "HashValueSizes" .HashValueSizes}}
+						<h3>Value Sizes: {{template "stats" stats .HashValueSizes}}</h3>
+						{{if and $.View.Raw (not $.View.Combined)}}{{template "freq" .HashValueSizes}}{{end}}
+						{{if $.View.Chart}}{{template "barchart" barChart "HashValueSizes" .HashValueSizes}}{{end}}
+						{{if $.View.PowerOfTwo}}
 						<h3>2<sup><var>n</var></sup> Value Sizes:</h3>
-						{{template "freq" power .HashValueSizes}}
+						{{if $.View.Combined}}{{template "combinedfreq" combine .HashValueSizes}}{{else}}{{template "freq" power .HashValueSizes}}{{end}}
+						{{end}}
+						{{if .HashByteSizes}}
+						<h3>Estimated Bytes per Hash: {{template "stats" stats .HashByteSizes}}</h3>
+						{{if and $.View.Raw (not $.View.Combined)}}{{template "freq" .HashByteSizes}}{{end}}
+						{{if $.View.Chart}}{{template "barchart" barChart "HashByteSizes" .HashByteSizes}}{{end}}
+						{{if $.View.PowerOfTwo}}
+						<h3>2<sup><var>n</var></sup> Estimated Bytes per Hash:</h3>
+						{{if $.View.Combined}}{{template "combinedfreq" combine .HashByteSizes}}{{else}}{{template "freq" power .HashByteSizes}}{{end}}
+						{{end}}
+						{{end}}
+						{{range $class, $sizes := .HashFieldValueSizes}}
+						<h3>Value Sizes of <code>{{html $class}}</code> fields: {{template "stats" stats $sizes}}</h3>
+						{{if and $.View.Raw (not $.View.Combined)}}{{template "freq" $sizes}}{{end}}
+						{{if $.View.PowerOfTwo}}
+						<h3>2<sup><var>n</var></sup> Value Sizes of <code>{{html $class}}</code> fields:</h3>
+						{{if $.View.Combined}}{{template "combinedfreq" combine $sizes}}{{else}}{{template "freq" power $sizes}}{{end}}
+						{{end}}
+						{{end}}
 					</div>
 				</div>
-			{{ end }}
+{{end}}
 
-		 </container>
+{{define "accessfrequencies"}}
+			  <h1>Access Frequencies <small>{{summarize .AccessFrequencies}}</small> </h1>
+				<div class="panel panel-default">
+					<div class="panel-body">
+						<h3>Frequencies: {{template "stats" stats .AccessFrequencies}}</h3>
+						{{if $.View.Raw}}{{template "freq" .AccessFrequencies}}{{end}}
+						{{if $.View.Chart}}{{template "barchart" barChart "AccessFrequencies" .AccessFrequencies}}{{end}}
+					</div>
+				</div>
+{{end}}
 
-		<script src="https://ajax.googleapis.com/ajax/libs/jquery/1.11.2/jquery.min.js"></script>
-		<script src="https://maxcdn.bootstrapcdn.com/bootstrap/3.3.4/js/bootstrap.min.js"></script>
-	</body>
-</html>
+{{define "keymemory"}}
+			  {{ $c := .MemoryConcentration }}
+			  <h1>Key Memory <small>{{summarize .KeyMemorySizes}}</small> </h1>
+				<div class="panel panel-default">
+					<div class="panel-body">
+						<p>The largest 1% of keys account for {{share $c.Top1}}% of the memory used, the largest 5% for {{share $c.Top5}}%, and the largest 10% for {{share $c.Top10}}%.  The Gini coefficient is {{fmtFloat $c.Gini}}.</p>
+						<h3>Bytes per key: {{template "stats" stats .KeyMemorySizes}}</h3>
+						{{if and $.View.Raw (not $.View.Combined)}}{{template "freq" .KeyMemorySizes}}{{end}}
+						{{if $.View.Chart}}{{template "barchart" barChart "KeyMemorySizes" .KeyMemorySizes}}{{end}}
+						{{if $.View.PowerOfTwo}}
+						<h3>2<sup><var>n</var></sup> Bytes per key:</h3>
+						{{if $.View.Combined}}{{template "combinedfreq" combine .KeyMemorySizes}}{{else}}{{template "freq" power .KeyMemorySizes}}{{end}}
+						{{end}}
+					</div>
+				</div>
+{{end}}
 
+{{define "idletimes"}}
+			  {{ $idle := summarize .IdleTimes }}
+			  <h1>Idle Times <small>{{$idle}}</small> </h1>
+				<div class="panel panel-default">
+					<div class="panel-body">
+						<h3>Seconds since last access: {{template "stats" stats .IdleTimes}}</h3>
+						<table class="table table-striped">
+							<thead>
+								<tr>
+									<th>Idle time</th>
+									<th># of keys</th>
+									<th>%</th>
+								</tr>
+							</thead>
+							<tbody>
+							{{ range idle .IdleTimes }}
+								<tr><td>{{html .Label}}</td> <td>{{.Count}}</td> <td>{{percentage .Count $idle}}%</td></tr>
+							{{end}}
+							</tbody>
+						</table>
+					</div>
+				</div>
 {{end}}
 
 {{define "barchart"}}
@@ -167,6 +430,7 @@ const (
   {{ $l := len .Data }}
   {{ if ge $l 4}}
 	{{ $total := summarize .Data }}
+	{{ $bars := .Bars }}
 	<button class="btn btn-primary" type="button" data-toggle="collapse" data-target="#{{.DOMElement}}Collapse">toggle chart</button>
 	<div class="collapse in" id="{{.DOMElement}}Collapse">
 		<canvas id="{{.DOMElement}}"></canvas>
@@ -176,7 +440,7 @@ const (
 		var ctx = document.getElementById("{{.DOMElement}}").getContext("2d");
 
 		var data = {
-			labels: [ {{range $k, $v := .Data}} "{{$k}}", {{end}} ],
+			labels: [ {{range $bars}} "{{.Label}}", {{end}} ],
 			datasets: [
 			{
 				label: "size frequencies",
@@ -184,7 +448,7 @@ const (
 				strokeColor: "rgba(151,187,205,0.8)",
 				highlightFill: "rgba(151,187,205,0.75)",
 				highlightStroke: "rgba(151,187,205,1)",
-				data: [ {{range $k, $v := .Data}} {{percentage $v $total}}, {{end}} ]
+				data: [ {{range $bars}} {{percentage .Count $total}}, {{end}} ]
 			}
 			]
 		};
@@ -194,15 +458,36 @@ const (
 {{end}}
 
 {{define "stats"}}
-	{{ with stats . }}
-		<small>(min: {{.Min}} max: {{.Max}} mean: {{fmtFloat .Mean}} std dev: {{fmtFloat .StdDev}})</small>
+	{{ with . }}
+		<small>(min: {{.Min}} max: {{.Max}} mean: {{fmtFloat .Mean}}{{if defined .MeanCI}} ± {{fmtFloat .MeanCI}}{{end}} std dev: {{fmtFloat .StdDev}})</small>
 	{{end}}
 {{end}}
 
 {{define "examples"}}
 	<ul class="list-inline">
 	{{range $k, $v := .}}
-		<li><code>{{$k}}</code></li>
+		<li><code>{{html $k}}</code>{{with source $k}} <small>from {{html .}}</small>{{end}}</li>
+	{{end}}
+{{end}}
+
+{{define "keyexamples"}}
+	<ul class="list-inline">
+	{{range $k, $v := .Keys}}
+		<li><code>{{html $k}}</code>{{with source $k}} <small>from {{html .}}</small>{{end}}
+		{{with inspect $.Type $k}}<br><code>{{html .}}</code> <button type="button" class="btn btn-default btn-xs" onclick="copyCommand(this)">copy</button>{{end}}</li>
+	{{end}}
+	</ul>
+{{end}}
+
+{{define "bucketkeys"}}
+	{{if .}}
+	<h3>Example keys by 2<sup><var>n</var></sup> size:</h3>
+	<table class="table table-striped">
+		<thead><tr><th>Size</th> <th>Example keys</th></tr></thead>
+		<tbody>
+		{{range .}}<tr><td>{{.Size}}</td> <td>{{range .Keys}}<code>{{html .}}</code> {{end}}</td></tr>
+		{{end}}</tbody>
+	</table>
 	{{end}}
 {{end}}
 
@@ -224,5 +509,34 @@ const (
 	</table>
 {{end}}
 
+{{define "combinedfreq"}}
+  <table class="table table-striped">
+		<thead>
+			<tr>
+				<th>Size</th>
+				<th># of occurrences</th>
+				<th>%</th>
+			</tr>
+		</thead>
+		<tbody>
+		{{ range .Buckets }}
+			<tr>
+				<td>
+					<details>
+						<summary>{{.Size}}</summary>
+						<table class="table table-condensed">
+						{{ range .Sizes }}
+							<tr><td>{{.Size}}</td> <td>{{.Count}}</td> <td>{{percentage .Count $.Total}}%</td></tr>
+						{{end}}
+						</table>
+					</details>
+				</td>
+				<td>{{.Count}}</td> <td>{{percentage .Count $.Total}}%</td>
+			</tr>
+		{{end}}
+		</tbody>
+	</table>
+{{end}}
+
 `
 )
@@ -0,0 +1,585 @@
+/*
+ * Copyright (C) 2015 zulily, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reckon
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+// sampleResults builds a Results instance with observations of every type
+func sampleResults() *Results {
+	r := NewResults()
+	r.Name = "sample"
+	r.observeString("str1", "12345", "12345")
+	r.observeString("str2", "hello", "hello")
+	r.observeList("list1", 4, []string{"elem"})
+	r.observeSet("set1", 3, []string{"member"})
+	r.observeSortedSet("zset1", 2, []string{"zmember"})
+	r.observeHash("hash1", 5, []string{"field"}, []string{"value"})
+	return r
+}
+
+func assertContains(t *testing.T, s, substr string) {
+	if !strings.Contains(s, substr) {
+		t.Errorf("expected output to contain: %q", substr)
+	}
+}
+
+func TestRenderText(t *testing.T) {
+
+	var buf bytes.Buffer
+	if err := RenderText(sampleResults(), &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	assertContains(t, out, "--- Strings (2) ---")
+	assertContains(t, out, "Integer-encoded values: 1 (50.00%)")
+	assertContains(t, out, "--- Hashes (1) ---")
+}
+
+func TestRenderHTML(t *testing.T) {
+
+	var buf bytes.Buffer
+	if err := RenderHTML(sampleResults(), &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	assertContains(t, out, "<h1>sample <small>6 keys</small></h1>")
+	assertContains(t, out, "Integer-encoded values: <small>1 (50.00%)</small>")
+}
+
+func TestRenderUndefinedStatistics(t *testing.T) {
+
+	if fmtFloat(math.NaN()) != "n/a" || fmtFloat(math.Inf(1)) != "n/a" || fmtFloat(1.5) != "1.50" {
+		t.Error("expected undefined values to be formatted as n/a")
+	}
+
+	// a single observation has an undefined standard deviation
+	r := NewResults()
+	r.Name = "single"
+	r.observeSet("set1", 1, []string{"member"})
+
+	var text, html bytes.Buffer
+	if err := RenderText(r, &text); err != nil {
+		t.Fatal(err)
+	}
+	if err := RenderHTML(r, &html); err != nil {
+		t.Fatal(err)
+	}
+	for _, out := range []string{text.String(), html.String()} {
+		if strings.Contains(out, "NaN<") || strings.Contains(out, "NaN)") {
+			t.Error("expected no NaN in the rendered statistics")
+		}
+		assertContains(t, out, "mean: 1.00 std dev: n/a")
+	}
+}
+
+func TestRenderVanishedKeys(t *testing.T) {
+
+	r := sampleResults()
+	var buf bytes.Buffer
+	if err := RenderText(r, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "vanished") {
+		t.Error("expected no vanished keys line when none vanished")
+	}
+
+	other := sampleResults()
+	r.VanishedKeys, other.VanishedKeys = 2, 3
+	r.Merge(other)
+	assertInt(t, 5, int(r.VanishedKeys))
+
+	buf.Reset()
+	if err := RenderText(r, &buf); err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, buf.String(), "# of sampled keys that vanished: 5")
+
+	buf.Reset()
+	if err := RenderHTML(r, &buf); err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, buf.String(), "<p>5 sampled keys vanished before they could be observed.</p>")
+}
+
+func TestRenderIdleTimes(t *testing.T) {
+
+	r := sampleResults()
+	r.IdleTimes[30] = 3
+	r.IdleTimes[7200] = 1
+
+	var buf bytes.Buffer
+	if err := RenderText(r, &buf); err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, buf.String(), " < 1 minute: 3 (75.00)")
+	assertContains(t, buf.String(), " < 1 day: 1 (25.00)")
+
+	buf.Reset()
+	if err := RenderHTML(r, &buf); err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, buf.String(), "<tr><td>&lt; 1 minute</td> <td>3</td> <td>75.00%</td></tr>")
+}
+
+func TestRenderViews(t *testing.T) {
+
+	var buf bytes.Buffer
+	if err := RenderText(sampleResults(), &buf, WithViews(ViewPowerOfTwo)); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	assertContains(t, out, "^2 Sizes:")
+	assertContains(t, out, " 8: 2 (100.00)")
+	if strings.Contains(out, " 5: 2 (100.00)") {
+		t.Errorf("expected raw sizes to be omitted")
+	}
+
+	buf.Reset()
+	if err := RenderHTML(sampleResults(), &buf, WithViews(ViewRaw)); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "2<sup><var>n</var></sup>") {
+		t.Errorf("expected power-of-two tables to be omitted")
+	}
+}
+
+// countingWriter discards everything written to it, counting the writes and
+// tracking the size of the largest one
+type countingWriter struct {
+	writes   int
+	maxWrite int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	if len(p) > w.maxWrite {
+		w.maxWrite = len(p)
+	}
+	return len(p), nil
+}
+
+func TestCombinedFreq(t *testing.T) {
+
+	table := combinedFreq(map[int]int64{3: 1, 4: 2, 5: 1, 100: 4})
+	assertInt(t, 8, int(table.Total))
+	assertInt(t, 3, len(table.Buckets))
+
+	b := table.Buckets[0]
+	assertInt(t, 4, b.Size)
+	assertInt(t, 3, int(b.Count))
+	if len(b.Sizes) != 2 || b.Sizes[0] != (SizeCount{Size: 3, Count: 1}) || b.Sizes[1] != (SizeCount{Size: 4, Count: 2}) {
+		t.Errorf("unexpected raw sizes in bucket 4: %v", b.Sizes)
+	}
+	assertInt(t, 8, table.Buckets[1].Size)
+	assertInt(t, 128, table.Buckets[2].Size)
+
+	var buf bytes.Buffer
+	if err := RenderHTML(sampleResults(), &buf, WithCombinedTables()); err != nil {
+		t.Fatal(err)
+	}
+	combined := buf.String()
+	assertContains(t, combined, "<summary>8</summary>")
+
+	buf.Reset()
+	if err := RenderHTML(sampleResults(), &buf, WithCombinedTables(), WithViews(ViewPowerOfTwo)); err != nil {
+		t.Fatal(err)
+	}
+	powerOnly := buf.String()
+	if strings.Contains(powerOnly, "<details>") {
+		t.Errorf("expected no combined tables without the raw view")
+	}
+
+	// the raw tables are merged into the power-of-two tables
+	tag := `<table class="table table-striped">`
+	if strings.Count(combined, tag) != strings.Count(powerOnly, tag) {
+		t.Errorf("expected %d tables, actual: %d", strings.Count(powerOnly, tag), strings.Count(combined, tag))
+	}
+}
+
+func TestRenderHTMLStreams(t *testing.T) {
+
+	r := sampleResults()
+	for i := 0; i < 50; i++ {
+		r.StringSizes[i] = 1
+	}
+
+	w := &countingWriter{}
+	if err := RenderHTML(r, w); err != nil {
+		t.Fatal(err)
+	}
+
+	if w.writes < 100 {
+		t.Errorf("expected the report to be written incrementally, actual writes: %d", w.writes)
+	}
+	if w.maxWrite > len(chartJS()) {
+		t.Errorf("expected no write larger than the inlined js (%d bytes), actual: %d", len(chartJS()), w.maxWrite)
+	}
+}
+
+func TestRenderBitCounts(t *testing.T) {
+
+	r := sampleResults()
+	var buf bytes.Buffer
+	if err := RenderText(r, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "Set Bits") {
+		t.Error("expected no set bits section without BitmapStats")
+	}
+
+	r = sampleResults()
+	r.StringBitCounts[3] = 2
+	buf.Reset()
+	if err := RenderText(r, &buf); err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, buf.String(), "Set Bits (")
+	assertContains(t, buf.String(), " 3: 2 (100.00)")
+
+	buf.Reset()
+	if err := RenderHTML(r, &buf); err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, buf.String(), "<h3>Set Bits: ")
+}
+
+func TestRenderHashFieldClasses(t *testing.T) {
+
+	r := sampleResults()
+	r.observeHashFieldClasses([][]string{{"blob"}}, []string{"0123456789"})
+	var buf bytes.Buffer
+	if err := RenderText(r, &buf); err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, buf.String(), "Value Sizes of blob fields (")
+	assertContains(t, buf.String(), " 10: 1 (100.00)")
+
+	buf.Reset()
+	if err := RenderHTML(r, &buf); err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, buf.String(), "<h3>Value Sizes of <code>blob</code> fields: ")
+}
+
+func TestRenderAll(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "reckon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, "reports")
+	results := map[string]*Results{"a": sampleResults(), "b/c": sampleResults(), "b_c": sampleResults(), "d:e": sampleResults(), ".f": sampleResults()}
+	if err := RenderAll(results, out); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"a.html", "a.txt", "b%2Fc.html", "b%2Fc.txt", "b_c.html", "d%3Ae.txt", "%2Ef.txt"} {
+		if _, err := os.Stat(filepath.Join(out, name)); err != nil {
+			t.Errorf("expected report %s: %s", name, err)
+		}
+	}
+
+	txt, err := ioutil.ReadFile(filepath.Join(out, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, string(txt), "--- Strings (2) ---")
+
+	for _, group := range []string{"a/b", "a:b", "a b", "a%b", ".", "..", "a\x00b"} {
+		name := reportFileName(group)
+		if unescaped, err := url.PathUnescape(name); err != nil || unescaped != group {
+			t.Errorf("expected %q to decode to %q, actual: %q (%v)", name, group, unescaped, err)
+		}
+		if strings.ContainsAny(name, "/\\:\x00") || strings.HasPrefix(name, ".") {
+			t.Errorf("unsafe file name for %q: %q", group, name)
+		}
+	}
+
+	collide := map[string]*Results{"a": sampleResults(), "A": sampleResults()}
+	if err := RenderAll(collide, filepath.Join(dir, "collide")); err == nil {
+		t.Error("expected an error for file names that differ only in case")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "collide")); !os.IsNotExist(err) {
+		t.Error("expected no reports to be written when file names collide")
+	}
+}
+
+func TestRenderZip(t *testing.T) {
+
+	var buf bytes.Buffer
+	results := map[string]*Results{"a": sampleResults(), "b/c": sampleResults()}
+	if err := RenderZip(results, &buf, "text"); err != nil {
+		t.Fatal(err)
+	}
+
+	z, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := map[string]string{}
+	for _, f := range z.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries[f.Name] = string(b)
+	}
+
+	if len(entries) != 3 {
+		t.Errorf("expected two reports and an index, actual: %d entries", len(entries))
+	}
+	assertContains(t, entries["b%2Fc.txt"], "--- Strings (2) ---")
+	assertContains(t, entries["index.html"], `<a href="b%252Fc.txt">b/c</a> (6 keys)`)
+
+	if err := RenderZip(results, &buf, "csv"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestChartBuckets(t *testing.T) {
+
+	freq := map[int]int64{1: 5, 2: 40, 3: 30, 4: 10, 5: 15}
+	bars := barChart("sizes", freq, 3).Bars()
+	if fmt.Sprint(bars) != "[{2 40} {3 30} {5 15} {other 15}]" {
+		t.Errorf("unexpected bars: %v", bars)
+	}
+	if bars = barChart("sizes", freq, 10).Bars(); len(bars) != 5 {
+		t.Errorf("expected a bar for each size, actual: %v", bars)
+	}
+
+	r := NewResults()
+	r.observeSet("set1", 1, []string{"member"})
+	for size := 1; size <= 50; size++ {
+		r.SetSizes[size] = int64(size)
+	}
+	var buf bytes.Buffer
+	if err := RenderHTML(r, &buf, WithChartBuckets(4)); err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, buf.String(), `labels: [  "47",  "48",  "49",  "50",  "other",  ]`)
+
+	if _, err := newRenderOptions([]func(*RenderOptions) error{WithChartBuckets(0)}); err == nil {
+		t.Error("expected an error for fewer than 1 ChartBuckets")
+	}
+}
+
+func TestRenderBucketExamples(t *testing.T) {
+
+	r := sampleResults()
+	r.observeBucketExample(TypeString, 5, "str1", 2)
+	r.observeBucketExample(TypeString, 5, "str2", 2)
+	r.observeBucketExample(TypeString, 1000, "big", 2)
+
+	var buf bytes.Buffer
+	if err := RenderText(r.Clone(), &buf); err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, buf.String(), "Example Keys by ^2 Size:\n 8: str1 str2\n 1024: big\n")
+
+	buf.Reset()
+	if err := RenderHTML(r, &buf); err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, buf.String(), "<tr><td>8</td> <td><code>str1</code> <code>str2</code> </td></tr>")
+}
+
+func TestRenderTabs(t *testing.T) {
+
+	var buf bytes.Buffer
+	if err := RenderHTML(sampleResults(), &buf); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "nav-tabs") {
+		t.Error("expected no tabs by default")
+	}
+	stacked := strings.Count(buf.String(), "<h1>Hashes")
+
+	buf.Reset()
+	if err := RenderHTML(sampleResults(), &buf, WithTabs()); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	assertContains(t, out, `<li role="presentation" class="active"><a href="#strings" aria-controls="strings" role="tab" data-toggle="tab">Strings</a>`)
+	assertContains(t, out, `<li role="presentation"><a href="#hashes"`)
+
+	// only the first pane is shown until another tab is selected
+	assertInt(t, 1, strings.Count(out, "tab-pane active"))
+	assertContains(t, out, `<div role="tabpanel" class="tab-pane active" id="strings">`)
+	assertContains(t, out, `<div role="tabpanel" class="tab-pane" id="hashes">`)
+	if strings.Contains(out, `href="#idletimes"`) {
+		t.Error("expected no tab for absent idle times")
+	}
+	assertInt(t, stacked, strings.Count(out, "<h1>Hashes"))
+
+	r := NewResults()
+	r.observeHash("h", 1, []string{"f"}, []string{"v"})
+	buf.Reset()
+	if err := RenderHTML(r, &buf, WithTabs()); err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, buf.String(), `<div role="tabpanel" class="tab-pane active" id="hashes">`)
+}
+
+func TestSparkline(t *testing.T) {
+
+	if s := sparkline(map[int]int64{}); s != "" {
+		t.Errorf("expected an empty sparkline, actual: %q", s)
+	}
+
+	// the 4- and 8-byte buckets are empty
+	s := sparkline(map[int]int64{1: 8, 2: 4, 11: 1, 16: 0})
+	if s != "1 █▄  ▁ 16" {
+		t.Errorf("unexpected sparkline: %q", s)
+	}
+
+	var buf bytes.Buffer
+	if err := RenderText(sampleResults(), &buf); err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, buf.String(), "Distribution: 8 █ 8")
+}
+
+func TestInspectCommands(t *testing.T) {
+
+	if cmd := inspectCommand("localhost", 6379, TypeSortedSet, "it's"); cmd != `redis-cli -h 'localhost' -p 6379 ZRANGE 'it'\''s' 0 -1 WITHSCORES` {
+		t.Errorf("unexpected command: %s", cmd)
+	}
+
+	var buf bytes.Buffer
+	if err := RenderHTML(sampleResults(), &buf); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "redis-cli") {
+		t.Error("expected no inspect commands by default")
+	}
+
+	buf.Reset()
+	r := sampleResults()
+	r.observeString(`bin\x00`, "v", "v")
+	r.observeBinaryKey(`bin\x00`)
+	if err := RenderHTML(r, &buf, WithInspectCommands("redis.internal", 6380)); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	assertContains(t, out, "redis-cli -h &#39;redis.internal&#39; -p 6380 GET &#39;str1&#39;")
+	assertContains(t, out, "redis-cli -h &#39;redis.internal&#39; -p 6380 HGETALL &#39;hash1&#39;")
+	assertContains(t, out, "function copyCommand(button)")
+	if strings.Contains(out, `GET &#39;bin`) {
+		t.Error("expected no inspect command for a binary key name")
+	}
+
+	// an escaped key that was not retained among the BinaryKeys
+	buf.Reset()
+	r.BinaryKeys = map[string]bool{}
+	if err := RenderHTML(r, &buf, WithInspectCommands("redis.internal", 6380)); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), `GET &#39;bin`) {
+		t.Error("expected no inspect command for an escaped key name")
+	}
+
+	if _, err := newRenderOptions([]func(*RenderOptions) error{WithInspectCommands("", 6379)}); err == nil {
+		t.Error("expected an error for an empty host")
+	}
+}
+
+func TestRenderHTMLEscapesKeys(t *testing.T) {
+
+	r := NewResults()
+	r.observeString("<b>&key", "v", "v")
+	r.Sources["<b>&key"] = "<host>"
+
+	var buf bytes.Buffer
+	if err := RenderHTML(r, &buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	assertContains(t, out, "<code>&lt;b&gt;&amp;key</code> <small>from &lt;host&gt;</small>")
+	if strings.Contains(out, "<b>&key") || strings.Contains(out, "<host>") {
+		t.Error("expected example keys and their sources to be escaped")
+	}
+}
+
+func TestRenderWithFuncs(t *testing.T) {
+
+	humanize := template.FuncMap{"humanizeBytes": func(n int64) string { return fmt.Sprintf("%d B", n) }}
+	fm, err := FuncMap(sampleResults(), "text", WithFuncs(humanize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	custom := template.Must(template.New("custom").Funcs(fm).Parse(`{{humanizeBytes .TotalKeys}}, {{percentage 1 .TotalKeys}}%`))
+	var buf bytes.Buffer
+	r := sampleResults()
+	r.TotalKeys = 8
+	if err := custom.Execute(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, buf.String(), "8 B, 12.50%")
+
+	// an extra func replaces the built-in func of the same name
+	buf.Reset()
+	fmtFloat := WithFuncs(template.FuncMap{"fmtFloat": func(float64) string { return "~" }})
+	if err := RenderText(sampleResults(), &buf, fmtFloat); err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, buf.String(), "mean: ~")
+
+	if _, err := FuncMap(sampleResults(), "csv"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestRenderPopulationCorrection(t *testing.T) {
+
+	r := NewResults()
+	r.observeHash("h1", 1, []string{"a"}, []string{"a"})
+	r.observeHash("h2", 3, []string{"abc"}, []string{"abc"})
+	r.TotalKeys = 3
+
+	var buf bytes.Buffer
+	if err := RenderText(r, &buf); err != nil {
+		t.Fatal(err)
+	}
+	// the per-key sizes are corrected for the group's 3 keys, but the field
+	// and value sizes are not, since they are not sampled from its keys
+	uncorrected := ComputeStatistics(r.HashSizes).MeanCI
+	assertContains(t, buf.String(), "mean: 2.00 ± "+fmtFloat(uncorrected*math.Sqrt(0.5)))
+	if strings.Count(buf.String(), "mean: 2.00 ± "+fmtFloat(uncorrected)) != 2 {
+		t.Errorf("expected only the field and value sizes to be uncorrected: %s", buf.String())
+	}
+}
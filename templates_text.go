@@ -19,76 +19,156 @@ package reckon
 const (
 	statsTempl = `
 {{define "base"}}
-# of keys sampled: {{.KeyCount}}
-
-{{ if .StringKeys }}
---- Strings ({{summarize .StringSizes}}) ---
+{{if .ScaleFactor}}# of keys (estimated): {{.KeyCount}}
+(all counts are estimates for the full keyspace, scaled by {{fmtFloat .ScaleFactor}} from the sampled counts)
+{{else}}# of keys sampled: {{.KeyCount}}
+{{end}}Estimated bytes in values: {{.EstimatedBytes}} (from mean sizes; excludes key names and redis overhead)
+{{if .VanishedKeys}}# of sampled keys that vanished: {{.VanishedKeys}}
+{{end}}{{if .Approximate}}(sizes are approximate, to within about 3%)
+{{end}}{{ if .UnmatchedKeys }}
+--- Unmatched Keys ({{.UnmatchedCount}}, {{percentage .UnmatchedCount .KeyCount}}%) ---
+{{template "exampleKeys" .UnmatchedKeys}}{{end}}{{ if .BinaryKeys }}
+--- Binary Key Names ({{.BinaryKeyCount}}, {{percentage .BinaryKeyCount .KeyCount}}%) ---
+{{template "exampleKeys" .BinaryKeys}}{{end}}{{ if .NonUniformKeys }}
+--- Non-Uniform Elements ({{.NonUniformCount}}, {{percentage .NonUniformCount .KeyCount}}%) ---
+Expected element size: {{.UniformElementSize}}
+{{range $t, $cv := .NonUniformTypes}}{{$t}} element sizes vary (coefficient of variation: {{fmtFloat $cv}})
+{{end}}{{template "exampleKeys" .NonUniformKeys}}{{end}}{{ if .OtherTypes }}
+--- Other Types ---
+{{range $t, $n := .OtherTypes}}{{$t}}: {{$n}} ({{percentage $n $.KeyCount}}%)
+{{end}}{{end}}
+{{ if .StringKeys }}{{ $strings := summarize .StringSizes }}
+--- Strings ({{$strings}}) ---
 {{template "exampleKeys" .StringKeys}}
 {{template "exampleValues" .StringValues}}
-Sizes ({{template "stats" .StringSizes}}):
-{{template "freq" .StringSizes}}
-^2 Sizes:{{template "freq" power .StringSizes}}{{end}}
+Integer-encoded values: {{.StringIntegers}} ({{percentage .StringIntegers $strings}}%)
+Sizes ({{template "stats" keyStats .StringSizes $.TotalKeys}}):
+Distribution: {{sparkline .StringSizes}}
+{{if $.View.Raw}}{{template "freq" .StringSizes}}{{end}}
+{{if $.View.PowerOfTwo}}^2 Sizes:{{template "freq" power .StringSizes}}{{end}}
+{{template "bucketKeys" bucketKeys "string"}}{{if .StringBitCounts}}Set Bits ({{template "stats" stats .StringBitCounts}}):
+{{if $.View.Raw}}{{template "freq" .StringBitCounts}}{{end}}
+{{if $.View.PowerOfTwo}}^2 Set Bits:{{template "freq" power .StringBitCounts}}{{end}}{{end}}{{end}}
 
 {{ if .SetKeys }}
 --- Sets ({{summarize .SetSizes}}) ---
 {{template "exampleKeys" .SetKeys}}
-Sizes ({{template "stats" .SetSizes}}):
-{{template "freq" .SetSizes}}
-^2 Sizes:{{template "freq" power .SetSizes}}
-{{template "exampleElements" .SetElements}}
-Element Sizes:{{template "freq" .SetElementSizes}}
-Element ^2 Sizes:{{template "freq" power .SetElementSizes}}{{end}}
+Sizes ({{template "stats" keyStats .SetSizes $.TotalKeys}}):
+Distribution: {{sparkline .SetSizes}}
+{{if $.View.Raw}}{{template "freq" .SetSizes}}{{end}}
+{{if $.View.PowerOfTwo}}^2 Sizes:{{template "freq" power .SetSizes}}{{end}}
+{{template "bucketKeys" bucketKeys "set"}}{{template "exampleElements" .SetElements}}
+{{if $.View.Raw}}Element Sizes:{{template "freq" .SetElementSizes}}{{end}}
+{{if $.View.PowerOfTwo}}Element ^2 Sizes:{{template "freq" power .SetElementSizes}}{{end}}
+{{if .SetByteSizes}}Estimated Bytes per Set ({{template "stats" stats .SetByteSizes}}):
+{{if $.View.Raw}}{{template "freq" .SetByteSizes}}{{end}}
+{{if $.View.PowerOfTwo}}^2 Estimated Bytes per Set:{{template "freq" power .SetByteSizes}}{{end}}
+{{end}}{{range $class, $sizes := .SetElementClassSizes}}Element Sizes of {{$class}} elements ({{template "stats" stats $sizes}}):
+{{if $.View.Raw}}{{template "freq" $sizes}}{{end}}
+{{if $.View.PowerOfTwo}}^2 Element Sizes of {{$class}} elements:{{template "freq" power $sizes}}{{end}}
+{{end}}{{end}}
 
 {{ if .SortedSetKeys }}
 --- Sorted Sets ({{summarize .SortedSetSizes}}) ---
 {{template "exampleKeys" .SortedSetKeys}}
-Sizes ({{template "stats" .SortedSetSizes}}):
-{{template "freq" .SortedSetSizes}}
-^2 Sizes:{{template "freq" power .SortedSetSizes}}
-{{template "exampleElements" .SortedSetElements}}
-Element Sizes ({{template "stats" .SortedSetElementSizes}}):
-{{template "freq" .SortedSetElementSizes}}
-Element ^2 Sizes:{{template "freq" power .SortedSetElementSizes}}{{end}}
+Sizes ({{template "stats" keyStats .SortedSetSizes $.TotalKeys}}):
+Distribution: {{sparkline .SortedSetSizes}}
+{{if $.View.Raw}}{{template "freq" .SortedSetSizes}}{{end}}
+{{if $.View.PowerOfTwo}}^2 Sizes:{{template "freq" power .SortedSetSizes}}{{end}}
+{{template "bucketKeys" bucketKeys "zset"}}{{template "exampleElements" .SortedSetElements}}
+Element Sizes ({{template "stats" stats .SortedSetElementSizes}}):
+{{if $.View.Raw}}{{template "freq" .SortedSetElementSizes}}{{end}}
+{{if $.View.PowerOfTwo}}Element ^2 Sizes:{{template "freq" power .SortedSetElementSizes}}{{end}}
+{{if .SortedSetByteSizes}}Estimated Bytes per Sorted Set ({{template "stats" stats .SortedSetByteSizes}}):
+{{if $.View.Raw}}{{template "freq" .SortedSetByteSizes}}{{end}}
+{{if $.View.PowerOfTwo}}^2 Estimated Bytes per Sorted Set:{{template "freq" power .SortedSetByteSizes}}{{end}}
+{{end}}{{range $class, $sizes := .SortedSetElementClassSizes}}Element Sizes of {{$class}} elements ({{template "stats" stats $sizes}}):
+{{if $.View.Raw}}{{template "freq" $sizes}}{{end}}
+{{if $.View.PowerOfTwo}}^2 Element Sizes of {{$class}} elements:{{template "freq" power $sizes}}{{end}}
+{{end}}{{end}}
 
 {{ if .HashKeys }}
 --- Hashes ({{summarize .HashSizes}}) ---
 {{template "exampleKeys" .HashKeys}}
-Sizes ({{template "stats" .HashSizes}}):
-{{template "freq" .HashSizes}}
-^2 Sizes:{{template "freq" power .HashSizes}}
-{{template "exampleElements" .HashElements}}
-Element Sizes ({{template "stats" .HashElementSizes}}):
-{{template "freq" .HashElementSizes}}
-^2 Element Sizes:{{template "freq" power .HashElementSizes}}
+Sizes ({{template "stats" keyStats .HashSizes $.TotalKeys}}):
+Distribution: {{sparkline .HashSizes}}
+{{if $.View.Raw}}{{template "freq" .HashSizes}}{{end}}
+{{if $.View.PowerOfTwo}}^2 Sizes:{{template "freq" power .HashSizes}}{{end}}
+{{template "bucketKeys" bucketKeys "hash"}}{{template "exampleElements" .HashElements}}
+Element Sizes ({{template "stats" stats .HashElementSizes}}):
+{{if $.View.Raw}}{{template "freq" .HashElementSizes}}{{end}}
+{{if $.View.PowerOfTwo}}^2 Element Sizes:{{template "freq" power .HashElementSizes}}{{end}}
 {{template "exampleValues" .HashValues}}
-Value Sizes ({{template "stats" .HashValueSizes}}):
-{{template "freq" .HashValueSizes}}
-^2 Value Sizes:{{template "freq" power .HashValueSizes}}{{end}}
+Value Sizes ({{template "stats" stats .HashValueSizes}}):
+{{if $.View.Raw}}{{template "freq" .HashValueSizes}}{{end}}
+{{if $.View.PowerOfTwo}}^2 Value Sizes:{{template "freq" power .HashValueSizes}}{{end}}
+{{if .HashByteSizes}}Estimated Bytes per Hash ({{template "stats" stats .HashByteSizes}}):
+{{if $.View.Raw}}{{template "freq" .HashByteSizes}}{{end}}
+{{if $.View.PowerOfTwo}}^2 Estimated Bytes per Hash:{{template "freq" power .HashByteSizes}}{{end}}
+{{end}}{{range $class, $sizes := .HashFieldValueSizes}}Value Sizes of {{$class}} fields ({{template "stats" stats $sizes}}):
+{{if $.View.Raw}}{{template "freq" $sizes}}{{end}}
+{{if $.View.PowerOfTwo}}^2 Value Sizes of {{$class}} fields:{{template "freq" power $sizes}}{{end}}
+{{end}}{{end}}
 
 {{ if .ListKeys }}
 --- Lists ({{summarize .ListSizes}}) ---
 {{template "exampleKeys" .ListKeys}}
-Sizes ({{template "stats" .ListSizes}}):
-{{template "freq" .ListSizes}}
-^2 Sizes:{{template "freq" power .ListSizes}}
-{{template "exampleElements" .ListElements}}
-Element Sizes ({{template "stats" .ListElementSizes}}):
-{{template "freq" .ListElementSizes}}
-^2 Element Sizes{{template "freq" power .ListElementSizes}}
+Sizes ({{template "stats" keyStats .ListSizes $.TotalKeys}}):
+Distribution: {{sparkline .ListSizes}}
+{{if $.View.Raw}}{{template "freq" .ListSizes}}{{end}}
+{{if $.View.PowerOfTwo}}^2 Sizes:{{template "freq" power .ListSizes}}{{end}}
+{{template "bucketKeys" bucketKeys "list"}}{{template "exampleElements" .ListElements}}
+Element Sizes ({{template "stats" stats .ListElementSizes}}):
+{{if $.View.Raw}}{{template "freq" .ListElementSizes}}{{end}}
+{{if $.View.PowerOfTwo}}^2 Element Sizes{{template "freq" power .ListElementSizes}}{{end}}
+{{if .ListByteSizes}}Estimated Bytes per List ({{template "stats" stats .ListByteSizes}}):
+{{if $.View.Raw}}{{template "freq" .ListByteSizes}}{{end}}
+{{if $.View.PowerOfTwo}}^2 Estimated Bytes per List:{{template "freq" power .ListByteSizes}}{{end}}
+{{end}}{{if .ListHeadSizes}}Head Element Sizes ({{template "stats" stats .ListHeadSizes}}):
+{{if $.View.Raw}}{{template "freq" .ListHeadSizes}}{{end}}
+{{if $.View.PowerOfTwo}}^2 Head Element Sizes:{{template "freq" power .ListHeadSizes}}{{end}}
+Tail Element Sizes ({{template "stats" stats .ListTailSizes}}):
+{{if $.View.Raw}}{{template "freq" .ListTailSizes}}{{end}}
+{{if $.View.PowerOfTwo}}^2 Tail Element Sizes:{{template "freq" power .ListTailSizes}}{{end}}
+{{end}}{{range $class, $sizes := .ListElementClassSizes}}Element Sizes of {{$class}} elements ({{template "stats" stats $sizes}}):
+{{if $.View.Raw}}{{template "freq" $sizes}}{{end}}
+{{if $.View.PowerOfTwo}}^2 Element Sizes of {{$class}} elements:{{template "freq" power $sizes}}{{end}}
 {{end}}{{end}}
+{{ if .AccessFrequencies }}
+--- Access Frequencies ({{summarize .AccessFrequencies}}) ---
+Frequencies ({{template "stats" stats .AccessFrequencies}}):
+{{if $.View.Raw}}{{template "freq" .AccessFrequencies}}{{end}}
+{{end}}
+{{ if .KeyMemorySizes }}{{ $c := .MemoryConcentration }}
+--- Key Memory ({{summarize .KeyMemorySizes}}) ---
+Bytes per key ({{template "stats" stats .KeyMemorySizes}}):
+{{if $.View.Raw}}{{template "freq" .KeyMemorySizes}}{{end}}
+{{if $.View.PowerOfTwo}}^2 Bytes per key:{{template "freq" power .KeyMemorySizes}}{{end}}
+Share of memory in the largest 1% of keys: {{share $c.Top1}}%, 5%: {{share $c.Top5}}%, 10%: {{share $c.Top10}}%
+Gini coefficient: {{fmtFloat $c.Gini}}
+{{end}}
+{{ if .IdleTimes }}{{ $idle := summarize .IdleTimes }}
+--- Idle Times ({{$idle}}) ---
+Seconds since last access ({{template "stats" stats .IdleTimes}}):
+{{ range idle .IdleTimes }} {{.Label}}: {{.Count}} ({{percentage .Count $idle}})
+{{end}}{{end}}{{end}}
 
-{{define "stats"}}{{ with stats . }}min: {{.Min}} max: {{.Max}} mean: {{fmtFloat .Mean}} std dev: {{fmtFloat .StdDev}}{{end}}{{end}}
+{{define "stats"}}{{ with . }}min: {{.Min}} max: {{.Max}} mean: {{fmtFloat .Mean}}{{if defined .MeanCI}} ± {{fmtFloat .MeanCI}}{{end}} std dev: {{fmtFloat .StdDev}}{{end}}{{end}}
 
 {{define "exampleKeys"}}Example Keys:
-{{range $k, $v := .}} {{$k}}
+{{range $k, $v := .}} {{$k}}{{with source $k}} (from {{.}}){{end}}
 {{end}}{{end}}
 
+{{define "bucketKeys"}}{{if .}}Example Keys by ^2 Size:
+{{range .}} {{.Size}}:{{range .Keys}} {{.}}{{end}}
+{{end}}{{end}}{{end}}
+
 {{define "exampleValues"}}Example Values:
-{{range $k, $v := .}} {{$k}}
+{{range $k, $v := .}} {{$k}}{{with source $k}} (from {{.}}){{end}}
 {{end}}{{end}}
 
 {{define "exampleElements"}}Example Elements:
-{{range $k, $v := .}} {{$k}}
+{{range $k, $v := .}} {{$k}}{{with source $k}} (from {{.}}){{end}}
 {{end}}{{end}}
 
 {{define "freq"}}
@@ -0,0 +1,66 @@
+/*
+ * Copyright (C) 2015 zulily, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reckon
+
+// A Tracer starts the spans with which a sampling operation is traced.  See
+// the `Tracer` field of Options.  It is deliberately small, so that an
+// OpenTelemetry trace.Tracer (or that of any other tracing library) can be
+// adapted to it in a few lines, without reckon depending on the library.
+type Tracer interface {
+	// Start begins a span called `name`
+	Start(name string) Span
+}
+
+// A Span is a single timed operation, begun by a Tracer
+type Span interface {
+	// SetAttribute tags the span with a key-value pair
+	SetAttribute(key string, value interface{})
+
+	// End completes the span.  `err` is the error with which the operation
+	// failed, or nil.
+	End(err error)
+}
+
+// noopSpan is the Span used when no Tracer is configured
+type noopSpan struct{}
+
+func (noopSpan) SetAttribute(key string, value interface{}) {}
+func (noopSpan) End(err error)                              {}
+
+// startSpan begins a span called `name` using the configured Tracer, if any
+func (s *sampler) startSpan(name string) Span {
+	if s.opts.Tracer == nil {
+		return noopSpan{}
+	}
+	return s.opts.Tracer.Start(name)
+}
+
+// samplingMode names the means by which keys are chosen for observation
+// under `opts`, in the order of precedence applied by Run
+func samplingMode(opts Options) string {
+	switch {
+	case opts.KeysGlob != "":
+		return "glob"
+	case opts.KeySource != nil:
+		return "source"
+	case len(opts.HashSlots) > 0:
+		return "slots"
+	case opts.ScanLimit > 0:
+		return "scan"
+	}
+	return "random"
+}
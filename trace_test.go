@@ -0,0 +1,104 @@
+/*
+ * Copyright (C) 2015 zulily, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reckon
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+)
+
+// recordingTracer is a Tracer that records the spans it starts
+type recordingTracer struct {
+	spans []*recordingSpan
+}
+
+type recordingSpan struct {
+	name  string
+	attrs map[string]interface{}
+	ended bool
+	err   error
+}
+
+func (t *recordingTracer) Start(name string) Span {
+	span := &recordingSpan{name: name, attrs: make(map[string]interface{})}
+	t.spans = append(t.spans, span)
+	return span
+}
+
+func (s *recordingSpan) SetAttribute(key string, value interface{}) {
+	s.attrs[key] = value
+}
+
+func (s *recordingSpan) End(err error) {
+	s.ended, s.err = true, err
+}
+
+func TestTracer(t *testing.T) {
+
+	tracer := &recordingTracer{}
+	dialErr := errors.New("connection refused")
+	opts, err := NewOptions(
+		WithHost("redis.internal"),
+		WithTracer(tracer),
+		WithMinSamples(10),
+		WithDialer(func(network, addr string) (net.Conn, error) { return nil, dialErr }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = Run(opts, AggregatorFunc(AnyKey)); err == nil {
+		t.Fatal("expected a connection error")
+	}
+
+	if len(tracer.spans) != 2 {
+		t.Fatalf("expected run and connect spans, actual: %d spans", len(tracer.spans))
+	}
+	run, connect := tracer.spans[0], tracer.spans[1]
+	if run.name != "reckon.run" || connect.name != "reckon.connect" {
+		t.Errorf("unexpected span names: %s, %s", run.name, connect.name)
+	}
+	if !run.ended || run.err != err || !connect.ended || connect.err == nil {
+		t.Error("expected both spans to end with the connection error")
+	}
+	if fmt.Sprint(run.attrs) != "map[host:redis.internal mode:random port:6379 sampled:0]" {
+		t.Errorf("unexpected run span attributes: %v", run.attrs)
+	}
+
+	if _, err := NewOptions(WithTracer(nil)); err == nil {
+		t.Error("expected an error for a nil Tracer")
+	}
+}
+
+func TestSamplingMode(t *testing.T) {
+
+	for _, c := range []struct {
+		opts Options
+		mode string
+	}{
+		{Options{}, "random"},
+		{Options{KeysGlob: "*", ScanLimit: 10}, "glob"},
+		{Options{KeySource: NewKeyList(nil)}, "source"},
+		{Options{HashSlots: []int{1}}, "slots"},
+		{Options{ScanLimit: 10}, "scan"},
+	} {
+		if mode := samplingMode(c.opts); mode != c.mode {
+			t.Errorf("expected %s, actual: %s", c.mode, mode)
+		}
+	}
+}
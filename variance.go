@@ -0,0 +1,131 @@
+/*
+ * Copyright (C) 2015 zulily, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reckon
+
+import (
+	"errors"
+	"math"
+)
+
+// A Spread is the mean and standard deviation of a statistic across repeated
+// sampling operations
+type Spread struct {
+	Mean   float64
+	StdDev float64
+}
+
+// CoeffVar returns the coefficient of variation of the statistic: its
+// standard deviation relative to its mean, or NaN if the mean is zero
+func (s Spread) CoeffVar() float64 {
+	if s.Mean == 0 {
+		return math.NaN()
+	}
+	return s.StdDev / math.Abs(s.Mean)
+}
+
+// A Variance describes how the statistics of one aggregation group varied
+// across repeated sampling operations.  A high coefficient of variation (see
+// Spread.CoeffVar) signals that too few keys were sampled for the statistic
+// to be trusted.  See RunVariance.
+type Variance struct {
+	// Iterations is the number of sampling operations in which keys of the
+	// group were observed
+	Iterations int
+
+	// MeanSize is the spread of the mean size of the values of each
+	// ValueType (see TypeSummary), over the iterations in which values of the
+	// type were observed
+	MeanSize map[ValueType]Spread
+
+	// TypeMix is the spread of the fraction of the group's keys that were of
+	// each ValueType, over the iterations in which the group was observed
+	TypeMix map[ValueType]Spread
+}
+
+// RunVariance runs the sampling operation configured by `fns` (see
+// NewOptions) `iterations` times against the same redis instance, and
+// returns the Variance of the statistics of each aggregation group across
+// the runs, e.g. to choose a MinSamples empirically.  At least two
+// iterations are required.  If any run fails, its error is returned.
+func RunVariance(iterations int, aggregator Aggregator, fns ...func(*Options) error) (map[string]*Variance, error) {
+	if iterations < 2 {
+		return nil, errors.New("iterations must be at least 2")
+	}
+	opts, err := NewOptions(fns...)
+	if err != nil {
+		return nil, err
+	}
+
+	runs := make([]map[string]*Results, 0, iterations)
+	for i := 0; i < iterations; i++ {
+		results, _, err := Run(opts, aggregator)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, results)
+	}
+	return variance(runs), nil
+}
+
+// variance computes the Variance of each group across `runs`
+func variance(runs []map[string]*Results) map[string]*Variance {
+	sizes := make(map[string]map[ValueType]*runningStats)
+	mix := make(map[string]map[ValueType]*runningStats)
+	variances := make(map[string]*Variance)
+
+	for _, results := range runs {
+		for group, r := range results {
+			v, ok := variances[group]
+			if !ok {
+				v = &Variance{}
+				variances[group] = v
+				sizes[group] = make(map[ValueType]*runningStats)
+				mix[group] = make(map[ValueType]*runningStats)
+				for _, vt := range sampledTypes {
+					sizes[group][vt] = &runningStats{}
+					mix[group][vt] = &runningStats{}
+				}
+			}
+			v.Iterations++
+
+			summary := r.Summary()
+			for _, vt := range sampledTypes {
+				ts, observed := summary.Types[vt]
+				if observed {
+					sizes[group][vt].add(ts.MeanSize)
+				}
+				if r.KeyCount > 0 {
+					mix[group][vt].add(float64(ts.Keys) / float64(r.KeyCount))
+				}
+			}
+		}
+	}
+
+	for group, v := range variances {
+		v.MeanSize = make(map[ValueType]Spread)
+		v.TypeMix = make(map[ValueType]Spread)
+		for _, vt := range sampledTypes {
+			if s := sizes[group][vt]; s.n > 0 {
+				v.MeanSize[vt] = s.spread()
+			}
+			if m := mix[group][vt]; m.n > 0 && m.mean > 0 {
+				v.TypeMix[vt] = m.spread()
+			}
+		}
+	}
+	return variances
+}
@@ -0,0 +1,65 @@
+/*
+ * Copyright (C) 2015 zulily, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reckon
+
+import (
+	"math"
+	"testing"
+)
+
+func TestVariance(t *testing.T) {
+
+	// three runs: strings of mean size 4, 6 and 8, and a mix of strings and
+	// sets that varies, except in the second run, in which no sets are seen
+	var runs []map[string]*Results
+	for i, size := range []int{4, 6, 8} {
+		r := NewResults()
+		r.observeString("str", string(make([]byte, size)), "")
+		r.observeString("str", string(make([]byte, size)), "")
+		if i != 1 {
+			r.observeSet("set", 3, []string{"member"})
+			r.observeSet("set", 3, []string{"member"})
+		}
+		runs = append(runs, map[string]*Results{"g": r})
+	}
+	runs[2]["h"] = NewResults()
+
+	v := variance(runs)
+	g := v["g"]
+	assertInt(t, 3, g.Iterations)
+	assertFloat(t, 6, g.MeanSize[TypeString].Mean, 1e-9)
+	assertFloat(t, 2, g.MeanSize[TypeString].StdDev, 1e-9)
+	assertFloat(t, 1.0/3, g.MeanSize[TypeString].CoeffVar(), 1e-9)
+	assertFloat(t, 3, g.MeanSize[TypeSet].Mean, 1e-9)
+	assertFloat(t, 0, g.MeanSize[TypeSet].StdDev, 1e-9)
+
+	// 1/2, 1 and 1/2 of the keys were strings
+	assertFloat(t, 2.0/3, g.TypeMix[TypeString].Mean, 1e-9)
+	assertFloat(t, math.Sqrt(1.0/12), g.TypeMix[TypeString].StdDev, 1e-9)
+	if _, ok := g.TypeMix[TypeHash]; ok {
+		t.Error("expected no type mix for hashes, which were never observed")
+	}
+
+	assertInt(t, 1, v["h"].Iterations)
+	if len(v["h"].MeanSize) != 0 {
+		t.Errorf("expected no mean sizes for an empty group: %v", v["h"].MeanSize)
+	}
+
+	if _, err := RunVariance(1, AggregatorFunc(AnyKey)); err == nil {
+		t.Error("expected an error for fewer than two iterations")
+	}
+}